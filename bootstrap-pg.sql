@@ -4,14 +4,22 @@ CREATE TABLE relationships (
     subject_relation TEXT not null DEFAULT '',
     resource_type TEXT not null,
     resource_id TEXT not null,
-    relationship TEXT not null
+    relationship TEXT not null,
+    caveat_name TEXT not null DEFAULT '',
+    -- the program embeds the context in the JSON of the caveats of the derived
+    -- relationships, so it must be a JSON object
+    caveat_context TEXT not null DEFAULT '{}' CHECK (jsonb_typeof(caveat_context::jsonb) = 'object'),
+    expires_at TIMESTAMP
 );
 
 CREATE TABLE type_restrictions (
 	resource_type TEXT not null,
 	relation TEXT not null,
 	subject_type TEXT not null,
-	subject_relation TEXT not null
+	subject_relation TEXT not null,
+	wildcard BOOLEAN not null DEFAULT false,
+	caveat TEXT not null DEFAULT '',
+	with_expiration BOOLEAN not null DEFAULT false
 );
 
 CREATE TABLE unary_rules (
@@ -25,5 +33,46 @@ CREATE TABLE binary_rules (
     prerequisite1_relationship TEXT not null,
     prerequisite2_resource_type TEXT not null,
     prerequisite2_relationship TEXT not null,
-    derived_relationship TEXT not null
-);
\ No newline at end of file
+    derived_relationship TEXT not null,
+    kind TEXT not null
+);
+
+CREATE TABLE negated_binary_rules (
+    prerequisite1_resource_type TEXT not null,
+    prerequisite1_relationship TEXT not null,
+    prerequisite2_resource_type TEXT not null,
+    prerequisite2_relationship TEXT not null,
+    derived_relationship TEXT not null,
+    stratum INTEGER not null
+);
+
+-- The version of the rules written to the rule tables by 'sync-rules'.
+CREATE TABLE schema_version (
+    version TEXT not null,
+    synced_at TIMESTAMP not null
+);
+
+-- The tables the pipeline writes to when the program is generated with
+-- -postgres-output, which 'serve -postgres' reads from. derived_relationships
+-- also has the marker of each revision the pipeline has reached, with an empty
+-- resource type.
+CREATE TABLE derived_relationships (
+    subject_type TEXT not null,
+    subject_id TEXT not null,
+    subject_relation TEXT not null,
+    resource_type TEXT not null,
+    resource_id TEXT not null,
+    relationship TEXT not null,
+    caveats TEXT not null,
+    expires_at TIMESTAMP,
+    PRIMARY KEY (resource_type, resource_id, relationship, subject_type, subject_relation, subject_id, caveats)
+);
+CREATE INDEX derived_relationships_subject_idx ON derived_relationships (subject_type, subject_id, subject_relation, relationship, resource_type);
+
+CREATE TABLE depth_limited_subjects (
+    marker TEXT not null,
+    subject_type TEXT not null,
+    subject_id TEXT not null,
+    subject_relation TEXT not null,
+    PRIMARY KEY (subject_type, subject_id, subject_relation)
+);
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/authzed/spicedb/pkg/caveats"
+	caveattypes "github.com/authzed/spicedb/pkg/caveats/types"
+	authorizerpb "github.com/jon-whit/feldera-rebac/protos/gen/go/authorizer/v1alpha1"
+)
+
+// caveatRef is a caveat a derived relationship is conditioned on, together
+// with the context the relationship was written with. The Feldera program
+// encodes each caveatRef of a derived relationship as a JSON string.
+type caveatRef struct {
+	Name    string         `json:"name"`
+	Context map[string]any `json:"context"`
+}
+
+// validCaveatContext returns true if the caveat context of a relationship is
+// empty or a JSON object. The program embeds the context in the JSON of the
+// caveatRef as is, so any other context would make the caveats of the derived
+// relationships undecodable.
+func validCaveatContext(context string) bool {
+	var values map[string]any
+	return context == "" || (json.Unmarshal([]byte(context), &values) == nil && values != nil)
+}
+
+// parseCaveatRefs decodes the caveats of a derived relationship.
+func parseCaveatRefs(encoded []string) ([]caveatRef, error) {
+	refs := make([]caveatRef, 0, len(encoded))
+	for _, e := range encoded {
+		var ref caveatRef
+		if err := json.Unmarshal([]byte(e), &ref); err != nil {
+			return nil, fmt.Errorf("failed to decode caveat '%s': %w", e, err)
+		}
+
+		refs = append(refs, ref)
+	}
+
+	return refs, nil
+}
+
+// compiledCaveat is a caveat definition of the schema compiled to CEL.
+type compiledCaveat struct {
+	caveat     *caveats.CompiledCaveat
+	parameters map[string]caveattypes.VariableType
+}
+
+// compileCaveats compiles the caveat definitions of a schema by name.
+func compileCaveats(definitions map[string]*authorizerpb.CaveatDefinition) (map[string]compiledCaveat, error) {
+	compiled := make(map[string]compiledCaveat, len(definitions))
+
+	for name, definition := range definitions {
+		parameters := make(map[string]caveattypes.VariableType, len(definition.GetParameters()))
+		for parameter, typeName := range definition.GetParameters() {
+			varType, err := parseCaveatParameterType(typeName)
+			if err != nil {
+				return nil, fmt.Errorf("caveat '%s' parameter '%s': %w", name, parameter, err)
+			}
+
+			parameters[parameter] = varType
+		}
+
+		env, err := caveats.EnvForVariablesWithDefaultTypeSet(parameters)
+		if err != nil {
+			return nil, fmt.Errorf("caveat '%s': %w", name, err)
+		}
+
+		caveat, err := caveats.CompileCaveatWithName(env, definition.GetExpression(), name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile caveat '%s': %w", name, err)
+		}
+
+		compiled[name] = compiledCaveat{caveat: caveat, parameters: parameters}
+	}
+
+	return compiled, nil
+}
+
+// parseCaveatParameterType parses a caveat parameter type such as 'int' or
+// 'map<list<string>>'.
+func parseCaveatParameterType(typeName string) (caveattypes.VariableType, error) {
+	typeName = strings.TrimSpace(typeName)
+
+	name, children, generic := strings.Cut(typeName, "<")
+	if !generic {
+		varType, err := caveattypes.Default.BuildType(name, nil)
+		if err != nil {
+			return caveattypes.VariableType{}, err
+		}
+
+		return *varType, nil
+	}
+
+	if !strings.HasSuffix(children, ">") {
+		return caveattypes.VariableType{}, fmt.Errorf("malformed type '%s'", typeName)
+	}
+
+	var childTypes []caveattypes.VariableType
+	for _, child := range splitTypeArguments(strings.TrimSuffix(children, ">")) {
+		childType, err := parseCaveatParameterType(child)
+		if err != nil {
+			return caveattypes.VariableType{}, err
+		}
+
+		childTypes = append(childTypes, childType)
+	}
+
+	varType, err := caveattypes.Default.BuildType(strings.TrimSpace(name), childTypes)
+	if err != nil {
+		return caveattypes.VariableType{}, err
+	}
+
+	return *varType, nil
+}
+
+// splitTypeArguments splits the comma separated type arguments of a generic
+// type, ignoring the commas of nested generic types.
+func splitTypeArguments(arguments string) []string {
+	var split []string
+
+	depth, start := 0, 0
+	for i, c := range arguments {
+		switch c {
+		case '<':
+			depth++
+		case '>':
+			depth--
+		case ',':
+			if depth == 0 {
+				split = append(split, arguments[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(split, arguments[start:])
+}
+
+// evaluateCaveats evaluates the conjunction of the caveats of a derived
+// relationship. The context of each relationship takes precedence over the
+// request context. If any caveat evaluates to false the relationship does not
+// apply, otherwise if any caveat is missing parameters the result is
+// conditional on those parameters.
+func evaluateCaveats(
+	compiled map[string]compiledCaveat,
+	refs []caveatRef,
+	requestContext map[string]any,
+) (authorizerpb.Permissionship, []string, error) {
+	missing := map[string]struct{}{}
+
+	for _, ref := range refs {
+		caveat, ok := compiled[ref.Name]
+		if !ok {
+			return authorizerpb.Permissionship_PERMISSIONSHIP_UNSPECIFIED, nil, fmt.Errorf("undefined caveat '%s'", ref.Name)
+		}
+
+		values := make(map[string]any, len(caveat.parameters))
+		for parameter, varType := range caveat.parameters {
+			value, ok := ref.Context[parameter]
+			if !ok {
+				value, ok = requestContext[parameter]
+			}
+
+			if !ok {
+				continue
+			}
+
+			converted, err := varType.ConvertValue(value)
+			if err != nil {
+				return authorizerpb.Permissionship_PERMISSIONSHIP_UNSPECIFIED, nil, fmt.Errorf("caveat '%s' parameter '%s': %w", ref.Name, parameter, err)
+			}
+
+			values[parameter] = converted
+		}
+
+		result, err := caveats.EvaluateCaveat(caveat.caveat, values)
+		if err != nil {
+			return authorizerpb.Permissionship_PERMISSIONSHIP_UNSPECIFIED, nil, fmt.Errorf("failed to evaluate caveat '%s': %w", ref.Name, err)
+		}
+
+		if result.IsPartial() {
+			missingVarNames, err := result.MissingVarNames()
+			if err != nil {
+				return authorizerpb.Permissionship_PERMISSIONSHIP_UNSPECIFIED, nil, err
+			}
+
+			for _, name := range missingVarNames {
+				missing[name] = struct{}{}
+			}
+
+			continue
+		}
+
+		if !result.Value() {
+			return authorizerpb.Permissionship_PERMISSIONSHIP_NO_PERMISSION, nil, nil
+		}
+	}
+
+	if len(missing) > 0 {
+		return authorizerpb.Permissionship_PERMISSIONSHIP_CONDITIONAL_PERMISSION, slices.Sorted(maps.Keys(missing)), nil
+	}
+
+	return authorizerpb.Permissionship_PERMISSIONSHIP_HAS_PERMISSION, nil, nil
+}
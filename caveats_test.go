@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	authorizerpb "github.com/jon-whit/feldera-rebac/protos/gen/go/authorizer/v1alpha1"
+)
+
+func TestEvaluateCaveats(t *testing.T) {
+	compiled, err := compileCaveats(map[string]*authorizerpb.CaveatDefinition{
+		"ip_allowlist": {
+			Name: "ip_allowlist",
+			Parameters: map[string]string{
+				"cidr":    "string",
+				"user_ip": "ipaddress",
+			},
+			Expression: "user_ip.in_cidr(cidr)",
+		},
+		"business_hours": {
+			Name: "business_hours",
+			Parameters: map[string]string{
+				"hour":    "int",
+				"allowed": "list<int>",
+			},
+			Expression: "hour in allowed",
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to compile caveats: %v", err)
+	}
+
+	tests := []struct {
+		name            string
+		refs            []caveatRef
+		context         map[string]any
+		permissionship  authorizerpb.Permissionship
+		missingContexts []string
+	}{
+		{
+			name:           "no caveats",
+			permissionship: authorizerpb.Permissionship_PERMISSIONSHIP_HAS_PERMISSION,
+		},
+		{
+			name:           "satisfied",
+			refs:           []caveatRef{{Name: "ip_allowlist", Context: map[string]any{"cidr": "10.0.0.0/8"}}},
+			context:        map[string]any{"user_ip": "10.1.2.3"},
+			permissionship: authorizerpb.Permissionship_PERMISSIONSHIP_HAS_PERMISSION,
+		},
+		{
+			name:           "not satisfied",
+			refs:           []caveatRef{{Name: "ip_allowlist", Context: map[string]any{"cidr": "10.0.0.0/8"}}},
+			context:        map[string]any{"user_ip": "192.168.1.1"},
+			permissionship: authorizerpb.Permissionship_PERMISSIONSHIP_NO_PERMISSION,
+		},
+		{
+			name:           "relationship context takes precedence",
+			refs:           []caveatRef{{Name: "ip_allowlist", Context: map[string]any{"cidr": "10.0.0.0/8"}}},
+			context:        map[string]any{"user_ip": "192.168.1.1", "cidr": "192.168.0.0/16"},
+			permissionship: authorizerpb.Permissionship_PERMISSIONSHIP_NO_PERMISSION,
+		},
+		{
+			name:            "missing context",
+			refs:            []caveatRef{{Name: "ip_allowlist", Context: map[string]any{"cidr": "10.0.0.0/8"}}},
+			permissionship:  authorizerpb.Permissionship_PERMISSIONSHIP_CONDITIONAL_PERMISSION,
+			missingContexts: []string{"user_ip"},
+		},
+		{
+			name: "conjunction with a false caveat",
+			refs: []caveatRef{
+				{Name: "ip_allowlist", Context: map[string]any{"cidr": "10.0.0.0/8"}},
+				{Name: "business_hours", Context: map[string]any{"allowed": []any{9.0, 10.0, 11.0}}},
+			},
+			context:        map[string]any{"hour": 20.0},
+			permissionship: authorizerpb.Permissionship_PERMISSIONSHIP_NO_PERMISSION,
+		},
+		{
+			name: "conjunction with a conditional caveat",
+			refs: []caveatRef{
+				{Name: "ip_allowlist", Context: map[string]any{"cidr": "10.0.0.0/8"}},
+				{Name: "business_hours", Context: map[string]any{"allowed": []any{9.0, 10.0, 11.0}}},
+			},
+			context:         map[string]any{"hour": 10.0},
+			permissionship:  authorizerpb.Permissionship_PERMISSIONSHIP_CONDITIONAL_PERMISSION,
+			missingContexts: []string{"user_ip"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			permissionship, missing, err := evaluateCaveats(compiled, test.refs, test.context)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if permissionship != test.permissionship {
+				t.Errorf("expected %v, got %v", test.permissionship, permissionship)
+			}
+
+			if !reflect.DeepEqual(missing, test.missingContexts) {
+				t.Errorf("expected missing context %v, got %v", test.missingContexts, missing)
+			}
+		})
+	}
+}
+
+func TestParseCaveatParameterType(t *testing.T) {
+	for _, typeName := range []string{"int", "ipaddress", "list<string>", "map<list<int>>"} {
+		varType, err := parseCaveatParameterType(typeName)
+		if err != nil {
+			t.Errorf("failed to parse '%s': %v", typeName, err)
+			continue
+		}
+
+		if varType.String() != typeName {
+			t.Errorf("expected '%s', got '%s'", typeName, varType.String())
+		}
+	}
+
+	if _, err := parseCaveatParameterType("list<unknown>"); err == nil {
+		t.Errorf("expected an error for an unknown type")
+	}
+}
+
+func TestValidCaveatContext(t *testing.T) {
+	for context, valid := range map[string]bool{
+		``:                          true,
+		`{}`:                        true,
+		`{"cidr": "10.0.0.0/8"}`:    true,
+		`{"cidrs": ["10.0.0.0/8"]}`: true,
+		`null`:                      false,
+		`["10.0.0.0/8"]`:            false,
+		`"10.0.0.0/8"`:              false,
+		`{"cidr": `:                 false,
+	} {
+		if validCaveatContext(context) != valid {
+			t.Errorf("expected the validity of the context '%s' to be %t", context, valid)
+		}
+	}
+
+	// the caveat the program encodes for the relationship decodes
+	r := Relationship{CaveatName: "ip_allowlist", CaveatContext: `{"cidr": "10.0.0.0/8"}`}
+	if _, err := parseCaveatRefs([]string{`{"name": "` + r.CaveatName + `", "context": ` + r.CaveatContext + `}`}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := validateRelationship(importRules, Relationship{ResourceType: "document", ResourceID: "1", Relation: "editor", SubjectType: "user", SubjectID: "jon", CaveatName: "ip_allowlist", CaveatContext: "[]"}); !errors.Is(err, errInvalidRelationship) {
+		t.Errorf("expected a context which isn't a JSON object to be invalid, got %v", err)
+	}
+}
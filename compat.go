@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+	authorizerpb "github.com/jon-whit/feldera-rebac/protos/gen/go/authorizer/v1alpha1"
+	"google.golang.org/protobuf/proto"
+)
+
+// schemaChangeKind is the kind of a change between two versions of a schema.
+type schemaChangeKind string
+
+const (
+	removedType         schemaChangeKind = "removed type"
+	addedType           schemaChangeKind = "added type"
+	removedRelation     schemaChangeKind = "removed relation"
+	addedRelation       schemaChangeKind = "added relation"
+	narrowedRestriction schemaChangeKind = "narrowed restriction"
+	widenedRestriction  schemaChangeKind = "widened restriction"
+	removedPermission   schemaChangeKind = "removed permission"
+	addedPermission     schemaChangeKind = "added permission"
+	changedPermission   schemaChangeKind = "changed permission expression"
+	removedCaveat       schemaChangeKind = "removed caveat"
+	addedCaveat         schemaChangeKind = "added caveat"
+	changedCaveat       schemaChangeKind = "changed caveat"
+)
+
+// schemaChange is a change between two versions of a schema. A breaking change
+// makes existing relationships invalid or changes the permissions derived from
+// them.
+type schemaChange struct {
+	Kind     schemaChangeKind
+	Breaking bool
+
+	// Type and Name are the type definition and the relation, permission or
+	// caveat which changed, and Restriction is the type restriction which was
+	// removed or added.
+	Type        string
+	Name        string
+	Restriction RelationTypeRestriction
+
+	// Affected is the number of existing relationships the change affects, if
+	// they were counted.
+	Affected int
+}
+
+func (c schemaChange) String() string {
+	switch {
+	case c.Kind == narrowedRestriction || c.Kind == widenedRestriction:
+		return fmt.Sprintf("%s %s", c.Kind, c.Restriction)
+	case c.Name == "":
+		return fmt.Sprintf("%s %s", c.Kind, c.Type)
+	case c.Type == "":
+		return fmt.Sprintf("%s %s", c.Kind, c.Name)
+	default:
+		return fmt.Sprintf("%s %s#%s", c.Kind, c.Type, c.Name)
+	}
+}
+
+// compareSchemas returns the changes from the old version of a schema to the
+// new one, in the order of the type definitions and their relations and
+// permissions by name.
+func compareSchemas(old, new *authorizerpb.Schema) []schemaChange {
+	var changes []schemaChange
+
+	oldTypes, newTypes := old.GetTypeDefinitions(), new.GetTypeDefinitions()
+	for _, typeName := range slices.Sorted(maps.Keys(oldTypes)) {
+		if _, ok := newTypes[typeName]; !ok {
+			changes = append(changes, schemaChange{Kind: removedType, Breaking: true, Type: typeName})
+		}
+	}
+
+	for _, typeName := range slices.Sorted(maps.Keys(newTypes)) {
+		oldType, ok := oldTypes[typeName]
+		if !ok {
+			changes = append(changes, schemaChange{Kind: addedType, Type: typeName})
+			continue
+		}
+
+		newType := newTypes[typeName]
+		changes = append(changes, compareRelations(typeName, oldType.GetRelations(), newType.GetRelations())...)
+		changes = append(changes, comparePermissions(typeName, oldType.GetPermissions(), newType.GetPermissions())...)
+	}
+
+	oldCaveats, newCaveats := old.GetCaveats(), new.GetCaveats()
+	for _, name := range slices.Sorted(maps.Keys(oldCaveats)) {
+		newCaveat, ok := newCaveats[name]
+		switch {
+		case !ok:
+			changes = append(changes, schemaChange{Kind: removedCaveat, Breaking: true, Name: name})
+		case !proto.Equal(oldCaveats[name], newCaveat):
+			changes = append(changes, schemaChange{Kind: changedCaveat, Breaking: true, Name: name})
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(newCaveats)) {
+		if _, ok := oldCaveats[name]; !ok {
+			changes = append(changes, schemaChange{Kind: addedCaveat, Name: name})
+		}
+	}
+
+	return changes
+}
+
+// compareRelations returns the changes of the relations of a type. Type
+// restrictions are compared by all of their columns, as the pipeline matches
+// relationships with them, so changing the caveat of a restriction narrows it
+// and widens it by another.
+func compareRelations(typeName string, old, new map[string]*authorizerpb.Relation) []schemaChange {
+	var changes []schemaChange
+	for _, name := range slices.Sorted(maps.Keys(old)) {
+		if _, ok := new[name]; !ok {
+			changes = append(changes, schemaChange{Kind: removedRelation, Breaking: true, Type: typeName, Name: name})
+			continue
+		}
+
+		newRestrictions := relationTypeRestrictions(typeName, name, new[name])
+		for _, restriction := range relationTypeRestrictions(typeName, name, old[name]) {
+			if !slices.Contains(newRestrictions, restriction) {
+				changes = append(changes, schemaChange{Kind: narrowedRestriction, Breaking: true, Type: typeName, Name: name, Restriction: restriction})
+			}
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(new)) {
+		if _, ok := old[name]; !ok {
+			changes = append(changes, schemaChange{Kind: addedRelation, Type: typeName, Name: name})
+			continue
+		}
+
+		oldRestrictions := relationTypeRestrictions(typeName, name, old[name])
+		for _, restriction := range relationTypeRestrictions(typeName, name, new[name]) {
+			if !slices.Contains(oldRestrictions, restriction) {
+				changes = append(changes, schemaChange{Kind: widenedRestriction, Type: typeName, Name: name, Restriction: restriction})
+			}
+		}
+	}
+
+	return changes
+}
+
+func relationTypeRestrictions(typeName, relationName string, relation *authorizerpb.Relation) []RelationTypeRestriction {
+	var restrictions []RelationTypeRestriction
+	for _, restriction := range relation.GetTypeRestrictions() {
+		restrictions = append(restrictions, RelationTypeRestriction{
+			ResourceType:    typeName,
+			Relation:        relationName,
+			SubjectType:     restriction.GetResourceType(),
+			SubjectRelation: restriction.GetRelation(),
+			Wildcard:        restriction.GetWildcard(),
+			Caveat:          restriction.GetCaveat(),
+			WithExpiration:  restriction.GetWithExpiration(),
+		})
+	}
+
+	return restrictions
+}
+
+func comparePermissions(typeName string, old, new map[string]*authorizerpb.Permission) []schemaChange {
+	var changes []schemaChange
+	for _, name := range slices.Sorted(maps.Keys(old)) {
+		newPermission, ok := new[name]
+		switch {
+		case !ok:
+			changes = append(changes, schemaChange{Kind: removedPermission, Breaking: true, Type: typeName, Name: name})
+		case !proto.Equal(old[name].GetExpression(), newPermission.GetExpression()):
+			changes = append(changes, schemaChange{Kind: changedPermission, Breaking: true, Type: typeName, Name: name})
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(new)) {
+		if _, ok := old[name]; !ok {
+			changes = append(changes, schemaChange{Kind: addedPermission, Type: typeName, Name: name})
+		}
+	}
+
+	return changes
+}
+
+// affects returns true if the breaking change affects the relationship, which
+// matches the type restriction of the old schema which allowed it. Removed
+// types, relations and restrictions affect the relationships they allowed,
+// which the new schema rejects. Changed or removed permissions and caveats
+// affect the relationships they may be derived from: those of resources of
+// the type, or those conditioned on the caveat.
+func (c schemaChange) affects(r Relationship, restriction RelationTypeRestriction) bool {
+	switch c.Kind {
+	case removedType:
+		return r.ResourceType == c.Type
+	case removedRelation:
+		return r.ResourceType == c.Type && r.Relation == c.Name
+	case narrowedRestriction:
+		return restriction == c.Restriction
+	case removedPermission, changedPermission:
+		return r.ResourceType == c.Type
+	case removedCaveat, changedCaveat:
+		return r.CaveatName == c.Name
+	default:
+		return false
+	}
+}
+
+// countAffected counts the relationships each breaking change affects, of the
+// relationships read by read which the old schema allows. Relationships the
+// old schema rejected were never derived from, so no change affects them.
+func countAffected(changes []schemaChange, old SchemaQueryRules, read func(func(Relationship) error) error) error {
+	return read(func(r Relationship) error {
+		restriction, ok := matchingTypeRestriction(old, r)
+		if !ok {
+			return nil
+		}
+
+		for i, change := range changes {
+			if change.Breaking && change.affects(r, restriction) {
+				changes[i].Affected++
+			}
+		}
+
+		return nil
+	})
+}
+
+// matchingTypeRestriction returns the type restriction of the rules which
+// allows r, if any.
+func matchingTypeRestriction(rules SchemaQueryRules, r Relationship) (RelationTypeRestriction, bool) {
+	for _, restriction := range rules.RelationTypeRestrictions {
+		if rejectionReason(SchemaQueryRules{RelationTypeRestrictions: []RelationTypeRestriction{restriction}}, r) == "" {
+			return restriction, true
+		}
+	}
+
+	return RelationTypeRestriction{}, false
+}
+
+func runCheckCompat(args []string) {
+	flags := flag.NewFlagSet("check-compat", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: check-compat [flags] <old schema> <new schema>")
+		flags.PrintDefaults()
+	}
+	relationshipsPath := flags.String("relationships", "", "Path to a (.csv, .ndjson or .zed) relationships file to count the relationships each breaking change affects")
+	format := flags.String("format", "", "The format of the relationships file (csv, ndjson or zed), by default inferred from its extension")
+	postgres := flags.Bool("postgres", false, "Count the relationships each breaking change affects in the relationships table in Postgres")
+	postgresURI := flags.String("postgres-uri", defaultPostgresURI, "The URI of the Postgres database with the relationships table")
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	oldSchema, err := loadSchema(flags.Arg(0))
+	if err != nil {
+		log.Fatalf("failed to load schema '%s': %v", flags.Arg(0), err)
+	}
+
+	newSchema, err := loadSchema(flags.Arg(1))
+	if err != nil {
+		log.Fatalf("failed to load schema '%s': %v", flags.Arg(1), err)
+	}
+
+	changes := compareSchemas(oldSchema, newSchema)
+
+	var read func(func(Relationship) error) error
+	switch {
+	case *relationshipsPath != "" && *postgres:
+		log.Fatalf("-relationships and -postgres are mutually exclusive")
+	case *relationshipsPath != "":
+		read = func(fn func(Relationship) error) error {
+			relationships, err := readRelationshipsFile(*relationshipsPath, *format)
+			if err != nil {
+				return err
+			}
+
+			for _, r := range relationships {
+				if err := fn(r); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}
+	case *postgres:
+		read = func(fn func(Relationship) error) error {
+			ctx := context.Background()
+
+			conn, err := pgx.Connect(ctx, *postgresURI)
+			if err != nil {
+				return fmt.Errorf("failed to connect to postgres: %w", err)
+			}
+			defer conn.Close(ctx)
+
+			return readPostgresRelationships(ctx, conn, fn)
+		}
+	}
+
+	if read != nil {
+		rules, err := mapSchemaToQueryRules(oldSchema)
+		if err != nil {
+			log.Fatalf("failed to map schema '%s' to rules: %v", flags.Arg(0), err)
+		}
+
+		if err := countAffected(changes, rules, read); err != nil {
+			log.Fatalf("failed to count the affected relationships: %v", err)
+		}
+	}
+
+	breaking := 0
+	for _, change := range changes {
+		if !change.Breaking {
+			fmt.Printf("compatible %s\n", change)
+			continue
+		}
+
+		breaking++
+		if read != nil {
+			fmt.Printf("BREAKING %s (%d relationships)\n", change, change.Affected)
+		} else {
+			fmt.Printf("BREAKING %s\n", change)
+		}
+	}
+
+	if breaking > 0 {
+		fmt.Printf("found %d breaking change(s) in %d change(s)\n", breaking, len(changes))
+		os.Exit(1)
+	}
+
+	fmt.Printf("no breaking changes found in %d change(s)\n", len(changes))
+}
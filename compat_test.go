@@ -0,0 +1,131 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	authorizerpb "github.com/jon-whit/feldera-rebac/protos/gen/go/authorizer/v1alpha1"
+	"google.golang.org/protobuf/proto"
+)
+
+func compatSchema() *authorizerpb.Schema {
+	viewer := &authorizerpb.PermissionExpressionRef{
+		Expression: &authorizerpb.PermissionExpressionRef_UnaryExpression{
+			UnaryExpression: &authorizerpb.UnaryPermissionExpression{SourceRelation: "viewer"},
+		},
+	}
+
+	return &authorizerpb.Schema{
+		TypeDefinitions: map[string]*authorizerpb.TypeDefinition{
+			"user":  {Name: "user"},
+			"group": {Name: "group"},
+			"document": {
+				Name: "document",
+				Relations: map[string]*authorizerpb.Relation{
+					"viewer": {Name: "viewer", TypeRestrictions: []*authorizerpb.RelationTypeRestriction{
+						{ResourceType: "user"},
+						{ResourceType: "group", Relation: "member"},
+					}},
+					"editor": {Name: "editor", TypeRestrictions: []*authorizerpb.RelationTypeRestriction{
+						{ResourceType: "user", Caveat: "ip_allowlist"},
+					}},
+				},
+				Permissions: map[string]*authorizerpb.Permission{
+					"can_view": {Name: "can_view", Expression: viewer},
+				},
+			},
+		},
+		Caveats: map[string]*authorizerpb.CaveatDefinition{
+			"ip_allowlist": {Name: "ip_allowlist", Expression: "ip in cidr"},
+		},
+	}
+}
+
+func TestCompareSchemas(t *testing.T) {
+	old := compatSchema()
+
+	new := proto.Clone(old).(*authorizerpb.Schema)
+	delete(new.TypeDefinitions, "group")
+	new.TypeDefinitions["folder"] = &authorizerpb.TypeDefinition{Name: "folder"}
+
+	document := new.TypeDefinitions["document"]
+	document.Relations["viewer"].TypeRestrictions = []*authorizerpb.RelationTypeRestriction{{ResourceType: "user"}, {ResourceType: "user", Wildcard: true}}
+	delete(document.Relations, "editor")
+	document.Permissions["can_view"].Expression = &authorizerpb.PermissionExpressionRef{
+		Expression: &authorizerpb.PermissionExpressionRef_UnaryExpression{
+			UnaryExpression: &authorizerpb.UnaryPermissionExpression{SourceRelation: "owner"},
+		},
+	}
+	new.Caveats["ip_allowlist"].Expression = "ip in cidrs"
+
+	var changes []string
+	for _, change := range compareSchemas(old, new) {
+		prefix := "compatible "
+		if change.Breaking {
+			prefix = "BREAKING "
+		}
+		changes = append(changes, prefix+change.String())
+	}
+
+	expected := []string{
+		"BREAKING removed type group",
+		"BREAKING removed relation document#editor",
+		"BREAKING narrowed restriction viewer(group#member, document)",
+		"compatible widened restriction viewer(user:*, document)",
+		"BREAKING changed permission expression document#can_view",
+		"compatible added type folder",
+		"BREAKING changed caveat ip_allowlist",
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("expected the changes %q, got %q", expected, changes)
+	}
+
+	if changes := compareSchemas(old, compatSchema()); len(changes) != 0 {
+		t.Errorf("expected no changes between equal schemas, got %v", changes)
+	}
+}
+
+func TestCountAffected(t *testing.T) {
+	old := compatSchema()
+
+	new := proto.Clone(old).(*authorizerpb.Schema)
+	new.TypeDefinitions["document"].Relations["viewer"].TypeRestrictions = []*authorizerpb.RelationTypeRestriction{{ResourceType: "user"}}
+	delete(new.TypeDefinitions["document"].Relations, "editor")
+	delete(new.Caveats, "ip_allowlist")
+
+	relationships := []Relationship{
+		{ResourceType: "document", ResourceID: "1", Relation: "viewer", SubjectType: "user", SubjectID: "jon"},
+		{ResourceType: "document", ResourceID: "1", Relation: "viewer", SubjectType: "group", SubjectID: "eng", SubjectRelation: "member"},
+		{ResourceType: "document", ResourceID: "2", Relation: "viewer", SubjectType: "group", SubjectID: "iam", SubjectRelation: "member"},
+		{ResourceType: "document", ResourceID: "2", Relation: "editor", SubjectType: "user", SubjectID: "bob", CaveatName: "ip_allowlist"},
+		// rejected by the old schema, so no change affects it
+		{ResourceType: "document", ResourceID: "3", Relation: "editor", SubjectType: "user", SubjectID: "alice"},
+	}
+
+	changes := compareSchemas(old, new)
+	err := countAffected(changes, mustMapSchemaToQueryRules(t, old), func(fn func(Relationship) error) error {
+		for _, r := range relationships {
+			if err := fn(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	affected := map[string]int{}
+	for _, change := range changes {
+		affected[change.String()] = change.Affected
+	}
+
+	expected := map[string]int{
+		"removed relation document#editor":                    1,
+		"narrowed restriction viewer(group#member, document)": 2,
+		"removed caveat ip_allowlist":                         1,
+	}
+	if !reflect.DeepEqual(affected, expected) {
+		t.Errorf("expected the affected relationships %v, got %v", expected, affected)
+	}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+)
+
+// userset is a node of the userset graph, the set of subjects with a relation
+// to a resource (e.g. 'group:eng#member').
+type userset struct {
+	Type     string
+	ID       string
+	Relation string
+}
+
+func (u userset) String() string {
+	return fmt.Sprintf("%s:%s#%s", u.Type, u.ID, u.Relation)
+}
+
+// relationshipCycle is a cycle of usersets, each of which is contained in the
+// next.
+type relationshipCycle struct {
+
+	// Path is a cycle through the usersets of a strongly connected component
+	// of the userset graph, starting and ending at the same userset.
+	Path []userset
+
+	// Relationships are all of the relationships between the usersets of the
+	// component. Every cycle through the component consists of them, so these
+	// are the relationships to clean up to break the cycles.
+	Relationships []Relationship
+}
+
+// usersetEdge is an edge of the userset graph, from the userset whose
+// subjects the relationship derives into another.
+type usersetEdge struct {
+	relationship Relationship
+	to           userset
+}
+
+// findCycles finds the cycles of the userset graph of the relationships which
+// are valid under the type restrictions of the rules. A relationship with a
+// subject relation (e.g. 'group:eng#member@group:iam#member') is an edge from
+// the subject userset to the resource userset, since the pipeline expands
+// every subject of the former into the latter. A relationship of the
+// tupleset of an arrow (e.g. 'folder:b#parent@folder:a' of
+// 'can_view = parent->can_view') is an edge from the target of the arrow on
+// the subject to the relation the arrow derives on the resource (e.g. from
+// 'folder:a#can_view' to 'folder:b#can_view').
+func findCycles(rules SchemaQueryRules, relationships []Relationship) []relationshipCycle {
+	restrictions := make(map[RelationTypeRestriction]struct{}, len(rules.RelationTypeRestrictions))
+	for _, restriction := range rules.RelationTypeRestrictions {
+		restrictions[RelationTypeRestriction{
+			ResourceType:    restriction.ResourceType,
+			Relation:        restriction.Relation,
+			SubjectType:     restriction.SubjectType,
+			SubjectRelation: restriction.SubjectRelation,
+		}] = struct{}{}
+	}
+
+	arrows := map[relationKey][]BinaryRule{}
+	for _, rule := range rules.BinaryRules {
+		if rule.Kind == ArrowRule {
+			tupleset := relationKey{rule.SecondResourceType, rule.SecondRelation}
+			arrows[tupleset] = append(arrows[tupleset], rule)
+		}
+	}
+
+	edges := map[userset][]usersetEdge{}
+	var nodes []userset
+	addNode := func(u userset) {
+		if _, ok := edges[u]; !ok {
+			edges[u] = nil
+			nodes = append(nodes, u)
+		}
+	}
+
+	addEdge := func(from, to userset, r Relationship) {
+		addNode(from)
+		addNode(to)
+		edges[from] = append(edges[from], usersetEdge{relationship: r, to: to})
+	}
+
+	for _, r := range relationships {
+		restriction := RelationTypeRestriction{
+			ResourceType:    r.ResourceType,
+			Relation:        r.Relation,
+			SubjectType:     r.SubjectType,
+			SubjectRelation: r.SubjectRelation,
+		}
+		if _, ok := restrictions[restriction]; !ok {
+			continue
+		}
+
+		if r.SubjectRelation != "" {
+			from := userset{Type: r.SubjectType, ID: r.SubjectID, Relation: r.SubjectRelation}
+			addEdge(from, userset{Type: r.ResourceType, ID: r.ResourceID, Relation: r.Relation}, r)
+		}
+
+		for _, rule := range arrows[relationKey{r.ResourceType, r.Relation}] {
+			if rule.FirstResourceType != r.SubjectType {
+				continue
+			}
+
+			from := userset{Type: r.SubjectType, ID: r.SubjectID, Relation: rule.FirstRelation}
+			addEdge(from, userset{Type: r.ResourceType, ID: r.ResourceID, Relation: rule.DerivedRelation}, r)
+		}
+	}
+
+	var cycles []relationshipCycle
+	for _, component := range stronglyConnectedComponents(nodes, edges) {
+		members := make(map[userset]struct{}, len(component))
+		for _, u := range component {
+			members[u] = struct{}{}
+		}
+
+		var internal []Relationship
+		for _, u := range component {
+			for _, e := range edges[u] {
+				if _, ok := members[e.to]; ok {
+					internal = append(internal, e.relationship)
+				}
+			}
+		}
+
+		// a component of a single userset is only a cycle if it contains itself
+		if len(internal) == 0 {
+			continue
+		}
+
+		cycles = append(cycles, relationshipCycle{
+			Path:          cyclePath(component[0], members, edges),
+			Relationships: internal,
+		})
+	}
+
+	return cycles
+}
+
+// stronglyConnectedComponents returns the strongly connected components of the
+// userset graph using Tarjan's algorithm.
+func stronglyConnectedComponents(nodes []userset, edges map[userset][]usersetEdge) [][]userset {
+	index := map[userset]int{}
+	lowlink := map[userset]int{}
+	onStack := map[userset]bool{}
+	var stack []userset
+	var components [][]userset
+
+	var connect func(u userset)
+	connect = func(u userset) {
+		index[u] = len(index)
+		lowlink[u] = index[u]
+		stack = append(stack, u)
+		onStack[u] = true
+
+		for _, e := range edges[u] {
+			v := e.to
+			if _, visited := index[v]; !visited {
+				connect(v)
+				lowlink[u] = min(lowlink[u], lowlink[v])
+			} else if onStack[v] {
+				lowlink[u] = min(lowlink[u], index[v])
+			}
+		}
+
+		if lowlink[u] != index[u] {
+			return
+		}
+
+		var component []userset
+		for {
+			v := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[v] = false
+			component = append(component, v)
+
+			if v == u {
+				break
+			}
+		}
+
+		components = append(components, component)
+	}
+
+	for _, u := range nodes {
+		if _, visited := index[u]; !visited {
+			connect(u)
+		}
+	}
+
+	return components
+}
+
+// cyclePath returns a path from start back to itself through the usersets of
+// its strongly connected component.
+func cyclePath(start userset, members map[userset]struct{}, edges map[userset][]usersetEdge) []userset {
+	// breadth first search for the shortest path from the successors of start
+	// back to start
+	previous := map[userset]userset{}
+	queue := []userset{start}
+	visited := map[userset]bool{}
+
+	for len(queue) > 0 {
+		u := queue[0]
+		queue = queue[1:]
+
+		for _, e := range edges[u] {
+			v := e.to
+			if _, ok := members[v]; !ok || visited[v] {
+				continue
+			}
+
+			visited[v] = true
+			previous[v] = u
+
+			if v == start {
+				path := []userset{start}
+				for w := previous[start]; w != start; w = previous[w] {
+					path = append(path, w)
+				}
+
+				path = append(path, start)
+				slices.Reverse(path)
+				return path
+			}
+
+			queue = append(queue, v)
+		}
+	}
+
+	return nil
+}
+
+func runCycles(args []string) {
+	flags := flag.NewFlagSet("cycles", flag.ExitOnError)
+	schemaPath := flags.String("schema-path", "schema.json", "Path to the (.json) schema file")
+	relationshipsPath := flags.String("relationships", "", "Path to a (.csv or .ndjson) relationships file. The relationships table is read from Postgres if empty")
+	format := flags.String("format", "", "The format of the relationships file (csv, ndjson or zed), by default inferred from its extension")
+	postgresURI := flags.String("postgres-uri", defaultPostgresURI, "The URI of the Postgres database with the relationships table")
+	flags.Parse(args)
+
+	schema, err := loadSchema(*schemaPath)
+	if err != nil {
+		log.Fatalf("failed to load schema: %v", err)
+	}
+
+	var relationships []Relationship
+	if *relationshipsPath != "" {
+		relationships, err = readRelationshipsFile(*relationshipsPath, *format)
+	} else {
+		relationships, err = loadRelationshipsFromPostgres(context.Background(), *postgresURI)
+	}
+	if err != nil {
+		log.Fatalf("failed to load relationships: %v", err)
+	}
+
+	rules, err := mapSchemaToQueryRules(schema)
+	if err != nil {
+		log.Fatalf("failed to map schema to rules: %v", err)
+	}
+
+	cycles := findCycles(rules, relationships)
+	for i, cycle := range cycles {
+		path := make([]string, 0, len(cycle.Path))
+		for _, u := range cycle.Path {
+			path = append(path, u.String())
+		}
+
+		fmt.Printf("cycle %d: %s\n", i+1, strings.Join(path, " -> "))
+		for _, r := range cycle.Relationships {
+			fmt.Printf("  %s\n", r)
+		}
+	}
+
+	if len(cycles) > 0 {
+		fmt.Printf("found %d cycle(s) in %d relationships\n", len(cycles), len(relationships))
+		os.Exit(1)
+	}
+
+	fmt.Printf("no cycles found in %d relationships\n", len(relationships))
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFindCycles(t *testing.T) {
+	rules := SchemaQueryRules{
+		RelationTypeRestrictions: []RelationTypeRestriction{
+			{ResourceType: "group", Relation: "member", SubjectType: "user"},
+			{ResourceType: "group", Relation: "member", SubjectType: "group", SubjectRelation: "member"},
+			{ResourceType: "document", Relation: "viewer", SubjectType: "group", SubjectRelation: "member"},
+		},
+	}
+
+	relationships, err := collectRelationships(strings.NewReader(`subject_type,subject_id,subject_relation,resource_type,resource_id,relationship
+user,jon,,group,a,member
+group,a,member,group,b,member
+group,b,member,group,c,member
+group,c,member,group,a,member
+group,c,member,document,readme,viewer
+group,d,member,group,d,member
+group,x,member,group,y,member
+group,y,member,document,z,viewer
+document,z,viewer,group,x,member
+`), "csv")
+	if err != nil {
+		t.Fatalf("failed to read relationships: %v", err)
+	}
+
+	cycles := findCycles(rules, relationships)
+	if len(cycles) != 2 {
+		t.Fatalf("expected 2 cycles, got %d: %v", len(cycles), cycles)
+	}
+
+	var paths []string
+	for _, cycle := range cycles {
+		var path []string
+		for _, u := range cycle.Path {
+			path = append(path, u.String())
+		}
+
+		paths = append(paths, strings.Join(path, " -> "))
+
+		if cycle.Path[0] != cycle.Path[len(cycle.Path)-1] {
+			t.Errorf("expected the path to start and end at the same userset, got %v", path)
+		}
+	}
+
+	// a cycle may be reported starting at any of its usersets
+	for _, expected := range [][]string{
+		{"group:a#member", "group:b#member", "group:c#member"},
+		{"group:d#member"},
+	} {
+		found := false
+		for _, path := range paths {
+			matches := true
+			for _, u := range expected {
+				matches = matches && strings.Contains(path, u)
+			}
+
+			found = found || matches
+		}
+
+		if !found {
+			t.Errorf("expected a cycle through %v, got %v", expected, paths)
+		}
+	}
+
+	for _, cycle := range cycles {
+		if len(cycle.Path) == 4 && len(cycle.Relationships) != 3 {
+			t.Errorf("expected 3 relationships in the cycle, got %v", cycle.Relationships)
+		}
+	}
+}
+
+func TestFindCycles_Arrows(t *testing.T) {
+	// can_view = viewer + parent->can_view
+	rules := SchemaQueryRules{
+		RelationTypeRestrictions: []RelationTypeRestriction{
+			{ResourceType: "folder", Relation: "viewer", SubjectType: "user"},
+			{ResourceType: "folder", Relation: "parent", SubjectType: "folder"},
+		},
+		UnaryRules: []UnaryRule{
+			{ResourceType: "folder", SourceRelation: "viewer", DerivedRelation: "can_view"},
+		},
+		BinaryRules: []BinaryRule{
+			{FirstResourceType: "folder", FirstRelation: "can_view", SecondResourceType: "folder", SecondRelation: "parent", DerivedRelation: "can_view", Kind: ArrowRule},
+		},
+	}
+
+	relationships, err := collectRelationships(strings.NewReader(`subject_type,subject_id,subject_relation,resource_type,resource_id,relationship
+user,jon,,folder,a,viewer
+folder,a,,folder,b,parent
+folder,b,,folder,a,parent
+folder,a,,folder,c,parent
+`), "csv")
+	if err != nil {
+		t.Fatalf("failed to read relationships: %v", err)
+	}
+
+	cycles := findCycles(rules, relationships)
+	if len(cycles) != 1 {
+		t.Fatalf("expected 1 cycle, got %d: %v", len(cycles), cycles)
+	}
+
+	var path []string
+	for _, u := range cycles[0].Path {
+		path = append(path, u.String())
+	}
+
+	if len(path) != 3 || path[0] != path[2] || !slices.Contains(path, "folder:a#can_view") || !slices.Contains(path, "folder:b#can_view") {
+		t.Errorf("expected a cycle through folder:a#can_view and folder:b#can_view, got %v", path)
+	}
+
+	// the parent relationships of the loop are the ones to clean up
+	var cleanup []string
+	for _, r := range cycles[0].Relationships {
+		cleanup = append(cleanup, r.String())
+	}
+
+	slices.Sort(cleanup)
+	if expected := []string{"folder:a#parent@folder:b", "folder:b#parent@folder:a"}; !reflect.DeepEqual(cleanup, expected) {
+		t.Errorf("expected the relationships %v, got %v", expected, cleanup)
+	}
+}
+
+func TestReadRelationships(t *testing.T) {
+	expected := []Relationship{
+		{SubjectType: "user", SubjectID: "jon", ResourceType: "group", ResourceID: "eng", Relation: "member"},
+		{SubjectType: "group", SubjectID: "eng", SubjectRelation: "member", ResourceType: "document", ResourceID: "readme", Relation: "viewer", CaveatName: "ip_allowlist", CaveatContext: `{"cidr": "10.0.0.0/8"}`},
+	}
+
+	fromCSV, err := collectRelationships(strings.NewReader(`resource_type,resource_id,relationship,subject_type,subject_id,subject_relation,caveat_name,caveat_context
+group,eng,member,user,jon,,,
+document,readme,viewer,group,eng,member,ip_allowlist,"{""cidr"": ""10.0.0.0/8""}"
+`), "csv")
+	if err != nil {
+		t.Fatalf("failed to read csv: %v", err)
+	}
+
+	if !reflect.DeepEqual(fromCSV, expected) {
+		t.Errorf("expected %v, got %v", expected, fromCSV)
+	}
+
+	fromNDJSON, err := collectRelationships(strings.NewReader(`{"subject_type": "user", "subject_id": "jon", "subject_relation": "", "resource_type": "group", "resource_id": "eng", "relationship": "member"}
+
+{"subject_type": "group", "subject_id": "eng", "subject_relation": "member", "resource_type": "document", "resource_id": "readme", "relationship": "viewer", "caveat_name": "ip_allowlist", "caveat_context": "{\"cidr\": \"10.0.0.0/8\"}"}
+`), "ndjson")
+	if err != nil {
+		t.Fatalf("failed to read ndjson: %v", err)
+	}
+
+	if !reflect.DeepEqual(fromNDJSON, expected) {
+		t.Errorf("expected %v, got %v", expected, fromNDJSON)
+	}
+}
+
+// collectRelationships reads all of the relationships of r.
+func collectRelationships(r *strings.Reader, format string) ([]Relationship, error) {
+	var relationships []Relationship
+	err := readRelationships(r, format, func(relationship Relationship) error {
+		relationships = append(relationships, relationship)
+		return nil
+	})
+
+	return relationships, err
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	authorizerpb "github.com/jon-whit/feldera-rebac/protos/gen/go/authorizer/v1alpha1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// relationshipsRule is the rule of a relationship of the relationships
+	// table.
+	relationshipsRule = "relationships"
+
+	// usersetRule is the rule of the relationship of a member of a userset to
+	// the resource the userset has a relationship to.
+	usersetRule = "userset"
+)
+
+// Derivation explains a derived relationship by the rule which derived it and
+// the relationships it was derived from.
+type Derivation struct {
+	Relationship Relationship
+
+	// Rule is relationshipsRule, usersetRule or the row of the rule table
+	// which derived the relationship (e.g. "unary_rules('document', 'viewer',
+	// 'can_view')"). It is empty if the relationship could not be explained.
+	Rule string
+
+	// Prerequisites are the derivations of the relationships the rule was
+	// applied to: the relationship of the subject to the userset and the
+	// relationship to the userset for usersetRule, the relationship of the
+	// subject for a unary rule, the first and second relationship for a binary
+	// rule, and the first relationship for a negated binary rule.
+	Prerequisites []*Derivation
+}
+
+// derivedRelationships returns the relationships of the derivations.
+func derivedRelationships(derivations []*Derivation) []Relationship {
+	derived := make([]Relationship, 0, len(derivations))
+	for _, d := range derivations {
+		derived = append(derived, d.Relationship)
+	}
+
+	return derived
+}
+
+// derivationStore is implemented by stores which know how their relationships
+// were derived, such as the memoryStore of the relationships evaluated in dev
+// mode.
+type derivationStore interface {
+
+	// Derivation returns the derivation of the relationship, or nil if it
+	// isn't known.
+	Derivation(r Relationship) *Derivation
+}
+
+// maxExplainVisits bounds the relationships explain visits to explain a
+// derived relationship, which is left unexplained beyond it.
+const maxExplainVisits = 10000
+
+// derivation returns the derivation of the derived relationship r. Unless the
+// store knows it, it is matched against the relationships in the store.
+func (s *authorizerServer) derivation(ctx context.Context, r Relationship) (*Derivation, error) {
+	if store, ok := s.store.(derivationStore); ok {
+		if d := store.Derivation(r); d != nil {
+			return d, nil
+		}
+	}
+
+	e := &explanation{store: s.store, rules: s.rules, visiting: map[string]bool{}, explained: map[derivedKey]*Derivation{}}
+	d, err := e.explain(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	if d == nil {
+		return &Derivation{Relationship: r}, nil
+	}
+
+	return d, nil
+}
+
+// explanation is the state of the explanation of a derived relationship by
+// the relationships in the store.
+type explanation struct {
+	store PermissionStore
+	rules SchemaQueryRules
+
+	// visiting holds the relationships being explained, which can't be
+	// prerequisites of the relationship.
+	visiting map[string]bool
+
+	// explained holds the derivations of the relationships explained so far,
+	// or nil for those which can't be explained. A relationship which couldn't
+	// be explained because one of its prerequisites was being explained isn't
+	// held, since it may be explained once that one is.
+	explained map[derivedKey]*Derivation
+
+	// cut counts the relationships which couldn't be explained because they
+	// were being explained, and visits counts those visited.
+	cut, visits int
+}
+
+// explain finds a derivation of the derived relationship r from the rules and
+// the relationships in the store. The store doesn't hold the relationships of
+// the relationships table separately, so a relationship the type restrictions
+// allow is taken to be one of them only if it can't be derived otherwise. It
+// returns nil if r can't be explained, e.g. while the pipeline hasn't yet
+// written its prerequisites, or once maxExplainVisits relationships have been
+// visited.
+func (e *explanation) explain(ctx context.Context, r Relationship) (*Derivation, error) {
+	if d, ok := e.explained[keyOf(r)]; ok {
+		return d, nil
+	}
+
+	key := fmt.Sprintf("%s:%s#%s@%s:%s#%s", r.ResourceType, r.ResourceID, r.Relation, r.SubjectType, r.SubjectID, r.SubjectRelation)
+
+	if e.visiting[key] || e.visits >= maxExplainVisits {
+		e.cut++
+		return nil, nil
+	}
+
+	e.visits++
+	e.visiting[key] = true
+	defer delete(e.visiting, key)
+
+	cut := e.cut
+	d, err := e.derive(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	if d != nil || e.cut == cut {
+		e.explained[keyOf(r)] = d
+	}
+
+	return d, nil
+}
+
+// derive finds a derivation of r by any of the rules.
+func (e *explanation) derive(ctx context.Context, r Relationship) (*Derivation, error) {
+	usersets, err := e.store.ResourceRelationships(ctx, r.ResourceType, []string{r.ResourceID}, r.Relation)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, userset := range usersets[r.ResourceID] {
+		if userset.SubjectRelation == "" || !allowed(e.rules, userset) {
+			continue
+		}
+
+		member, err := e.explainPrerequisite(ctx, r, userset.SubjectType, userset.SubjectID, userset.SubjectRelation)
+		if err != nil {
+			return nil, err
+		}
+
+		if member != nil {
+			return &Derivation{
+				Relationship:  r,
+				Rule:          usersetRule,
+				Prerequisites: []*Derivation{member, {Relationship: userset, Rule: relationshipsRule}},
+			}, nil
+		}
+	}
+
+	for _, rule := range e.rules.UnaryRules {
+		if rule.ResourceType != r.ResourceType || rule.DerivedRelation != r.Relation {
+			continue
+		}
+
+		source, err := e.explainPrerequisite(ctx, r, r.ResourceType, r.ResourceID, rule.SourceRelation)
+		if err != nil {
+			return nil, err
+		}
+
+		if source != nil {
+			return &Derivation{Relationship: r, Rule: rule.String(), Prerequisites: []*Derivation{source}}, nil
+		}
+	}
+
+	for _, rule := range e.rules.BinaryRules {
+		if rule.SecondResourceType != r.ResourceType || rule.DerivedRelation != r.Relation {
+			continue
+		}
+
+		var d *Derivation
+		var err error
+		switch rule.Kind {
+		case ArrowRule:
+			d, err = e.explainArrow(ctx, rule, r)
+		case IntersectionRule:
+			d, err = e.explainIntersection(ctx, rule, r)
+		}
+		if err != nil || d != nil {
+			return d, err
+		}
+	}
+
+	for _, rule := range e.rules.NegatedBinaryRules {
+		if rule.FirstResourceType != r.ResourceType || rule.DerivedRelation != r.Relation {
+			continue
+		}
+
+		first, err := e.explainPrerequisite(ctx, r, r.ResourceType, r.ResourceID, rule.FirstRelation)
+		if err != nil {
+			return nil, err
+		}
+
+		if first != nil {
+			return &Derivation{Relationship: r, Rule: rule.String(), Prerequisites: []*Derivation{first}}, nil
+		}
+	}
+
+	if allowed(e.rules, r) {
+		return &Derivation{Relationship: r, Rule: relationshipsRule}, nil
+	}
+
+	return nil, nil
+}
+
+// explainPrerequisite finds a derivation of a relationship of the subject of r
+// with the relation to the resource.
+func (e *explanation) explainPrerequisite(ctx context.Context, r Relationship, resourceType, resourceID, relation string) (*Derivation, error) {
+	relationships, err := e.store.DerivedRelationships(ctx, resourceType, []string{resourceID}, relation, r.SubjectType, r.SubjectRelation, []string{r.SubjectID})
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := relationships[resourceID]
+	for _, candidate := range candidates {
+		if candidate.Expired(time.Now()) {
+			continue
+		}
+
+		d, err := e.explain(ctx, candidate)
+		if err != nil || d != nil {
+			return d, err
+		}
+	}
+
+	return nil, nil
+}
+
+// explainArrow finds a derivation of r by the arrow rule from the relationship
+// of the subject of r to the subject of a second relationship to the resource
+// of r (e.g. the parent folder).
+func (e *explanation) explainArrow(ctx context.Context, rule BinaryRule, r Relationship) (*Derivation, error) {
+	seconds, err := e.store.ResourceRelationships(ctx, r.ResourceType, []string{r.ResourceID}, rule.SecondRelation)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, second := range seconds[r.ResourceID] {
+		if second.SubjectType != rule.FirstResourceType || second.SubjectRelation != "" || second.Expired(time.Now()) {
+			continue
+		}
+
+		first, err := e.explainPrerequisite(ctx, r, second.SubjectType, second.SubjectID, rule.FirstRelation)
+		if err != nil {
+			return nil, err
+		}
+		if first == nil {
+			continue
+		}
+
+		secondDerivation, err := e.explain(ctx, second)
+		if err != nil {
+			return nil, err
+		}
+		if secondDerivation == nil {
+			continue
+		}
+
+		return &Derivation{Relationship: r, Rule: rule.String(), Prerequisites: []*Derivation{first, secondDerivation}}, nil
+	}
+
+	return nil, nil
+}
+
+// explainIntersection finds a derivation of r by the intersection rule from
+// both relationships of the subject of r, or of the wildcard subject, to the
+// resource of r.
+func (e *explanation) explainIntersection(ctx context.Context, rule BinaryRule, r Relationship) (*Derivation, error) {
+	subjects := []Relationship{r}
+	if r.SubjectID != wildcardSubjectID {
+		wildcard := r
+		wildcard.SubjectID = wildcardSubjectID
+		subjects = append(subjects, wildcard)
+	}
+
+	var prerequisites []*Derivation
+	for _, relation := range []string{rule.FirstRelation, rule.SecondRelation} {
+		var prerequisite *Derivation
+		for _, subject := range subjects {
+			d, err := e.explainPrerequisite(ctx, subject, r.ResourceType, r.ResourceID, relation)
+			if err != nil {
+				return nil, err
+			}
+
+			if d != nil {
+				prerequisite = d
+				break
+			}
+		}
+
+		if prerequisite == nil {
+			return nil, nil
+		}
+
+		prerequisites = append(prerequisites, prerequisite)
+	}
+
+	return &Derivation{Relationship: r, Rule: rule.String(), Prerequisites: prerequisites}, nil
+}
+
+func derivationToProto(d *Derivation) *authorizerpb.Derivation {
+	derivation := &authorizerpb.Derivation{Relationship: relationshipToProto(d.Relationship), Rule: d.Rule}
+	for _, prerequisite := range d.Prerequisites {
+		derivation.Prerequisites = append(derivation.Prerequisites, derivationToProto(prerequisite))
+	}
+
+	return derivation
+}
+
+func relationshipToProto(r Relationship) *authorizerpb.DerivedRelationship {
+	relationship := &authorizerpb.DerivedRelationship{
+		ResourceType:    r.ResourceType,
+		ResourceId:      r.ResourceID,
+		Relation:        r.Relation,
+		SubjectType:     r.SubjectType,
+		SubjectId:       r.SubjectID,
+		SubjectRelation: r.SubjectRelation,
+		Caveats:         r.Caveats,
+	}
+	if r.ExpiresAt != nil {
+		relationship.ExpiresAt = timestamppb.New(r.ExpiresAt.Time)
+	}
+
+	return relationship
+}
+
+// allowed returns true if the type restrictions allow r as a relationship of
+// the relationships table.
+func allowed(rules SchemaQueryRules, r Relationship) bool {
+	for _, restriction := range rules.RelationTypeRestrictions {
+		if restriction.ResourceType == r.ResourceType && restriction.Relation == r.Relation &&
+			restriction.SubjectType == r.SubjectType && restriction.SubjectRelation == r.SubjectRelation &&
+			restriction.Wildcard == (r.SubjectID == wildcardSubjectID) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	authorizerpb "github.com/jon-whit/feldera-rebac/protos/gen/go/authorizer/v1alpha1"
+)
+
+// derivationString returns the derivation with a line for each relationship,
+// indented under the relationship derived from it.
+func derivationString(d *authorizerpb.Derivation) string {
+	var b strings.Builder
+
+	var write func(d *authorizerpb.Derivation, indent string)
+	write = func(d *authorizerpb.Derivation, indent string) {
+		r := d.GetRelationship()
+		subject := r.GetSubjectType() + ":" + r.GetSubjectId()
+		if r.GetSubjectRelation() != "" {
+			subject += "#" + r.GetSubjectRelation()
+		}
+
+		fmt.Fprintf(&b, "%s%s:%s#%s@%s %s\n", indent, r.GetResourceType(), r.GetResourceId(), r.GetRelation(), subject, d.GetRule())
+		for _, prerequisite := range d.GetPrerequisites() {
+			write(prerequisite, indent+"  ")
+		}
+	}
+
+	write(d, "")
+	return b.String()
+}
+
+func TestCheck_Debug(t *testing.T) {
+	tests := []struct {
+		name          string
+		schemaPath    string
+		relationships []Relationship
+		expected      string
+	}{
+		{
+			name:       "nested groups",
+			schemaPath: "examples/nested-groups/schema.json",
+			relationships: []Relationship{
+				{SubjectType: "user", SubjectID: "jon", ResourceType: "group", ResourceID: "eng", Relation: "member"},
+				{SubjectType: "group", SubjectID: "eng", SubjectRelation: "member", ResourceType: "group", ResourceID: "all", Relation: "member"},
+				{SubjectType: "group", SubjectID: "all", SubjectRelation: "member", ResourceType: "document", ResourceID: "readme", Relation: "viewer"},
+			},
+			expected: `document:readme#can_view@user:jon unary_rules('document', 'viewer', 'can_view')
+  document:readme#viewer@user:jon userset
+    group:all#member@user:jon userset
+      group:eng#member@user:jon relationships
+      group:all#member@group:eng#member relationships
+    document:readme#viewer@group:all#member relationships
+`,
+		},
+		{
+			name:       "hierarchy",
+			schemaPath: "examples/hierarchical-relationships/schema.json",
+			relationships: []Relationship{
+				{SubjectType: "user", SubjectID: "jon", ResourceType: "folder", ResourceID: "root", Relation: "viewer"},
+				{SubjectType: "folder", SubjectID: "root", ResourceType: "folder", ResourceID: "docs", Relation: "parent"},
+				{SubjectType: "folder", SubjectID: "docs", ResourceType: "document", ResourceID: "readme", Relation: "parent"},
+			},
+			expected: `document:readme#can_view@user:jon binary_rules('folder', 'can_view', 'document', 'parent', 'can_view', 'arrow')
+  folder:docs#can_view@user:jon binary_rules('folder', 'can_view', 'folder', 'parent', 'can_view', 'arrow')
+    folder:root#can_view@user:jon unary_rules('folder', 'viewer', 'can_view')
+      folder:root#viewer@user:jon relationships
+    folder:docs#parent@folder:root relationships
+  document:readme#parent@folder:docs relationships
+`,
+		},
+		{
+			name:       "intersection",
+			schemaPath: "examples/intersection/schema.json",
+			relationships: []Relationship{
+				{SubjectType: "user", SubjectID: "jon", ResourceType: "document", ResourceID: "readme", Relation: "viewer"},
+				{SubjectType: "user", SubjectID: "jon", ResourceType: "document", ResourceID: "readme", Relation: "allowed"},
+				{SubjectType: "user", SubjectID: "bob", ResourceType: "document", ResourceID: "readme", Relation: "allowed"},
+			},
+			expected: `document:readme#can_view@user:jon binary_rules('document', 'viewer', 'document', 'allowed', 'can_view', 'intersection')
+  document:readme#viewer@user:jon relationships
+  document:readme#allowed@user:jon relationships
+`,
+		},
+		{
+			name:       "exclusion",
+			schemaPath: "examples/exclusion/schema.json",
+			relationships: []Relationship{
+				{SubjectType: "user", SubjectID: "jon", ResourceType: "document", ResourceID: "readme", Relation: "viewer"},
+				{SubjectType: "user", SubjectID: "bob", ResourceType: "document", ResourceID: "readme", Relation: "banned"},
+			},
+			expected: `document:readme#can_view@user:jon negated_binary_rules('document', 'viewer', 'document', 'banned', 'can_view', 1)
+  document:readme#viewer@user:jon relationships
+`,
+		},
+	}
+
+	for _, test := range tests {
+		schema, err := loadSchema(test.schemaPath)
+		if err != nil {
+			t.Fatalf("failed to load schema: %v", err)
+		}
+
+		rules := mustMapSchemaToQueryRules(t, schema)
+		derivations, err := EvaluateDerivations(rules, test.relationships, time.Now())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		materialized := newTestServer(t, derivedRelationships(derivations))
+		materialized.schema = schema
+		materialized.rules = rules
+
+		// the evaluator records the derivations, which are otherwise matched
+		// against the derived relationships in Redis
+		for name, server := range map[string]*authorizerServer{
+			"evaluator":    {store: newDerivationMemoryStore(derivations), schema: schema, rules: rules},
+			"materialized": materialized,
+		} {
+			t.Run(test.name+"/"+name, func(t *testing.T) {
+				resp, err := server.Check(context.Background(), &authorizerpb.CheckRequest{
+					ResourceType: "document",
+					ResourceIds:  []string{"readme"},
+					Relation:     "can_view",
+					SubjectType:  "user",
+					SubjectId:    "jon",
+					Debug:        true,
+				})
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				derivations := resp.GetResultsByResourceId()["readme"].GetDerivations()
+				if len(derivations) != 1 {
+					t.Fatalf("expected 1 derivation, got %d", len(derivations))
+				}
+
+				if got := derivationString(derivations[0]); got != test.expected {
+					t.Errorf("expected\n%s\ngot\n%s", test.expected, got)
+				}
+			})
+		}
+	}
+}
+
+func TestCheck_DebugDisabled(t *testing.T) {
+	server := newTestServer(t, []Relationship{
+		{SubjectType: "user", SubjectID: "jon", ResourceType: "document", ResourceID: "1", Relation: "viewer"},
+	})
+
+	resp, err := server.Check(context.Background(), &authorizerpb.CheckRequest{
+		ResourceType: "document",
+		ResourceIds:  []string{"1"},
+		Relation:     "viewer",
+		SubjectType:  "user",
+		SubjectId:    "jon",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result := resp.GetResultsByResourceId()["1"]; !result.GetHasRelation() || len(result.GetDerivations()) > 0 {
+		t.Errorf("expected the relation without derivations, got %v", result)
+	}
+}
+
+// countingStore counts the lookups of the relationships explain makes.
+type countingStore struct {
+	PermissionStore
+	lookups int
+}
+
+func (s *countingStore) DerivedRelationships(ctx context.Context, resourceType string, resourceIDs []string, relation, subjectType, subjectRelation string, subjectIDs []string) (map[string][]Relationship, error) {
+	s.lookups++
+	return s.PermissionStore.DerivedRelationships(ctx, resourceType, resourceIDs, relation, subjectType, subjectRelation, subjectIDs)
+}
+
+func (s *countingStore) ResourceRelationships(ctx context.Context, resourceType string, resourceIDs []string, relation string) (map[string][]Relationship, error) {
+	s.lookups++
+	return s.PermissionStore.ResourceRelationships(ctx, resourceType, resourceIDs, relation)
+}
+
+func TestExplain_Memoized(t *testing.T) {
+	// groups only have the members of teams and other groups
+	rules := SchemaQueryRules{
+		RelationTypeRestrictions: []RelationTypeRestriction{
+			{ResourceType: "team", Relation: "member", SubjectType: "user"},
+			{ResourceType: "group", Relation: "member", SubjectType: "team", SubjectRelation: "member"},
+			{ResourceType: "group", Relation: "member", SubjectType: "group", SubjectRelation: "member"},
+			{ResourceType: "document", Relation: "viewer", SubjectType: "group", SubjectRelation: "member"},
+		},
+		UnaryRules: []UnaryRule{
+			{ResourceType: "document", SourceRelation: "viewer", DerivedRelation: "can_view"},
+		},
+	}
+
+	// layers of two groups, each of which has both groups of the layer below
+	// as members, so there are 2^layers paths from user:jon to the document
+	const layers = 16
+	relationships := []Relationship{
+		{SubjectType: "user", SubjectID: "jon", ResourceType: "team", ResourceID: "eng", Relation: "member"},
+		{SubjectType: "team", SubjectID: "eng", SubjectRelation: "member", ResourceType: "group", ResourceID: "0a", Relation: "member"},
+		{SubjectType: "team", SubjectID: "eng", SubjectRelation: "member", ResourceType: "group", ResourceID: "0b", Relation: "member"},
+	}
+	for layer := 1; layer < layers; layer++ {
+		for _, group := range []string{"a", "b"} {
+			for _, member := range []string{"a", "b"} {
+				relationships = append(relationships, Relationship{
+					SubjectType: "group", SubjectID: fmt.Sprintf("%d%s", layer-1, member), SubjectRelation: "member",
+					ResourceType: "group", ResourceID: fmt.Sprintf("%d%s", layer, group), Relation: "member",
+				})
+			}
+		}
+	}
+
+	relationships = append(relationships, Relationship{
+		SubjectType: "group", SubjectID: fmt.Sprintf("%da", layers-1), SubjectRelation: "member",
+		ResourceType: "document", ResourceID: "readme", Relation: "viewer",
+	})
+
+	derived, err := Evaluate(rules, relationships, time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the relationships of the team to the groups of the bottom layer haven't
+	// reached the store yet, so no path explains the permission
+	var lagging []Relationship
+	for _, r := range derived {
+		if r.SubjectType != "team" || r.ResourceType != "group" {
+			lagging = append(lagging, r)
+		}
+	}
+
+	store := &countingStore{PermissionStore: newMemoryStore(lagging)}
+	server := &authorizerServer{store: store, rules: rules}
+
+	d, err := server.derivation(context.Background(), Relationship{SubjectType: "user", SubjectID: "jon", ResourceType: "document", ResourceID: "readme", Relation: "can_view", Caveats: []string{}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if d.Rule != "" {
+		t.Errorf("expected the relationship to be unexplained, got the rule '%s'", d.Rule)
+	}
+
+	// each relationship is explained once, rather than once for each path
+	if store.lookups > 64*layers {
+		t.Errorf("expected at most %d lookups, got %d", 64*layers, store.lookups)
+	}
+}
+
+func TestExplain_VisitBound(t *testing.T) {
+	store := &countingStore{PermissionStore: newMemoryStore(nil)}
+	e := &explanation{store: store, visiting: map[string]bool{}, explained: map[derivedKey]*Derivation{}, visits: maxExplainVisits}
+
+	d, err := e.explain(context.Background(), Relationship{SubjectType: "user", SubjectID: "jon", ResourceType: "document", ResourceID: "readme", Relation: "can_view"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if d != nil || store.lookups > 0 {
+		t.Errorf("expected no derivation and no lookups beyond the visit bound, got %v after %d lookups", d, store.lookups)
+	}
+}
@@ -0,0 +1,557 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/authzed/spicedb/pkg/development"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	devinterface "github.com/authzed/spicedb/pkg/proto/developer/v1"
+	v1dispatch "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+	authorizerpb "github.com/jon-whit/feldera-rebac/protos/gen/go/authorizer/v1alpha1"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	differentialIterations    = flag.Int("differential.iterations", 100, "The number of random schemas and relationships to compare against SpiceDB")
+	differentialSeed          = flag.Uint64("differential.seed", 0, "The seed of the random schemas and relationships (0 picks one)")
+	differentialArrows        = flag.Bool("differential.arrows", true, "Generate arrows (e.g. 'parent->viewer')")
+	differentialIntersections = flag.Bool("differential.intersections", true, "Generate intersections (e.g. 'viewer & allowed')")
+	differentialExclusions    = flag.Bool("differential.exclusions", true, "Generate exclusions (e.g. 'viewer - banned')")
+)
+
+// differentialOptions are the kinds of expressions to generate besides
+// relations and unions.
+type differentialOptions struct {
+	arrows, intersections, exclusions bool
+}
+
+var (
+	differentialObjectIDs = []string{"a", "b", "c"}
+	differentialUserIDs   = []string{"u0", "u1", "u2"}
+)
+
+// differentialCase is a schema and relationships to compare the evaluation of
+// against SpiceDB.
+type differentialCase struct {
+	schema        *authorizerpb.Schema
+	relationships []Relationship
+}
+
+func (c differentialCase) String() string {
+	var sb strings.Builder
+	sb.WriteString(schemaToZed(c.schema))
+	sb.WriteString("\n")
+	for _, r := range c.relationships {
+		sb.WriteString(r.String() + "\n")
+	}
+
+	return sb.String()
+}
+
+// differentialMismatch is a check the evaluator and SpiceDB disagree on.
+type differentialMismatch struct {
+	check     string
+	spicedb   bool
+	evaluator bool
+	err       error
+}
+
+func (m differentialMismatch) String() string {
+	if m.err != nil {
+		return fmt.Sprintf("evaluation failed: %v", m.err)
+	}
+
+	return fmt.Sprintf("%s: spicedb=%t, evaluator=%t", m.check, m.spicedb, m.evaluator)
+}
+
+// TestDifferential compares the relationships derived by Evaluate with the
+// checks of SpiceDB's development engine for random schemas and relationships.
+// Mismatches are shrunk to a minimal schema and set of relationships.
+//
+// Arrows, intersections and exclusions can each be left out of the generated
+// schemas with the -differential.arrows, -differential.intersections and
+// -differential.exclusions flags. Schemas the compiler rejects because an
+// exclusion may exclude subjects from the wildcard subject aren't compared,
+// but every exclusion of wildcard subjects it accepts is.
+func TestDifferential(t *testing.T) {
+	seed := *differentialSeed
+	if seed == 0 {
+		seed = uint64(time.Now().UnixNano())
+	}
+
+	rng := rand.New(rand.NewPCG(seed, seed))
+
+	var rejected int
+	for i := 0; i < *differentialIterations; i++ {
+		c := generateDifferentialCase(rng, differentialOptions{
+			arrows:        *differentialArrows,
+			intersections: *differentialIntersections,
+			exclusions:    *differentialExclusions,
+		})
+
+		if _, err := mapSchemaToQueryRules(c.schema); errors.Is(err, errWildcardExclusion) {
+			rejected++
+			continue
+		}
+
+		m, err := compareDifferentialCase(c)
+		if err != nil {
+			t.Fatalf("invalid case (seed %d):\n%s\n%v", seed, c, err)
+		}
+
+		if m != nil {
+			c, m = shrinkDifferentialCase(c, m)
+			t.Fatalf("mismatch (seed %d): %s\n\n%s", seed, m, c)
+		}
+	}
+
+	t.Logf("compared %d cases, and %d rejected for exclusions of wildcard subjects (seed %d)", *differentialIterations-rejected, rejected, seed)
+}
+
+// generateDifferentialCase generates a random schema and relationships which
+// are valid under it. The relationships are acyclic, since SpiceDB fails
+// checks which exceed its maximum depth.
+func generateDifferentialCase(rng *rand.Rand, opts differentialOptions) differentialCase {
+	schema := &authorizerpb.Schema{
+		TypeDefinitions: map[string]*authorizerpb.TypeDefinition{
+			"user": {Name: "user"},
+		},
+	}
+
+	// SpiceDB rejects usersets of relations which include wildcards, either
+	// directly or through other usersets
+	includesWildcard := map[string]bool{}
+
+	numTypes := 1 + rng.IntN(3)
+	for i := 0; i < numTypes; i++ {
+		typedef := &authorizerpb.TypeDefinition{
+			Name:        fmt.Sprintf("res%d", i),
+			Relations:   map[string]*authorizerpb.Relation{},
+			Permissions: map[string]*authorizerpb.Permission{},
+		}
+		schema.TypeDefinitions[typedef.GetName()] = typedef
+
+		numRelations := 1 + rng.IntN(3)
+		for j := 0; j < numRelations; j++ {
+			candidates := []*authorizerpb.RelationTypeRestriction{
+				{ResourceType: "user"},
+				{ResourceType: "user", Wildcard: true},
+			}
+			for k := 0; k <= i; k++ {
+				other := schema.GetTypeDefinitions()[fmt.Sprintf("res%d", k)]
+				for _, relation := range slices.Sorted(maps.Keys(other.GetRelations())) {
+					if relation != "parent" && !includesWildcard[other.GetName()+"#"+relation] {
+						candidates = append(candidates, &authorizerpb.RelationTypeRestriction{ResourceType: other.GetName(), Relation: relation})
+					}
+				}
+			}
+
+			relation := &authorizerpb.Relation{Name: fmt.Sprintf("rel%d", j)}
+			for _, index := range rng.Perm(len(candidates))[:1+rng.IntN(min(3, len(candidates)))] {
+				restriction := candidates[index]
+				relation.TypeRestrictions = append(relation.TypeRestrictions, restriction)
+
+				if restriction.GetWildcard() {
+					includesWildcard[typedef.GetName()+"#"+relation.GetName()] = true
+				}
+			}
+
+			typedef.Relations[relation.GetName()] = relation
+		}
+
+		if opts.arrows && i > 0 && rng.IntN(2) == 0 {
+			typedef.Relations["parent"] = &authorizerpb.Relation{
+				Name:             "parent",
+				TypeRestrictions: []*authorizerpb.RelationTypeRestriction{{ResourceType: fmt.Sprintf("res%d", rng.IntN(i))}},
+			}
+		}
+
+		numPermissions := 1 + rng.IntN(2)
+		for j := 0; j < numPermissions; j++ {
+			name := fmt.Sprintf("perm%d", j)
+			typedef.Permissions[name] = &authorizerpb.Permission{
+				Name:       name,
+				Expression: generatePermissionExpression(rng, schema, typedef, opts),
+			}
+		}
+	}
+
+	seen := map[string]struct{}{}
+	var relationships []Relationship
+	for n, attempts := rng.IntN(12), 0; len(relationships) < n && attempts < 100; attempts++ {
+		typedef := schema.GetTypeDefinitions()[fmt.Sprintf("res%d", rng.IntN(numTypes))]
+		relations := slices.Sorted(maps.Keys(typedef.GetRelations()))
+		relation := typedef.GetRelations()[relations[rng.IntN(len(relations))]]
+		restriction := relation.GetTypeRestrictions()[rng.IntN(len(relation.GetTypeRestrictions()))]
+
+		resourceIndex := rng.IntN(len(differentialObjectIDs))
+		r := Relationship{
+			ResourceType:    typedef.GetName(),
+			ResourceID:      differentialObjectIDs[resourceIndex],
+			Relation:        relation.GetName(),
+			SubjectType:     restriction.GetResourceType(),
+			SubjectRelation: restriction.GetRelation(),
+		}
+
+		switch {
+		case restriction.GetWildcard():
+			r.SubjectID = wildcardSubjectID
+		case restriction.GetResourceType() == "user":
+			r.SubjectID = differentialUserIDs[rng.IntN(len(differentialUserIDs))]
+		case restriction.GetResourceType() == typedef.GetName():
+			// usersets of the same type only reference objects before the
+			// resource, so the relationships are acyclic
+			if resourceIndex == 0 {
+				continue
+			}
+
+			r.SubjectID = differentialObjectIDs[rng.IntN(resourceIndex)]
+		default:
+			r.SubjectID = differentialObjectIDs[rng.IntN(len(differentialObjectIDs))]
+		}
+
+		if _, ok := seen[r.String()]; ok {
+			continue
+		}
+
+		seen[r.String()] = struct{}{}
+		relationships = append(relationships, r)
+	}
+
+	return differentialCase{schema: schema, relationships: relationships}
+}
+
+// generatePermissionExpression generates an expression over the relations and
+// previously generated permissions of the type definition.
+func generatePermissionExpression(
+	rng *rand.Rand,
+	schema *authorizerpb.Schema,
+	typedef *authorizerpb.TypeDefinition,
+	opts differentialOptions,
+) *authorizerpb.PermissionExpressionRef {
+	refs := slices.Concat(slices.Sorted(maps.Keys(typedef.GetRelations())), slices.Sorted(maps.Keys(typedef.GetPermissions())))
+
+	operand := func() *authorizerpb.PermissionExpressionRef {
+		if parent, ok := typedef.GetRelations()["parent"]; ok && rng.IntN(3) == 0 {
+			target := schema.GetTypeDefinitions()[parent.GetTypeRestrictions()[0].GetResourceType()]
+			targets := slices.Concat(slices.Sorted(maps.Keys(target.GetRelations())), slices.Sorted(maps.Keys(target.GetPermissions())))
+
+			return &authorizerpb.PermissionExpressionRef{
+				Expression: &authorizerpb.PermissionExpressionRef_HierarchicalExpression{
+					HierarchicalExpression: &authorizerpb.HierarchicalPermissionExpression{
+						Base:   "parent",
+						Target: targets[rng.IntN(len(targets))],
+					},
+				},
+			}
+		}
+
+		return &authorizerpb.PermissionExpressionRef{
+			Expression: &authorizerpb.PermissionExpressionRef_UnaryExpression{
+				UnaryExpression: &authorizerpb.UnaryPermissionExpression{SourceRelation: refs[rng.IntN(len(refs))]},
+			},
+		}
+	}
+
+	switch rng.IntN(4) {
+	case 1:
+		union := &authorizerpb.PermissionSetExpressionRef_Union{}
+		for n := 2 + rng.IntN(2); len(union.Operands) < n; {
+			union.Operands = append(union.Operands, operand())
+		}
+
+		return &authorizerpb.PermissionExpressionRef{
+			Expression: &authorizerpb.PermissionExpressionRef_SetExpression{
+				SetExpression: &authorizerpb.PermissionSetExpressionRef{
+					SetExpression: &authorizerpb.PermissionSetExpressionRef_Union_{Union: union},
+				},
+			},
+		}
+	case 2:
+		if opts.intersections {
+			return &authorizerpb.PermissionExpressionRef{
+				Expression: &authorizerpb.PermissionExpressionRef_SetExpression{
+					SetExpression: &authorizerpb.PermissionSetExpressionRef{
+						SetExpression: &authorizerpb.PermissionSetExpressionRef_Intersection_{
+							Intersection: &authorizerpb.PermissionSetExpressionRef_Intersection{
+								Operands: []*authorizerpb.PermissionExpressionRef{operand(), operand()},
+							},
+						},
+					},
+				},
+			}
+		}
+	case 3:
+		var bases []string
+		for _, relation := range slices.Sorted(maps.Keys(typedef.GetRelations())) {
+			if relation != "parent" {
+				bases = append(bases, relation)
+			}
+		}
+
+		if opts.exclusions && len(bases) > 0 {
+			unary := func(relation string) *authorizerpb.PermissionExpressionRef {
+				return &authorizerpb.PermissionExpressionRef{
+					Expression: &authorizerpb.PermissionExpressionRef_UnaryExpression{
+						UnaryExpression: &authorizerpb.UnaryPermissionExpression{SourceRelation: relation},
+					},
+				}
+			}
+
+			set := func(exp *authorizerpb.PermissionSetExpressionRef) *authorizerpb.PermissionExpressionRef {
+				return &authorizerpb.PermissionExpressionRef{
+					Expression: &authorizerpb.PermissionExpressionRef_SetExpression{SetExpression: exp},
+				}
+			}
+
+			exclusion := func(first, second *authorizerpb.PermissionExpressionRef) *authorizerpb.PermissionExpressionRef {
+				return set(&authorizerpb.PermissionSetExpressionRef{
+					SetExpression: &authorizerpb.PermissionSetExpressionRef_Exclusion_{
+						Exclusion: &authorizerpb.PermissionSetExpressionRef_Exclusion{
+							Operands: []*authorizerpb.PermissionExpressionRef{first, second},
+						},
+					},
+				})
+			}
+
+			union := func(operands ...*authorizerpb.PermissionExpressionRef) *authorizerpb.PermissionExpressionRef {
+				return set(&authorizerpb.PermissionSetExpressionRef{
+					SetExpression: &authorizerpb.PermissionSetExpressionRef_Union_{
+						Union: &authorizerpb.PermissionSetExpressionRef_Union{Operands: operands},
+					},
+				})
+			}
+
+			base := func() *authorizerpb.PermissionExpressionRef {
+				return unary(bases[rng.IntN(len(bases))])
+			}
+
+			// the operands are compound (e.g. '(viewer + editor) - banned',
+			// 'parent->viewer - banned' or 'viewer - (banned - allowed)'),
+			// and the exclusion may itself be an operand of a union
+			first, second := base(), unary(refs[rng.IntN(len(refs))])
+			switch rng.IntN(4) {
+			case 1:
+				first = union(base(), operand())
+			case 2:
+				first = operand()
+			case 3:
+				first = exclusion(base(), operand())
+			}
+
+			switch rng.IntN(3) {
+			case 1:
+				second = union(operand(), operand())
+			case 2:
+				second = exclusion(operand(), operand())
+			}
+
+			if rng.IntN(4) == 0 {
+				return union(operand(), exclusion(first, second))
+			}
+
+			return exclusion(first, second)
+		}
+	}
+
+	return operand()
+}
+
+// compareDifferentialCase checks every relation and permission of every
+// object for every user with both the evaluator and SpiceDB, and returns the
+// first check they disagree on. An error is returned if SpiceDB rejects the
+// case.
+func compareDifferentialCase(c differentialCase) (*differentialMismatch, error) {
+	tuples := make([]*core.RelationTuple, 0, len(c.relationships))
+	for _, r := range c.relationships {
+		rel, err := tuple.Parse(r.String())
+		if err != nil {
+			return nil, err
+		}
+
+		tuples = append(tuples, rel.ToCoreTuple())
+	}
+
+	devContext, devErrs, err := development.NewDevContext(context.Background(), &devinterface.RequestContext{
+		Schema:        schemaToZed(c.schema),
+		Relationships: tuples,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if devErrs != nil {
+		return nil, fmt.Errorf("%v", devErrs.GetInputErrors())
+	}
+	defer devContext.Dispose()
+
+	derived, err := evaluateDifferentialCase(c)
+	if err != nil {
+		return &differentialMismatch{err: err}, nil
+	}
+
+	holds := map[string]struct{}{}
+	for _, r := range derived {
+		if r.SubjectType == "user" && r.SubjectRelation == "" {
+			holds[r.String()] = struct{}{}
+		}
+	}
+
+	for _, typeName := range slices.Sorted(maps.Keys(c.schema.GetTypeDefinitions())) {
+		typedef := c.schema.GetTypeDefinitions()[typeName]
+		names := slices.Concat(slices.Sorted(maps.Keys(typedef.GetRelations())), slices.Sorted(maps.Keys(typedef.GetPermissions())))
+
+		for _, name := range names {
+			for _, objectID := range differentialObjectIDs {
+				for _, userID := range differentialUserIDs {
+					result, err := development.RunCheck(devContext, tuple.ONR(typeName, objectID, name), tuple.ONR("user", userID, tuple.Ellipsis), nil)
+					if err != nil {
+						return nil, err
+					}
+
+					check := fmt.Sprintf("%s:%s#%s@user:%s", typeName, objectID, name, userID)
+					_, direct := holds[check]
+					_, wildcard := holds[fmt.Sprintf("%s:%s#%s@user:%s", typeName, objectID, name, wildcardSubjectID)]
+
+					spicedb := result.Permissionship == v1dispatch.ResourceCheckResult_MEMBER
+					if evaluator := direct || wildcard; spicedb != evaluator {
+						return &differentialMismatch{check: check, spicedb: spicedb, evaluator: evaluator}, nil
+					}
+				}
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// evaluateDifferentialCase compiles the schema and evaluates the
+// relationships, returning an error if the compiler panics.
+func evaluateDifferentialCase(c differentialCase) (derived []Relationship, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to compile schema: %v", r)
+		}
+	}()
+
+	rules, err := mapSchemaToQueryRules(c.schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile schema: %w", err)
+	}
+
+	return Evaluate(rules, c.relationships, time.Now())
+}
+
+// shrinkDifferentialCase removes the relationships, permissions and relations
+// of a case with a mismatch for as long as a mismatch remains.
+func shrinkDifferentialCase(c differentialCase, m *differentialMismatch) (differentialCase, *differentialMismatch) {
+	try := func(candidate differentialCase) bool {
+		if cm, err := compareDifferentialCase(candidate); err == nil && cm != nil {
+			c, m = candidate, cm
+			return true
+		}
+
+		return false
+	}
+
+	for i := 0; i < len(c.relationships); {
+		if !try(differentialCase{schema: c.schema, relationships: slices.Delete(slices.Clone(c.relationships), i, i+1)}) {
+			i++
+		}
+	}
+
+	// SpiceDB rejects the schemas without relations or permissions which are
+	// still referenced
+	for _, typeName := range slices.Sorted(maps.Keys(c.schema.GetTypeDefinitions())) {
+		for _, name := range slices.Sorted(maps.Keys(c.schema.GetTypeDefinitions()[typeName].GetPermissions())) {
+			schema := proto.Clone(c.schema).(*authorizerpb.Schema)
+			delete(schema.GetTypeDefinitions()[typeName].GetPermissions(), name)
+			try(differentialCase{schema: schema, relationships: c.relationships})
+		}
+
+		for _, name := range slices.Sorted(maps.Keys(c.schema.GetTypeDefinitions()[typeName].GetRelations())) {
+			schema := proto.Clone(c.schema).(*authorizerpb.Schema)
+			delete(schema.GetTypeDefinitions()[typeName].GetRelations(), name)
+			try(differentialCase{schema: schema, relationships: c.relationships})
+		}
+	}
+
+	return c, m
+}
+
+// schemaToZed renders the schema in the SpiceDB schema language.
+func schemaToZed(schema *authorizerpb.Schema) string {
+	var sb strings.Builder
+	for _, typeName := range slices.Sorted(maps.Keys(schema.GetTypeDefinitions())) {
+		typedef := schema.GetTypeDefinitions()[typeName]
+		fmt.Fprintf(&sb, "definition %s {\n", typeName)
+
+		for _, name := range slices.Sorted(maps.Keys(typedef.GetRelations())) {
+			var subjects []string
+			for _, restriction := range typedef.GetRelations()[name].GetTypeRestrictions() {
+				subject := restriction.GetResourceType()
+				switch {
+				case restriction.GetWildcard():
+					subject += ":*"
+				case restriction.GetRelation() != "":
+					subject += "#" + restriction.GetRelation()
+				}
+
+				subjects = append(subjects, subject)
+			}
+
+			fmt.Fprintf(&sb, "\trelation %s: %s\n", name, strings.Join(subjects, " | "))
+		}
+
+		for _, name := range slices.Sorted(maps.Keys(typedef.GetPermissions())) {
+			fmt.Fprintf(&sb, "\tpermission %s = %s\n", name, expressionToZed(typedef.GetPermissions()[name].GetExpression()))
+		}
+
+		sb.WriteString("}\n")
+	}
+
+	return sb.String()
+}
+
+func expressionToZed(exp *authorizerpb.PermissionExpressionRef) string {
+	switch exp := exp.GetExpression().(type) {
+	case *authorizerpb.PermissionExpressionRef_UnaryExpression:
+		return exp.UnaryExpression.GetSourceRelation()
+	case *authorizerpb.PermissionExpressionRef_HierarchicalExpression:
+		return exp.HierarchicalExpression.GetBase() + "->" + exp.HierarchicalExpression.GetTarget()
+	case *authorizerpb.PermissionExpressionRef_SetExpression:
+		var operands []string
+		var operator string
+		switch set := exp.SetExpression.GetSetExpression().(type) {
+		case *authorizerpb.PermissionSetExpressionRef_Union_:
+			operator = " + "
+			for _, operand := range set.Union.GetOperands() {
+				operands = append(operands, expressionToZed(operand))
+			}
+		case *authorizerpb.PermissionSetExpressionRef_Intersection_:
+			operator = " & "
+			for _, operand := range set.Intersection.GetOperands() {
+				operands = append(operands, expressionToZed(operand))
+			}
+		case *authorizerpb.PermissionSetExpressionRef_Exclusion_:
+			operator = " - "
+			for _, operand := range set.Exclusion.GetOperands() {
+				operands = append(operands, expressionToZed(operand))
+			}
+		}
+
+		return "(" + strings.Join(operands, operator) + ")"
+	default:
+		panic(fmt.Sprintf("unexpected expression type %T", exp))
+	}
+}
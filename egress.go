@@ -0,0 +1,438 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"sync"
+	"time"
+)
+
+// egressView is the view of the pipeline an egressStore follows, which has
+// the derived relationships and the revision markers in a single change
+// stream.
+const egressView = "egress_derived_relationships"
+
+// egressRetryInterval is how long an egressStore waits to subscribe again
+// after losing a change stream.
+const egressRetryInterval = time.Second
+
+// egressRow is a row of the egress_derived_relationships view: a derived
+// relationship, or the marker of a revision if Revision is set. With a
+// maximum depth the view has a row for each depth a relationship is derived
+// at.
+type egressRow struct {
+	Relationship
+	Depth    int    `json:"depth"`
+	Revision *int64 `json:"revision"`
+}
+
+type rowKey struct {
+	derivedKey
+	depth int
+}
+
+type resourceIndexKey struct {
+	resourceType, resourceID, relation string
+}
+
+type subjectIndexKey struct {
+	subjectType, subjectID, subjectRelation string
+	relation, resourceType                  string
+}
+
+type depthLimitedKey struct {
+	subjectType, subjectID, subjectRelation string
+}
+
+// relationshipIndex holds the rows of the view an egressStore follows, with
+// the derived relationships indexed both resource-first and subject-first.
+type relationshipIndex struct {
+	rows map[rowKey]struct{}
+
+	// depths counts the rows of each derived relationship, which is indexed
+	// while it has any.
+	depths map[derivedKey]int
+
+	// relationships are the derived relationships by their resource-first key,
+	// as in a memoryStore.
+	relationships map[string]map[derivedKey]Relationship
+	byResource    map[resourceIndexKey]map[derivedKey]Relationship
+	bySubject     map[subjectIndexKey]map[derivedKey]Relationship
+
+	revisions map[int64]struct{}
+
+	// maxDepth is the maximum depth of the program, and depthLimited counts
+	// the rows of each subject at the maximum depth, whose derivations were
+	// cut off as in the depth_limited_subjects view.
+	maxDepth     int
+	depthLimited map[depthLimitedKey]int
+}
+
+func newRelationshipIndex(maxDepth int) *relationshipIndex {
+	return &relationshipIndex{
+		maxDepth:      maxDepth,
+		depthLimited:  map[depthLimitedKey]int{},
+		rows:          map[rowKey]struct{}{},
+		depths:        map[derivedKey]int{},
+		relationships: map[string]map[derivedKey]Relationship{},
+		byResource:    map[resourceIndexKey]map[derivedKey]Relationship{},
+		bySubject:     map[subjectIndexKey]map[derivedKey]Relationship{},
+		revisions:     map[int64]struct{}{},
+	}
+}
+
+// apply applies the changes of a chunk of the change stream. The revision
+// markers of the chunk are applied after its derived relationships, so that a
+// revision is never reached before the derived relationships of its step. A
+// row is either in the index or not, so applying the changes which preceded a
+// snapshot again leaves it as it was.
+func (x *relationshipIndex) apply(chunk []egressChange) error {
+	type markerChange struct {
+		revision int64
+		inserted bool
+	}
+
+	var markers []markerChange
+	for _, change := range chunk {
+		row, inserted := change.Insert, true
+		if row == nil {
+			row, inserted = change.Delete, false
+		}
+
+		if row == nil {
+			continue
+		}
+
+		var r egressRow
+		if err := json.Unmarshal(row, &r); err != nil {
+			return fmt.Errorf("failed to decode derived relationship: %w", err)
+		}
+
+		switch {
+		case r.Revision != nil:
+			markers = append(markers, markerChange{*r.Revision, inserted})
+		case inserted:
+			x.insert(r)
+		default:
+			x.delete(r)
+		}
+	}
+
+	for _, marker := range markers {
+		if marker.inserted {
+			x.revisions[marker.revision] = struct{}{}
+		} else {
+			delete(x.revisions, marker.revision)
+		}
+	}
+
+	return nil
+}
+
+func (x *relationshipIndex) insert(row egressRow) {
+	key := rowKey{keyOf(row.Relationship), row.Depth}
+	if _, ok := x.rows[key]; ok {
+		return
+	}
+
+	x.rows[key] = struct{}{}
+	if x.atMaxDepth(row) {
+		x.depthLimited[depthLimitedKey{row.SubjectType, row.SubjectID, row.SubjectRelation}]++
+	}
+
+	x.depths[key.derivedKey]++
+	if x.depths[key.derivedKey] > 1 {
+		return
+	}
+
+	r := row.Relationship
+	addEntry(x.relationships, resourceKey(r.ResourceType, r.ResourceID, r.Relation, r.SubjectType, r.SubjectRelation, r.SubjectID), key.derivedKey, r)
+	addEntry(x.byResource, resourceIndexKey{r.ResourceType, r.ResourceID, r.Relation}, key.derivedKey, r)
+	addEntry(x.bySubject, subjectIndexKey{r.SubjectType, r.SubjectID, r.SubjectRelation, r.Relation, r.ResourceType}, key.derivedKey, r)
+}
+
+func (x *relationshipIndex) delete(row egressRow) {
+	key := rowKey{keyOf(row.Relationship), row.Depth}
+	if _, ok := x.rows[key]; !ok {
+		return
+	}
+
+	delete(x.rows, key)
+	if x.atMaxDepth(row) {
+		subject := depthLimitedKey{row.SubjectType, row.SubjectID, row.SubjectRelation}
+		if x.depthLimited[subject]--; x.depthLimited[subject] == 0 {
+			delete(x.depthLimited, subject)
+		}
+	}
+
+	x.depths[key.derivedKey]--
+	if x.depths[key.derivedKey] > 0 {
+		return
+	}
+
+	delete(x.depths, key.derivedKey)
+
+	r := row.Relationship
+	removeEntry(x.relationships, resourceKey(r.ResourceType, r.ResourceID, r.Relation, r.SubjectType, r.SubjectRelation, r.SubjectID), key.derivedKey)
+	removeEntry(x.byResource, resourceIndexKey{r.ResourceType, r.ResourceID, r.Relation}, key.derivedKey)
+	removeEntry(x.bySubject, subjectIndexKey{r.SubjectType, r.SubjectID, r.SubjectRelation, r.Relation, r.ResourceType}, key.derivedKey)
+}
+
+// atMaxDepth returns true if the row is derived at the maximum depth, beyond
+// which its subject's derivations are cut off.
+func (x *relationshipIndex) atMaxDepth(row egressRow) bool {
+	return x.maxDepth > 0 && row.Depth >= x.maxDepth
+}
+
+// depthLimitReached returns true if the derivations of any of the subjects
+// were cut off at the maximum depth.
+func (x *relationshipIndex) depthLimitReached(subjectType, subjectRelation string, subjectIDs []string) bool {
+	for _, subjectID := range subjectIDs {
+		if x.depthLimited[depthLimitedKey{subjectType, subjectID, subjectRelation}] > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func addEntry[K comparable](index map[K]map[derivedKey]Relationship, key K, relationship derivedKey, r Relationship) {
+	if index[key] == nil {
+		index[key] = map[derivedKey]Relationship{}
+	}
+
+	index[key][relationship] = r
+}
+
+func removeEntry[K comparable](index map[K]map[derivedKey]Relationship, key K, relationship derivedKey) {
+	delete(index[key], relationship)
+	if len(index[key]) == 0 {
+		delete(index, key)
+	}
+}
+
+// egressStore is a PermissionStore of the derived relationships held in
+// memory, which it keeps up to date by following the change stream of a view
+// of the pipeline over HTTP, so that the authorizer doesn't need Redis.
+type egressStore struct {
+	feldera *felderaClient
+
+	// maxDepth is the maximum depth the program was generated with, which
+	// the depth of the derived relationships is compared to.
+	maxDepth int
+
+	// onReady is called when the store has loaded the snapshot of the view,
+	// and when it loses its change stream.
+	onReady func(ready bool)
+
+	mu    sync.RWMutex
+	index *relationshipIndex
+}
+
+func newEgressStore(feldera *felderaClient, maxDepth int, onReady func(ready bool)) *egressStore {
+	return &egressStore{feldera: feldera, maxDepth: maxDepth, onReady: onReady, index: newRelationshipIndex(maxDepth)}
+}
+
+// egressSync is a subscription to the change stream of the view. The chunks
+// received while the snapshot is loaded are applied once it has been.
+type egressSync struct {
+	index   *relationshipIndex
+	pending [][]egressChange
+	loaded  bool
+}
+
+// run keeps the store in sync with the pipeline until the context is
+// canceled, subscribing again whenever it loses a change stream.
+func (s *egressStore) run(ctx context.Context) {
+	for {
+		err := s.sync(ctx)
+		s.onReady(false)
+		if ctx.Err() != nil {
+			return
+		}
+
+		log.Printf("lost the change stream of the pipeline, subscribing again: %v", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(egressRetryInterval):
+		}
+	}
+}
+
+// sync subscribes to the change stream of the view, loads a snapshot of it,
+// and then applies its changes until the stream fails. The stream is
+// subscribed to before the snapshot is taken, so that no change is missed.
+func (s *egressStore) sync(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	state := &egressSync{index: newRelationshipIndex(s.maxDepth)}
+
+	stream, err := s.feldera.openEgress(ctx, egressView)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to '%s': %w", egressView, err)
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		defer stream.Close()
+		errs <- s.follow(state, stream)
+	}()
+
+	var snapshot []egressChange
+	err = s.feldera.query(ctx, "SELECT * FROM "+egressView, func(row json.RawMessage) error {
+		snapshot = append(snapshot, egressChange{Insert: row})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load the snapshot of '%s': %w", egressView, err)
+	}
+
+	if err := state.index.apply(snapshot); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	for _, chunk := range state.pending {
+		if err := state.index.apply(chunk); err != nil {
+			s.mu.Unlock()
+			return err
+		}
+	}
+
+	state.pending = nil
+	state.loaded = true
+	s.index = state.index
+	s.mu.Unlock()
+
+	log.Printf("loaded %d derived relationships from the pipeline", len(state.index.depths))
+	s.onReady(true)
+
+	return <-errs
+}
+
+// follow applies the chunks of the stream, or holds them until the snapshot
+// has been loaded.
+func (s *egressStore) follow(state *egressSync, stream *egressStream) error {
+	for {
+		chunk, err := stream.next()
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("the pipeline closed the change stream of '%s'", egressView)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		s.mu.Lock()
+		if !state.loaded {
+			state.pending = append(state.pending, chunk)
+		} else if err := state.index.apply(chunk); err != nil {
+			s.mu.Unlock()
+			return err
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (s *egressStore) DerivedRelationships(ctx context.Context, resourceType string, resourceIDs []string, relation, subjectType, subjectRelation string, subjectIDs []string) (map[string][]Relationship, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	relationships := make(map[string][]Relationship, len(resourceIDs))
+	for _, resourceID := range resourceIDs {
+		for _, subjectID := range subjectIDs {
+			for _, r := range s.index.relationships[resourceKey(resourceType, resourceID, relation, subjectType, subjectRelation, subjectID)] {
+				relationships[resourceID] = append(relationships[resourceID], r)
+			}
+		}
+	}
+
+	return relationships, nil
+}
+
+func (s *egressStore) CheckRelationships(ctx context.Context, checks []RelationshipCheck) ([]CheckedRelationships, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	checked := make([]CheckedRelationships, len(checks))
+	for i, check := range checks {
+		for _, subjectID := range check.SubjectIDs {
+			for _, r := range s.index.relationships[resourceKey(check.ResourceType, check.ResourceID, check.Relation, check.SubjectType, check.SubjectRelation, subjectID)] {
+				checked[i].Relationships = append(checked[i].Relationships, r)
+			}
+		}
+
+		checked[i].DepthLimitReached = s.index.depthLimitReached(check.SubjectType, check.SubjectRelation, check.SubjectIDs)
+	}
+
+	return checked, nil
+}
+
+func (s *egressStore) DepthLimitReached(ctx context.Context, subjectType, subjectRelation string, subjectIDs []string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.index.depthLimitReached(subjectType, subjectRelation, subjectIDs), nil
+}
+
+func (s *egressStore) LookupResourceIDs(ctx context.Context, resourceType, relation, subjectType, subjectRelation string, subjectIDs []string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var resourceIDs []string
+	for _, subjectID := range subjectIDs {
+		for _, r := range s.index.bySubject[subjectIndexKey{subjectType, subjectID, subjectRelation, relation, resourceType}] {
+			resourceIDs = append(resourceIDs, r.ResourceID)
+		}
+	}
+
+	slices.Sort(resourceIDs)
+	return slices.Compact(resourceIDs), nil
+}
+
+func (s *egressStore) LookupSubjectIDs(ctx context.Context, resourceType, resourceID, relation, subjectType, subjectRelation string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var subjectIDs []string
+	for _, r := range s.index.byResource[resourceIndexKey{resourceType, resourceID, relation}] {
+		if r.SubjectType == subjectType && r.SubjectRelation == subjectRelation {
+			subjectIDs = append(subjectIDs, r.SubjectID)
+		}
+	}
+
+	slices.Sort(subjectIDs)
+	return slices.Compact(subjectIDs), nil
+}
+
+func (s *egressStore) ResourceRelationships(ctx context.Context, resourceType string, resourceIDs []string, relation string) (map[string][]Relationship, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	relationships := make(map[string][]Relationship, len(resourceIDs))
+	for _, resourceID := range resourceIDs {
+		for _, r := range s.index.byResource[resourceIndexKey{resourceType, resourceID, relation}] {
+			relationships[resourceID] = append(relationships[resourceID], r)
+		}
+
+		slices.SortFunc(relationships[resourceID], compareRelationships)
+	}
+
+	return relationships, nil
+}
+
+func (s *egressStore) RevisionReached(ctx context.Context, revision int64) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.index.revisions[revision]
+	return ok, nil
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	authorizerpb "github.com/jon-whit/feldera-rebac/protos/gen/go/authorizer/v1alpha1"
+)
+
+// testPipeline is a stand-in for the pipeline manager, which answers the
+// snapshot query of the view with its rows and streams the chunks sent on its
+// channel from the egress endpoint of the view.
+type testPipeline struct {
+	snapshot []string
+	chunks   chan string
+
+	// subscribed is closed once the change stream of the view has been
+	// subscribed to.
+	subscribed chan struct{}
+}
+
+func newTestPipeline(t *testing.T, snapshot ...string) (*testPipeline, *felderaClient) {
+	t.Helper()
+
+	p := &testPipeline{snapshot: snapshot, chunks: make(chan string, 16), subscribed: make(chan struct{})}
+
+	var subscribed sync.Once
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v0/pipelines/rebac/query" {
+			if r.URL.Query().Get("sql") != "SELECT * FROM "+egressView {
+				http.Error(w, "unknown query", http.StatusBadRequest)
+				return
+			}
+
+			for _, row := range p.snapshot {
+				fmt.Fprintln(w, row)
+			}
+			return
+		}
+
+		if r.URL.Path != "/v0/pipelines/rebac/egress/"+egressView {
+			http.Error(w, "unknown view", http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		subscribed.Do(func() { close(p.subscribed) })
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case chunk := <-p.chunks:
+				fmt.Fprintln(w, chunk)
+				w.(http.Flusher).Flush()
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return p, &felderaClient{httpClient: server.Client(), baseURL: server.URL, pipeline: "rebac"}
+}
+
+func derivedRow(subjectID, resourceID string, depth int) string {
+	return fmt.Sprintf(`{"subject_type": "user", "subject_id": "%s", "subject_relation": "", "resource_type": "document", "resource_id": "%s", "relationship": "can_view", "caveats": [], "expires_at": null, "depth": %d, "revision": null}`, subjectID, resourceID, depth)
+}
+
+func markerRow(revision int64) string {
+	return fmt.Sprintf(`{"subject_type": "", "subject_id": "", "subject_relation": "", "resource_type": "", "resource_id": "", "relationship": "", "caveats": null, "expires_at": null, "depth": 0, "revision": %d}`, revision)
+}
+
+// eventually fails the test unless the condition holds within a second.
+func eventually(t *testing.T, condition func() bool, format string, args ...any) {
+	t.Helper()
+
+	for deadline := time.Now().Add(time.Second); !condition(); {
+		if time.Now().After(deadline) {
+			t.Fatalf(format, args...)
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestEgressStore(t *testing.T) {
+	pipeline, feldera := newTestPipeline(t, derivedRow("jon", "1", 1), derivedRow("bob", "1", 1), markerRow(42))
+
+	ready := make(chan bool, 8)
+	store := newEgressStore(feldera, 0, func(r bool) { ready <- r })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		store.run(ctx)
+		close(done)
+	}()
+
+	// a change which may reach the store while it loads the snapshot
+	<-pipeline.subscribed
+	pipeline.chunks <- fmt.Sprintf(`{"sequence_number": 0, "json_data": [{"delete": %s}, {"insert": %s}]}`, derivedRow("bob", "1", 1), derivedRow("alice", "2", 1))
+
+	if !<-ready {
+		t.Fatalf("expected the store to become ready")
+	}
+
+	server := &authorizerServer{store: store}
+	check := func(subjectID, resourceID string) bool {
+		resp, err := server.Check(context.Background(), &authorizerpb.CheckRequest{
+			ResourceType: "document",
+			ResourceIds:  []string{resourceID},
+			Relation:     "can_view",
+			SubjectType:  "user",
+			SubjectId:    subjectID,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		return resp.GetResultsByResourceId()[resourceID].GetHasRelation()
+	}
+
+	eventually(t, func() bool { return check("alice", "2") && !check("bob", "1") }, "expected the change to be applied after the snapshot")
+
+	if !check("jon", "1") {
+		t.Errorf("expected user:jon to have can_view on document:1")
+	}
+
+	if reached, _ := store.RevisionReached(context.Background(), 42); !reached {
+		t.Errorf("expected revision 42 to be reached")
+	}
+
+	subjects, err := store.LookupSubjectIDs(context.Background(), "document", "1", "can_view", "user", "")
+	if err != nil || !reflect.DeepEqual(subjects, []string{"jon"}) {
+		t.Errorf("expected the subjects [jon], got %v (%v)", subjects, err)
+	}
+
+	resources, err := store.LookupResourceIDs(context.Background(), "document", "can_view", "user", "", []string{"alice", "jon"})
+	if err != nil || !reflect.DeepEqual(resources, []string{"1", "2"}) {
+		t.Errorf("expected the resources [1 2], got %v (%v)", resources, err)
+	}
+
+	// the relationship is derived at another depth, and then at none
+	pipeline.chunks <- fmt.Sprintf(`{"sequence_number": 1, "json_data": [{"insert": %s}]}`, derivedRow("jon", "1", 2))
+	pipeline.chunks <- fmt.Sprintf(`{"sequence_number": 2, "json_data": [{"delete": %s}]}`, derivedRow("jon", "1", 1))
+	pipeline.chunks <- fmt.Sprintf(`{"sequence_number": 3, "json_data": [{"insert": %s}]}`, derivedRow("alice", "3", 1))
+	pipeline.chunks <- fmt.Sprintf(`{"sequence_number": 4, "json_data": [{"insert": %s}]}`, markerRow(43))
+
+	// the chunks of a stream are applied in order
+	eventually(t, func() bool { return check("alice", "3") }, "expected the changes of the stream to be applied")
+	eventually(t, func() bool {
+		reached, _ := store.RevisionReached(context.Background(), 43)
+		return reached
+	}, "expected revision 43 to be reached")
+
+	if !check("jon", "1") {
+		t.Errorf("expected user:jon to have can_view on document:1 while it is derived at any depth")
+	}
+
+	pipeline.chunks <- fmt.Sprintf(`{"sequence_number": 5, "json_data": [{"delete": %s}]}`, derivedRow("jon", "1", 2))
+	eventually(t, func() bool { return !check("jon", "1") }, "expected user:jon to lose can_view on document:1")
+
+	cancel()
+	<-done
+
+	if <-ready {
+		t.Errorf("expected the store to stop being ready")
+	}
+}
+
+func TestEgressStore_DepthLimitReached(t *testing.T) {
+	store := &egressStore{index: newRelationshipIndex(2)}
+	server := &authorizerServer{store: store}
+
+	apply := func(inserted bool, row string) {
+		change := egressChange{Delete: json.RawMessage(row)}
+		if inserted {
+			change = egressChange{Insert: json.RawMessage(row)}
+		}
+
+		if err := store.index.apply([]egressChange{change}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// Check reports whether the depth limit was reached for the resources the
+	// subject has no relationship to, which may be missing
+	depthLimitReached := func(subjectID string) bool {
+		resp, err := server.Check(context.Background(), &authorizerpb.CheckRequest{
+			ResourceType: "document",
+			ResourceIds:  []string{"missing"},
+			Relation:     "can_view",
+			SubjectType:  "user",
+			SubjectId:    subjectID,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		reached, err := store.DepthLimitReached(context.Background(), "user", "", []string{subjectID})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got := resp.GetResultsByResourceId()["missing"].GetDepthLimitReached(); got != reached {
+			t.Errorf("expected Check to report depth_limit_reached=%t for user:%s, got %t", reached, subjectID, got)
+		}
+
+		return reached
+	}
+
+	apply(true, derivedRow("jon", "1", 1))
+	apply(true, derivedRow("bob", "1", 2))
+	apply(true, derivedRow("bob", "2", 2))
+	if depthLimitReached("jon") || !depthLimitReached("bob") {
+		t.Errorf("expected only user:bob to have reached the maximum depth")
+	}
+
+	// the subject is depth limited while any of its rows is at the maximum
+	apply(false, derivedRow("bob", "1", 2))
+	if !depthLimitReached("bob") {
+		t.Errorf("expected user:bob to have reached the maximum depth while it has a row at it")
+	}
+
+	apply(false, derivedRow("bob", "2", 2))
+	if depthLimitReached("bob") {
+		t.Errorf("expected user:bob not to have reached the maximum depth once its rows are deleted")
+	}
+
+	// the depth isn't limited without a maximum depth
+	store.index = newRelationshipIndex(0)
+	apply(true, derivedRow("bob", "1", 2))
+	if depthLimitReached("bob") {
+		t.Errorf("expected no subject to reach the maximum depth without one")
+	}
+}
+
+func TestEgressStore_MarkerBeforeRows(t *testing.T) {
+	pipeline, feldera := newTestPipeline(t, markerRow(42))
+
+	ready := make(chan bool, 8)
+	store := newEgressStore(feldera, 0, func(r bool) { ready <- r })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go store.run(ctx)
+
+	if !<-ready {
+		t.Fatalf("expected the store to become ready")
+	}
+
+	// the marker of the step comes before its rows in the chunk, and must not
+	// be reached before they are applied
+	pipeline.chunks <- fmt.Sprintf(`{"sequence_number": 0, "json_data": [{"insert": %s}, {"insert": %s}, {"delete": %s}]}`, markerRow(43), derivedRow("jon", "1", 1), markerRow(42))
+
+	eventually(t, func() bool {
+		reached, err := store.RevisionReached(context.Background(), 43)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !reached {
+			return false
+		}
+
+		subjects, err := store.LookupSubjectIDs(context.Background(), "document", "1", "can_view", "user", "")
+		if err != nil || !reflect.DeepEqual(subjects, []string{"jon"}) {
+			t.Fatalf("expected the rows of revision 43 once it is reached, got %v (%v)", subjects, err)
+		}
+
+		return true
+	}, "expected revision 43 to be reached")
+
+	if reached, _ := store.RevisionReached(context.Background(), 42); reached {
+		t.Errorf("expected the marker of revision 42 to be retracted")
+	}
+}
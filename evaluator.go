@@ -0,0 +1,438 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// derivedKey identifies a row of the derived_relationships view.
+type derivedKey struct {
+	subjectType, subjectID, subjectRelation string
+	resourceType, resourceID, relation      string
+	caveats                                 string
+	expiresAt                               int64
+}
+
+func keyOf(r Relationship) derivedKey {
+	key := derivedKey{
+		subjectType:     r.SubjectType,
+		subjectID:       r.SubjectID,
+		subjectRelation: r.SubjectRelation,
+		resourceType:    r.ResourceType,
+		resourceID:      r.ResourceID,
+		relation:        r.Relation,
+		caveats:         strings.Join(r.Caveats, "\x00"),
+		expiresAt:       -1,
+	}
+	if r.ExpiresAt != nil {
+		key.expiresAt = r.ExpiresAt.UnixNano()
+	}
+
+	return key
+}
+
+// objectKey identifies an object (e.g. 'document:1') or, with a relation, a
+// userset (e.g. 'group:eng#member').
+type objectKey struct {
+	objectType, objectID, relation string
+}
+
+// evaluation is the state of the evaluation of the derived relationships.
+type evaluation struct {
+	rules SchemaQueryRules
+
+	// caveated are the active relationships the type restrictions allow, with
+	// their caveat encoded as in the allowed_relationships view, by subject
+	// userset.
+	caveated map[objectKey][]Relationship
+
+	unaryRules  map[[2]string][]UnaryRule
+	firstRules  map[[2]string][]BinaryRule
+	secondRules map[[2]string][]BinaryRule
+
+	// negatedRules are the negated rules of the stratum being evaluated, by
+	// their first prerequisite, and excluded the relationships of the
+	// previous stratum.
+	negatedRules map[[2]string][]BinaryRule
+	excluded     map[exclusion]struct{}
+
+	rows       map[derivedKey]Relationship
+	steps      map[derivedKey]step
+	byResource map[objectKey][]Relationship
+	bySubject  map[objectKey][]Relationship
+}
+
+// step is the derivation of a relationship by a rule from its prerequisites.
+type step struct {
+	relationship  Relationship
+	rule          string
+	prerequisites []Relationship
+}
+
+// Evaluate computes the rows of the derived_relationships view of program.sql
+// (generated without a maximum depth) for the rules and relationships, as of
+// the time now. It is the reference the pipeline is tested against, and serves
+// checks in dev mode.
+//
+// Negated binary rules derive a relationship for the subjects of the first
+// relationship which don't have the second relationship to the same resource,
+// either directly or through the wildcard subject. Any such relationship
+// excludes the subject, whether or not it is caveated. Like the strata of the
+// program, the negated rules are evaluated over the completed relationships of
+// the previous stratum, and an error is returned if the rules negate
+// relationships which depend on themselves.
+func Evaluate(rules SchemaQueryRules, relationships []Relationship, now time.Time) ([]Relationship, error) {
+	derivations, err := EvaluateDerivations(rules, relationships, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return derivedRelationships(derivations), nil
+}
+
+// EvaluateDerivations is Evaluate, but returns the derivation of each of the
+// derived relationships. The derivation of a relationship is the first of its
+// derivations found, which is one of the shortest.
+func EvaluateDerivations(rules SchemaQueryRules, relationships []Relationship, now time.Time) ([]*Derivation, error) {
+	e := &evaluation{
+		rules:       rules,
+		caveated:    map[objectKey][]Relationship{},
+		unaryRules:  map[[2]string][]UnaryRule{},
+		firstRules:  map[[2]string][]BinaryRule{},
+		secondRules: map[[2]string][]BinaryRule{},
+	}
+
+	for _, rule := range rules.UnaryRules {
+		key := [2]string{rule.ResourceType, rule.SourceRelation}
+		e.unaryRules[key] = append(e.unaryRules[key], rule)
+	}
+
+	for _, rule := range rules.BinaryRules {
+		first := [2]string{rule.FirstResourceType, rule.FirstRelation}
+		e.firstRules[first] = append(e.firstRules[first], rule)
+		second := [2]string{rule.SecondResourceType, rule.SecondRelation}
+		e.secondRules[second] = append(e.secondRules[second], rule)
+	}
+
+	restrictions := map[RelationTypeRestriction]struct{}{}
+	for _, restriction := range rules.RelationTypeRestrictions {
+		restrictions[restriction] = struct{}{}
+	}
+
+	var base []step
+	for _, r := range relationships {
+		if r.Expired(now) {
+			continue
+		}
+
+		caveated := Relationship{
+			SubjectType:     r.SubjectType,
+			SubjectID:       r.SubjectID,
+			SubjectRelation: r.SubjectRelation,
+			ResourceType:    r.ResourceType,
+			ResourceID:      r.ResourceID,
+			Relation:        r.Relation,
+			ExpiresAt:       r.ExpiresAt,
+		}
+		if r.CaveatName != "" {
+			caveatContext := r.CaveatContext
+			if caveatContext == "" {
+				caveatContext = "{}"
+			}
+
+			caveated.Caveats = []string{fmt.Sprintf(`{"name": "%s", "context": %s}`, r.CaveatName, caveatContext)}
+		}
+
+		restriction := RelationTypeRestriction{
+			ResourceType:    r.ResourceType,
+			Relation:        r.Relation,
+			SubjectType:     r.SubjectType,
+			SubjectRelation: r.SubjectRelation,
+			Wildcard:        r.SubjectID == wildcardSubjectID,
+			Caveat:          r.CaveatName,
+			WithExpiration:  true,
+		}
+		_, valid := restrictions[restriction]
+		if r.ExpiresAt == nil {
+			restriction.WithExpiration = false
+			_, withoutExpiration := restrictions[restriction]
+			valid = valid || withoutExpiration
+		}
+
+		if valid {
+			subject := objectKey{r.SubjectType, r.SubjectID, r.SubjectRelation}
+			e.caveated[subject] = append(e.caveated[subject], caveated)
+			base = append(base, step{relationship: caveated, rule: relationshipsRule})
+		}
+	}
+
+	negatedRules, err := stratify(rules)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each stratum derives the relationships of the previous one and those
+	// derived from them, applying its negated rules over the completed
+	// relationships of the previous stratum.
+	current := e.closure(base)
+	for stratum := 1; stratum <= maxStratum(negatedRules); stratum++ {
+		e.negatedRules = map[[2]string][]BinaryRule{}
+		for _, rule := range negatedRules {
+			if rule.Stratum == stratum {
+				first := [2]string{rule.FirstResourceType, rule.FirstRelation}
+				e.negatedRules[first] = append(e.negatedRules[first], rule)
+			}
+		}
+
+		e.excluded = map[exclusion]struct{}{}
+		seed := make([]step, 0, len(current))
+		for key, r := range current {
+			e.excluded[exclusion{
+				resource: objectKey{r.ResourceType, r.ResourceID, r.Relation},
+				subject:  objectKey{r.SubjectType, r.SubjectID, r.SubjectRelation},
+			}] = struct{}{}
+			seed = append(seed, e.steps[key])
+		}
+
+		// the relationships are seeded in order, so the derivations found
+		// don't depend on the order of the map
+		slices.SortFunc(seed, func(a, b step) int {
+			return compareRelationships(a.relationship, b.relationship)
+		})
+
+		current = e.closure(seed)
+	}
+
+	derivations := map[derivedKey]*Derivation{}
+	var derivation func(key derivedKey) *Derivation
+	derivation = func(key derivedKey) *Derivation {
+		if d, ok := derivations[key]; ok {
+			return d
+		}
+
+		s := e.steps[key]
+		d := &Derivation{Relationship: s.relationship, Rule: s.rule}
+		for i, prerequisite := range s.prerequisites {
+
+			// the relationship to a userset is a relationship of the
+			// relationships table, even if the same one is also derived
+			if s.rule == usersetRule && i == len(s.prerequisites)-1 {
+				d.Prerequisites = append(d.Prerequisites, &Derivation{Relationship: prerequisite, Rule: relationshipsRule})
+				continue
+			}
+
+			d.Prerequisites = append(d.Prerequisites, derivation(keyOf(prerequisite)))
+		}
+
+		derivations[key] = d
+		return d
+	}
+
+	result := make([]*Derivation, 0, len(current))
+	for key := range current {
+		result = append(result, derivation(key))
+	}
+
+	slices.SortFunc(result, func(a, b *Derivation) int {
+		return compareRelationships(a.Relationship, b.Relationship)
+	})
+	return result, nil
+}
+
+// closure computes all of the relationships derived from the seed
+// relationships.
+func (e *evaluation) closure(seed []step) map[derivedKey]Relationship {
+	e.rows = map[derivedKey]Relationship{}
+	e.steps = map[derivedKey]step{}
+	e.byResource = map[objectKey][]Relationship{}
+	e.bySubject = map[objectKey][]Relationship{}
+
+	queue := seed
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+
+		r := s.relationship
+		key := keyOf(r)
+		if _, ok := e.rows[key]; ok {
+			continue
+		}
+
+		e.rows[key] = r
+		e.steps[key] = s
+		resource := objectKey{r.ResourceType, r.ResourceID, ""}
+		subject := objectKey{r.SubjectType, r.SubjectID, ""}
+		e.byResource[resource] = append(e.byResource[resource], r)
+		e.bySubject[subject] = append(e.bySubject[subject], r)
+
+		queue = append(queue, e.derive(r)...)
+	}
+
+	return e.rows
+}
+
+// derive returns the relationships derived from r and the relationships
+// derived before it.
+func (e *evaluation) derive(r Relationship) []step {
+	var derived []step
+
+	// userset expansion
+	for _, c := range e.caveated[objectKey{r.ResourceType, r.ResourceID, r.Relation}] {
+		derived = append(derived, step{combine(r, c, c.Relation), usersetRule, []Relationship{r, c}})
+	}
+
+	for _, rule := range e.unaryRules[[2]string{r.ResourceType, r.Relation}] {
+		u := r
+		u.Relation = rule.DerivedRelation
+		derived = append(derived, step{u, rule.String(), []Relationship{r}})
+	}
+
+	// r is joined as either prerequisite of a binary rule with the
+	// relationships derived before it, including itself
+	resource := objectKey{r.ResourceType, r.ResourceID, ""}
+	for _, rule := range e.firstRules[[2]string{r.ResourceType, r.Relation}] {
+		switch rule.Kind {
+		case ArrowRule:
+			for _, rhs := range e.bySubject[resource] {
+				if rhs.ResourceType == rule.SecondResourceType && rhs.Relation == rule.SecondRelation && rhs.SubjectRelation == "" {
+					derived = append(derived, step{combine(r, rhs, rule.DerivedRelation), rule.String(), []Relationship{r, rhs}})
+				}
+			}
+		case IntersectionRule:
+			for _, rhs := range e.byResource[resource] {
+				if rhs.Relation == rule.SecondRelation && sameSubject(r, rhs) {
+					derived = append(derived, step{intersect(r, rhs, rule.DerivedRelation), rule.String(), []Relationship{r, rhs}})
+				}
+			}
+		}
+	}
+
+	for _, rule := range e.secondRules[[2]string{r.ResourceType, r.Relation}] {
+		switch rule.Kind {
+		case ArrowRule:
+			if r.SubjectType != rule.FirstResourceType || r.SubjectRelation != "" {
+				continue
+			}
+
+			for _, lhs := range e.byResource[objectKey{r.SubjectType, r.SubjectID, ""}] {
+				if lhs.Relation == rule.FirstRelation {
+					derived = append(derived, step{combine(lhs, r, rule.DerivedRelation), rule.String(), []Relationship{lhs, r}})
+				}
+			}
+		case IntersectionRule:
+			for _, lhs := range e.byResource[resource] {
+				if lhs.Relation == rule.FirstRelation && sameSubject(lhs, r) {
+					derived = append(derived, step{intersect(lhs, r, rule.DerivedRelation), rule.String(), []Relationship{lhs, r}})
+				}
+			}
+		}
+	}
+
+	for _, rule := range e.negatedRules[[2]string{r.ResourceType, r.Relation}] {
+		if e.isExcluded(rule, r) {
+			continue
+		}
+
+		n := r
+		n.Relation = rule.DerivedRelation
+		derived = append(derived, step{n, rule.String(), []Relationship{r}})
+	}
+
+	return derived
+}
+
+// sameSubject returns true if a and b are relationships of the same subject,
+// where the wildcard subject stands for any subject of its type.
+func sameSubject(a, b Relationship) bool {
+	return a.SubjectType == b.SubjectType && a.SubjectRelation == b.SubjectRelation &&
+		(a.SubjectID == b.SubjectID || a.SubjectID == wildcardSubjectID || b.SubjectID == wildcardSubjectID)
+}
+
+// intersect returns the relationship of the subject of both lhs and rhs to
+// their resource, which is the subject of rhs if lhs is of the wildcard
+// subject.
+func intersect(lhs, rhs Relationship, relation string) Relationship {
+	derived := combine(lhs, rhs, relation)
+	if lhs.SubjectID == wildcardSubjectID {
+		derived.SubjectID = rhs.SubjectID
+	}
+
+	return derived
+}
+
+// exclusion is a relationship of a subject, which excludes the subject from
+// the negated rules with it as their second prerequisite.
+type exclusion struct {
+	resource objectKey
+	subject  objectKey
+}
+
+// isExcluded returns true if the subject of r, or the wildcard subject, has
+// the second relationship of the negated rule to the resource of r in the
+// previous stratum.
+func (e *evaluation) isExcluded(rule BinaryRule, r Relationship) bool {
+	resource := objectKey{rule.SecondResourceType, r.ResourceID, rule.SecondRelation}
+	for _, subjectID := range []string{r.SubjectID, wildcardSubjectID} {
+		if _, ok := e.excluded[exclusion{resource, objectKey{r.SubjectType, subjectID, r.SubjectRelation}}]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// combine returns the relationship of the subject of lhs to the resource of
+// rhs derived from both of them, which carries the caveats of both and expires
+// with the first of them.
+func combine(lhs, rhs Relationship, relation string) Relationship {
+	var caveats []string
+	if len(lhs.Caveats) > 0 || len(rhs.Caveats) > 0 {
+		caveats = slices.Concat(lhs.Caveats, rhs.Caveats)
+		slices.Sort(caveats)
+	}
+
+	expiresAt := lhs.ExpiresAt
+	if expiresAt == nil || (rhs.ExpiresAt != nil && rhs.ExpiresAt.Before(expiresAt.Time)) {
+		expiresAt = rhs.ExpiresAt
+	}
+
+	return Relationship{
+		SubjectType:     lhs.SubjectType,
+		SubjectID:       lhs.SubjectID,
+		SubjectRelation: lhs.SubjectRelation,
+		ResourceType:    rhs.ResourceType,
+		ResourceID:      rhs.ResourceID,
+		Relation:        relation,
+		Caveats:         slices.Compact(caveats),
+		ExpiresAt:       expiresAt,
+	}
+}
+
+func compareRelationships(a, b Relationship) int {
+	return cmp.Or(
+		cmp.Compare(a.ResourceType, b.ResourceType),
+		cmp.Compare(a.ResourceID, b.ResourceID),
+		cmp.Compare(a.Relation, b.Relation),
+		cmp.Compare(a.SubjectType, b.SubjectType),
+		cmp.Compare(a.SubjectID, b.SubjectID),
+		cmp.Compare(a.SubjectRelation, b.SubjectRelation),
+		slices.Compare(a.Caveats, b.Caveats),
+		compareExpiration(a.ExpiresAt, b.ExpiresAt),
+	)
+}
+
+func compareExpiration(a, b *Timestamp) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	default:
+		return a.Compare(b.Time)
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	authorizerpb "github.com/jon-whit/feldera-rebac/protos/gen/go/authorizer/v1alpha1"
+)
+
+// derivedStrings returns the derived relationships in their string form,
+// excluding those of the given relations.
+func derivedStrings(derived []Relationship, excludedRelations ...string) []string {
+	var strs []string
+	for _, r := range derived {
+		if !slices.Contains(excludedRelations, r.Relation) {
+			strs = append(strs, r.String())
+		}
+	}
+
+	slices.Sort(strs)
+	return strs
+}
+
+func TestEvaluate_NestedGroups(t *testing.T) {
+	schema, err := loadSchema("examples/nested-groups/schema.json")
+	if err != nil {
+		t.Fatalf("failed to load schema: %v", err)
+	}
+
+	derived, err := Evaluate(mustMapSchemaToQueryRules(t, schema), []Relationship{
+		{SubjectType: "user", SubjectID: "jon", ResourceType: "group", ResourceID: "eng", Relation: "member"},
+		{SubjectType: "group", SubjectID: "eng", SubjectRelation: "member", ResourceType: "group", ResourceID: "all", Relation: "member"},
+		{SubjectType: "group", SubjectID: "all", SubjectRelation: "member", ResourceType: "document", ResourceID: "readme", Relation: "viewer"},
+
+		// not permitted by the type restrictions of document#viewer
+		{SubjectType: "user", SubjectID: "bob", ResourceType: "document", ResourceID: "readme", Relation: "viewer"},
+
+		// not permitted by the type restrictions of group#member, so the
+		// viewers of document:readme aren't expanded into its members
+		{SubjectType: "document", SubjectID: "readme", SubjectRelation: "viewer", ResourceType: "group", ResourceID: "leak", Relation: "member"},
+	}, time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{
+		"document:readme#can_view@group:all#member",
+		"document:readme#can_view@group:eng#member",
+		"document:readme#can_view@user:jon",
+		"document:readme#viewer@group:all#member",
+		"document:readme#viewer@group:eng#member",
+		"document:readme#viewer@user:jon",
+		"group:all#member@group:eng#member",
+		"group:all#member@user:jon",
+		"group:eng#member@user:jon",
+	}
+	if got := derivedStrings(derived); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestEvaluate_Hierarchy(t *testing.T) {
+	schema, err := loadSchema("examples/hierarchical-relationships/schema.json")
+	if err != nil {
+		t.Fatalf("failed to load schema: %v", err)
+	}
+
+	derived, err := Evaluate(mustMapSchemaToQueryRules(t, schema), []Relationship{
+		{SubjectType: "user", SubjectID: "jon", ResourceType: "folder", ResourceID: "root", Relation: "viewer"},
+		{SubjectType: "folder", SubjectID: "root", ResourceType: "folder", ResourceID: "docs", Relation: "parent"},
+		{SubjectType: "folder", SubjectID: "docs", ResourceType: "document", ResourceID: "readme", Relation: "parent"},
+	}, time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{
+		"document:readme#can_view@user:jon",
+		"folder:docs#can_view@user:jon",
+		"folder:root#can_view@user:jon",
+		"folder:root#viewer@user:jon",
+	}
+	if got := derivedStrings(derived, "parent"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestEvaluate_CaveatsAndExpiration(t *testing.T) {
+	soon := &Timestamp{time.Now().Add(time.Hour).Truncate(time.Microsecond)}
+	later := &Timestamp{time.Now().Add(24 * time.Hour).Truncate(time.Microsecond)}
+
+	rules := SchemaQueryRules{
+		RelationTypeRestrictions: []RelationTypeRestriction{
+			{ResourceType: "group", Relation: "member", SubjectType: "user", WithExpiration: true},
+			{ResourceType: "document", Relation: "viewer", SubjectType: "group", SubjectRelation: "member", Caveat: "ip_allowlist", WithExpiration: true},
+		},
+	}
+
+	derived, err := Evaluate(rules, []Relationship{
+		{SubjectType: "user", SubjectID: "jon", ResourceType: "group", ResourceID: "eng", Relation: "member", ExpiresAt: later},
+		{SubjectType: "user", SubjectID: "bob", ResourceType: "group", ResourceID: "eng", Relation: "member", ExpiresAt: &Timestamp{time.Now().Add(-time.Hour)}},
+		{SubjectType: "group", SubjectID: "eng", SubjectRelation: "member", ResourceType: "document", ResourceID: "readme", Relation: "viewer", CaveatName: "ip_allowlist", CaveatContext: `{"cidr": "10.0.0.0/8"}`, ExpiresAt: soon},
+	}, time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var viewer *Relationship
+	for _, r := range derived {
+		if r.SubjectID == "bob" {
+			t.Errorf("expected no relationships of the expired member, got %v", r)
+		}
+
+		if r.String() == "document:readme#viewer@user:jon" {
+			viewer = &r
+		}
+	}
+
+	if viewer == nil {
+		t.Fatalf("expected document:readme#viewer@user:jon to be derived, got %v", derived)
+	}
+
+	if expected := []string{`{"name": "ip_allowlist", "context": {"cidr": "10.0.0.0/8"}}`}; !reflect.DeepEqual(viewer.Caveats, expected) {
+		t.Errorf("expected caveats %v, got %v", expected, viewer.Caveats)
+	}
+
+	if viewer.ExpiresAt == nil || !viewer.ExpiresAt.Equal(soon.Time) {
+		t.Errorf("expected the relationship to expire at %v, got %v", soon, viewer.ExpiresAt)
+	}
+}
+
+func TestEvaluate_NegatedRules(t *testing.T) {
+	rules := SchemaQueryRules{
+		RelationTypeRestrictions: []RelationTypeRestriction{
+			{ResourceType: "document", Relation: "viewer", SubjectType: "user"},
+			{ResourceType: "document", Relation: "restricted", SubjectType: "user"},
+			{ResourceType: "document", Relation: "restricted", SubjectType: "user", Wildcard: true},
+		},
+		NegatedBinaryRules: []BinaryRule{
+			{FirstResourceType: "document", FirstRelation: "viewer", SecondResourceType: "document", SecondRelation: "restricted", DerivedRelation: "can_view"},
+		},
+	}
+
+	derived, err := Evaluate(rules, []Relationship{
+		{SubjectType: "user", SubjectID: "jon", ResourceType: "document", ResourceID: "1", Relation: "viewer"},
+		{SubjectType: "user", SubjectID: "bob", ResourceType: "document", ResourceID: "1", Relation: "viewer"},
+		{SubjectType: "user", SubjectID: "bob", ResourceType: "document", ResourceID: "1", Relation: "restricted"},
+		{SubjectType: "user", SubjectID: "jon", ResourceType: "document", ResourceID: "2", Relation: "viewer"},
+		{SubjectType: "user", SubjectID: "*", ResourceType: "document", ResourceID: "2", Relation: "restricted"},
+	}, time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{"document:1#can_view@user:jon"}
+	if got := derivedStrings(derived, "viewer", "restricted"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestEvaluate_StratifiedNegation(t *testing.T) {
+	rules := SchemaQueryRules{
+		RelationTypeRestrictions: []RelationTypeRestriction{
+			{ResourceType: "document", Relation: "viewer", SubjectType: "user"},
+			{ResourceType: "document", Relation: "editor", SubjectType: "user"},
+			{ResourceType: "document", Relation: "banned", SubjectType: "user"},
+		},
+		NegatedBinaryRules: []BinaryRule{
+			// can_edit = editor - can_view, which is only correct over all of
+			// the relationships of can_view
+			{FirstResourceType: "document", FirstRelation: "editor", SecondResourceType: "document", SecondRelation: "can_view", DerivedRelation: "can_edit"},
+			{FirstResourceType: "document", FirstRelation: "viewer", SecondResourceType: "document", SecondRelation: "banned", DerivedRelation: "can_view"},
+		},
+	}
+
+	derived, err := Evaluate(rules, []Relationship{
+		{SubjectType: "user", SubjectID: "jon", ResourceType: "document", ResourceID: "1", Relation: "viewer"},
+		{SubjectType: "user", SubjectID: "jon", ResourceType: "document", ResourceID: "1", Relation: "editor"},
+		{SubjectType: "user", SubjectID: "bob", ResourceType: "document", ResourceID: "1", Relation: "editor"},
+		{SubjectType: "user", SubjectID: "ann", ResourceType: "document", ResourceID: "1", Relation: "viewer"},
+		{SubjectType: "user", SubjectID: "ann", ResourceType: "document", ResourceID: "1", Relation: "editor"},
+		{SubjectType: "user", SubjectID: "ann", ResourceType: "document", ResourceID: "1", Relation: "banned"},
+	}, time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{
+		"document:1#can_edit@user:ann",
+		"document:1#can_edit@user:bob",
+		"document:1#can_view@user:jon",
+	}
+	if got := derivedStrings(derived, "viewer", "editor", "banned"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestEvaluate_UnstratifiableNegation(t *testing.T) {
+	rules := SchemaQueryRules{
+		RelationTypeRestrictions: []RelationTypeRestriction{
+			{ResourceType: "document", Relation: "viewer", SubjectType: "user"},
+		},
+		NegatedBinaryRules: []BinaryRule{
+			{FirstResourceType: "document", FirstRelation: "viewer", SecondResourceType: "document", SecondRelation: "can_view", DerivedRelation: "can_view"},
+		},
+	}
+
+	_, err := Evaluate(rules, []Relationship{
+		{SubjectType: "user", SubjectID: "jon", ResourceType: "document", ResourceID: "1", Relation: "viewer"},
+	}, time.Now())
+	if err == nil {
+		t.Errorf("expected an error for negation which depends on itself")
+	}
+}
+
+func TestCheck_DevMode(t *testing.T) {
+	schema, err := loadSchema("examples/nested-groups/schema.json")
+	if err != nil {
+		t.Fatalf("failed to load schema: %v", err)
+	}
+
+	derived, err := Evaluate(mustMapSchemaToQueryRules(t, schema), []Relationship{
+		{SubjectType: "user", SubjectID: "jon", ResourceType: "group", ResourceID: "eng", Relation: "member"},
+		{SubjectType: "group", SubjectID: "eng", SubjectRelation: "member", ResourceType: "document", ResourceID: "readme", Relation: "viewer"},
+	}, time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	server := &authorizerServer{store: newMemoryStore(derived)}
+
+	resp, err := server.Check(context.Background(), &authorizerpb.CheckRequest{
+		ResourceType: "document",
+		ResourceIds:  []string{"readme", "other"},
+		Relation:     "can_view",
+		SubjectType:  "user",
+		SubjectId:    "jon",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]bool{"readme": true, "other": false}
+	for resourceID, hasRelation := range expected {
+		if got := resp.GetResultsByResourceId()[resourceID].GetHasRelation(); got != hasRelation {
+			t.Errorf("expected has_relation=%t for document:%s, got %t", hasRelation, resourceID, got)
+		}
+	}
+
+	resources, err := server.LookupResources(context.Background(), &authorizerpb.LookupResourcesRequest{
+		ResourceType: "document",
+		Relation:     "can_view",
+		SubjectType:  "user",
+		SubjectId:    "jon",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if expected := []string{"readme"}; !reflect.DeepEqual(resources.GetResourceIds(), expected) {
+		t.Errorf("expected %v, got %v", expected, resources.GetResourceIds())
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	authorizerpb "github.com/jon-whit/feldera-rebac/protos/gen/go/authorizer/v1alpha1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *authorizerServer) Expand(ctx context.Context, req *authorizerpb.ExpandRequest) (*authorizerpb.ExpandResponse, error) {
+	if req.GetResourceType() == "" || req.GetResourceId() == "" || req.GetRelation() == "" {
+		return nil, status.Error(codes.InvalidArgument, "resource_type, resource_id and relation are required")
+	}
+
+	typedef, ok := s.schema.GetTypeDefinitions()[req.GetResourceType()]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "undefined resource type '%s'", req.GetResourceType())
+	}
+
+	if _, ok := typedef.GetRelations()[req.GetRelation()]; !ok {
+		if _, ok := typedef.GetPermissions()[req.GetRelation()]; !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "undefined relation or permission '%s' of '%s'", req.GetRelation(), req.GetResourceType())
+		}
+	}
+
+	e := &expansion{schema: s.schema, store: s.store}
+	tree, err := e.expand(req.GetResourceType(), req.GetResourceId(), req.GetRelation(), map[string]bool{})
+	if err == nil {
+		err = e.run(ctx)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to expand %s:%s#%s: %v", req.GetResourceType(), req.GetResourceId(), req.GetRelation(), err)
+	}
+
+	return &authorizerpb.ExpandResponse{Tree: tree}, nil
+}
+
+// expansion is the state of the expansion of a permission tree, which is built
+// a level at a time. The relationships of the leaves and arrows of a level are
+// read once the level is built, with one lookup of each resource type and
+// relation rather than one of each leaf, and the arrows then add the subtrees
+// of the next level.
+type expansion struct {
+	schema *authorizerpb.Schema
+	store  PermissionStore
+
+	// lookups are the lookups of the relationships of the level being built.
+	lookups []expansionLookup
+}
+
+// expansionLookup is a lookup of the relationships with the relation to the
+// resource, which fill adds to the tree once they're read.
+type expansionLookup struct {
+	resourceType, resourceID, relation string
+
+	fill func(relationships []Relationship) error
+}
+
+// run reads the relationships of each level of the tree, until a level needs
+// no more.
+func (e *expansion) run(ctx context.Context) error {
+	type batchKey struct{ resourceType, relation string }
+
+	for len(e.lookups) > 0 {
+		level := e.lookups
+		e.lookups = nil
+
+		var batches []batchKey
+		resourceIDs := map[batchKey][]string{}
+		for _, lookup := range level {
+			key := batchKey{lookup.resourceType, lookup.relation}
+			if _, ok := resourceIDs[key]; !ok {
+				batches = append(batches, key)
+			}
+
+			resourceIDs[key] = append(resourceIDs[key], lookup.resourceID)
+		}
+
+		relationships := make(map[batchKey]map[string][]Relationship, len(batches))
+		for _, key := range batches {
+			ids := resourceIDs[key]
+			slices.Sort(ids)
+
+			read, err := e.store.ResourceRelationships(ctx, key.resourceType, slices.Compact(ids), key.relation)
+			if err != nil {
+				return err
+			}
+
+			relationships[key] = read
+		}
+
+		for _, lookup := range level {
+			if err := lookup.fill(relationships[batchKey{lookup.resourceType, lookup.relation}][lookup.resourceID]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// expand returns the tree of the relation of the resource, whose leaves and
+// arrows are filled in by run. Permissions which are already being expanded
+// are not expanded again, which would never end for recursive permissions
+// (e.g. 'can_view = viewer + parent->can_view').
+func (e *expansion) expand(resourceType, resourceID, relation string, expanding map[string]bool) (*authorizerpb.PermissionTree, error) {
+	tree := &authorizerpb.PermissionTree{
+		ResourceType: resourceType,
+		ResourceId:   resourceID,
+		Relation:     relation,
+	}
+
+	key := fmt.Sprintf("%s:%s#%s", resourceType, resourceID, relation)
+	permission, ok := e.schema.GetTypeDefinitions()[resourceType].GetPermissions()[relation]
+	if !ok || expanding[key] {
+		leaf := &authorizerpb.PermissionTree_Leaf{}
+		e.lookups = append(e.lookups, expansionLookup{resourceType, resourceID, relation, func(relationships []Relationship) error {
+			leaf.Subjects = leafSubjects(relationships)
+			return nil
+		}})
+
+		tree.Node = &authorizerpb.PermissionTree_Leaf_{Leaf: leaf}
+		return tree, nil
+	}
+
+	expanding[key] = true
+	defer delete(expanding, key)
+
+	exp := permission.GetExpression()
+	if _, ok := exp.GetExpression().(*authorizerpb.PermissionExpressionRef_SetExpression); ok {
+		expanded, err := e.expandExpression(tree, exp, expanding)
+		if err != nil {
+			return nil, err
+		}
+
+		tree.Node = expanded.GetNode()
+		return tree, nil
+	}
+
+	child, err := e.expandExpression(tree, exp, expanding)
+	if err != nil {
+		return nil, err
+	}
+
+	tree.Node = &authorizerpb.PermissionTree_Intermediate_{
+		Intermediate: &authorizerpb.PermissionTree_Intermediate{
+			Operation: authorizerpb.PermissionTree_OPERATION_UNION,
+			Children:  []*authorizerpb.PermissionTree{child},
+		},
+	}
+	return tree, nil
+}
+
+// expandExpression returns the tree of an expression of the permission of
+// parent.
+func (e *expansion) expandExpression(
+	parent *authorizerpb.PermissionTree,
+	exp *authorizerpb.PermissionExpressionRef,
+	expanding map[string]bool,
+) (*authorizerpb.PermissionTree, error) {
+	switch exp := exp.GetExpression().(type) {
+	case *authorizerpb.PermissionExpressionRef_UnaryExpression:
+		return e.expand(parent.GetResourceType(), parent.GetResourceId(), exp.UnaryExpression.GetSourceRelation(), expanding)
+	case *authorizerpb.PermissionExpressionRef_HierarchicalExpression:
+		base := exp.HierarchicalExpression.GetBase()
+		target := exp.HierarchicalExpression.GetTarget()
+
+		// the subtrees of the arrow are expanded in the next level, under the
+		// permissions being expanded now
+		ancestors := maps.Clone(expanding)
+		intermediate := &authorizerpb.PermissionTree_Intermediate{Operation: authorizerpb.PermissionTree_OPERATION_UNION}
+		e.lookups = append(e.lookups, expansionLookup{parent.GetResourceType(), parent.GetResourceId(), base, func(relationships []Relationship) error {
+			seen := map[string]bool{}
+			for _, r := range relationships {
+				typedef := e.schema.GetTypeDefinitions()[r.SubjectType]
+				_, isRelation := typedef.GetRelations()[target]
+				_, isPermission := typedef.GetPermissions()[target]
+				if r.SubjectRelation != "" || r.Expired(time.Now()) || seen[r.SubjectType+":"+r.SubjectID] || !(isRelation || isPermission) {
+					continue
+				}
+
+				seen[r.SubjectType+":"+r.SubjectID] = true
+
+				child, err := e.expand(r.SubjectType, r.SubjectID, target, ancestors)
+				if err != nil {
+					return err
+				}
+
+				intermediate.Children = append(intermediate.Children, child)
+			}
+
+			return nil
+		}})
+
+		return &authorizerpb.PermissionTree{
+			ResourceType: parent.GetResourceType(),
+			ResourceId:   parent.GetResourceId(),
+			Relation:     base + "->" + target,
+			Node:         &authorizerpb.PermissionTree_Intermediate_{Intermediate: intermediate},
+		}, nil
+	case *authorizerpb.PermissionExpressionRef_SetExpression:
+		var operation authorizerpb.PermissionTree_Operation
+		var operands []*authorizerpb.PermissionExpressionRef
+		switch set := exp.SetExpression.GetSetExpression().(type) {
+		case *authorizerpb.PermissionSetExpressionRef_Union_:
+			operation = authorizerpb.PermissionTree_OPERATION_UNION
+			operands = set.Union.GetOperands()
+		case *authorizerpb.PermissionSetExpressionRef_Intersection_:
+			operation = authorizerpb.PermissionTree_OPERATION_INTERSECTION
+			operands = set.Intersection.GetOperands()
+		case *authorizerpb.PermissionSetExpressionRef_Exclusion_:
+			operation = authorizerpb.PermissionTree_OPERATION_EXCLUSION
+			operands = set.Exclusion.GetOperands()
+		default:
+			return nil, fmt.Errorf("unexpected set expression type %T", set)
+		}
+
+		intermediate := &authorizerpb.PermissionTree_Intermediate{Operation: operation}
+		for _, operand := range operands {
+			child, err := e.expandExpression(parent, operand, expanding)
+			if err != nil {
+				return nil, err
+			}
+
+			intermediate.Children = append(intermediate.Children, child)
+		}
+
+		return &authorizerpb.PermissionTree{
+			ResourceType: parent.GetResourceType(),
+			ResourceId:   parent.GetResourceId(),
+			Relation:     parent.GetRelation(),
+			Node:         &authorizerpb.PermissionTree_Intermediate_{Intermediate: intermediate},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unexpected expression type %T", exp)
+	}
+}
+
+// leafSubjects returns the subjects of the relationships of a leaf.
+func leafSubjects(relationships []Relationship) []*authorizerpb.Subject {
+	var subjects []*authorizerpb.Subject
+	seen := map[string]bool{}
+	for _, r := range relationships {
+		key := r.SubjectType + ":" + r.SubjectID + "#" + r.SubjectRelation
+		if r.Expired(time.Now()) || seen[key] {
+			continue
+		}
+
+		seen[key] = true
+		subjects = append(subjects, &authorizerpb.Subject{
+			SubjectType:     r.SubjectType,
+			SubjectId:       r.SubjectID,
+			SubjectRelation: r.SubjectRelation,
+		})
+	}
+
+	slices.SortFunc(subjects, func(a, b *authorizerpb.Subject) int {
+		return compareRelationships(
+			Relationship{SubjectType: a.GetSubjectType(), SubjectID: a.GetSubjectId(), SubjectRelation: a.GetSubjectRelation()},
+			Relationship{SubjectType: b.GetSubjectType(), SubjectID: b.GetSubjectId(), SubjectRelation: b.GetSubjectRelation()},
+		)
+	})
+
+	return subjects
+}
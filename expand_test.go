@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	authorizerpb "github.com/jon-whit/feldera-rebac/protos/gen/go/authorizer/v1alpha1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// treeString returns the tree in a compact form, e.g.
+// 'document:1#can_view union(document:1#viewer [user:jon])'.
+func treeString(tree *authorizerpb.PermissionTree) string {
+	s := tree.GetResourceType() + ":" + tree.GetResourceId() + "#" + tree.GetRelation()
+
+	switch node := tree.GetNode().(type) {
+	case *authorizerpb.PermissionTree_Leaf_:
+		var subjects []string
+		for _, subject := range node.Leaf.GetSubjects() {
+			str := subject.GetSubjectType() + ":" + subject.GetSubjectId()
+			if subject.GetSubjectRelation() != "" {
+				str += "#" + subject.GetSubjectRelation()
+			}
+
+			subjects = append(subjects, str)
+		}
+
+		return s + " [" + strings.Join(subjects, " ") + "]"
+	case *authorizerpb.PermissionTree_Intermediate_:
+		var children []string
+		for _, child := range node.Intermediate.GetChildren() {
+			children = append(children, treeString(child))
+		}
+
+		operation := strings.ToLower(strings.TrimPrefix(node.Intermediate.GetOperation().String(), "OPERATION_"))
+		return s + " " + operation + "(" + strings.Join(children, ", ") + ")"
+	default:
+		return s
+	}
+}
+
+func newExpandServer(t *testing.T, schemaPath string, relationships []Relationship) *authorizerServer {
+	t.Helper()
+
+	schema, err := loadSchema(schemaPath)
+	if err != nil {
+		t.Fatalf("failed to load schema: %v", err)
+	}
+
+	derived, err := Evaluate(mustMapSchemaToQueryRules(t, schema), relationships, time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return &authorizerServer{store: newMemoryStore(derived), schema: schema}
+}
+
+func TestExpand(t *testing.T) {
+	tests := []struct {
+		name          string
+		schemaPath    string
+		relationships []Relationship
+		req           *authorizerpb.ExpandRequest
+		expected      string
+	}{
+		{
+			name:       "relation",
+			schemaPath: "examples/nested-groups/schema.json",
+			relationships: []Relationship{
+				{SubjectType: "user", SubjectID: "jon", ResourceType: "group", ResourceID: "eng", Relation: "member"},
+				{SubjectType: "group", SubjectID: "eng", SubjectRelation: "member", ResourceType: "document", ResourceID: "readme", Relation: "viewer"},
+			},
+			req:      &authorizerpb.ExpandRequest{ResourceType: "document", ResourceId: "readme", Relation: "viewer"},
+			expected: "document:readme#viewer [group:eng#member user:jon]",
+		},
+		{
+			name:       "unary permission",
+			schemaPath: "examples/nested-groups/schema.json",
+			relationships: []Relationship{
+				{SubjectType: "group", SubjectID: "eng", SubjectRelation: "member", ResourceType: "document", ResourceID: "readme", Relation: "viewer"},
+			},
+			req:      &authorizerpb.ExpandRequest{ResourceType: "document", ResourceId: "readme", Relation: "can_view"},
+			expected: "document:readme#can_view union(document:readme#viewer [group:eng#member])",
+		},
+		{
+			name:       "recursive arrows",
+			schemaPath: "examples/hierarchical-relationships/schema.json",
+			relationships: []Relationship{
+				{SubjectType: "user", SubjectID: "jon", ResourceType: "folder", ResourceID: "root", Relation: "viewer"},
+				{SubjectType: "folder", SubjectID: "root", ResourceType: "folder", ResourceID: "docs", Relation: "parent"},
+				{SubjectType: "folder", SubjectID: "docs", ResourceType: "document", ResourceID: "readme", Relation: "parent"},
+			},
+			req: &authorizerpb.ExpandRequest{ResourceType: "document", ResourceId: "readme", Relation: "can_view"},
+			expected: "document:readme#can_view union(document:readme#parent->can_view union(" +
+				"folder:docs#can_view union(folder:docs#parent->can_view union(" +
+				"folder:root#can_view union(folder:root#parent->can_view union(), folder:root#viewer [user:jon]))" +
+				", folder:docs#viewer [])))",
+		},
+		{
+			name:       "cyclic arrows",
+			schemaPath: "examples/hierarchical-relationships/schema.json",
+			relationships: []Relationship{
+				{SubjectType: "folder", SubjectID: "a", ResourceType: "folder", ResourceID: "a", Relation: "parent"},
+			},
+			req:      &authorizerpb.ExpandRequest{ResourceType: "folder", ResourceId: "a", Relation: "can_view"},
+			expected: "folder:a#can_view union(folder:a#parent->can_view union(folder:a#can_view []), folder:a#viewer [])",
+		},
+		{
+			name:       "intersection",
+			schemaPath: "examples/intersection/schema.json",
+			relationships: []Relationship{
+				{SubjectType: "user", SubjectID: "jon", ResourceType: "document", ResourceID: "readme", Relation: "viewer"},
+				{SubjectType: "user", SubjectID: "jon", ResourceType: "document", ResourceID: "readme", Relation: "allowed"},
+			},
+			req:      &authorizerpb.ExpandRequest{ResourceType: "document", ResourceId: "readme", Relation: "can_view"},
+			expected: "document:readme#can_view intersection(document:readme#viewer [user:jon], document:readme#allowed [user:jon])",
+		},
+		{
+			name:       "exclusion",
+			schemaPath: "examples/exclusion/schema.json",
+			relationships: []Relationship{
+				{SubjectType: "user", SubjectID: "jon", ResourceType: "document", ResourceID: "readme", Relation: "viewer"},
+				{SubjectType: "user", SubjectID: "bob", ResourceType: "document", ResourceID: "readme", Relation: "viewer"},
+				{SubjectType: "user", SubjectID: "bob", ResourceType: "document", ResourceID: "readme", Relation: "banned"},
+			},
+			req:      &authorizerpb.ExpandRequest{ResourceType: "document", ResourceId: "readme", Relation: "can_view"},
+			expected: "document:readme#can_view exclusion(document:readme#viewer [user:bob user:jon], document:readme#banned [user:bob])",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newExpandServer(t, test.schemaPath, test.relationships)
+
+			resp, err := server.Expand(context.Background(), test.req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got := treeString(resp.GetTree()); got != test.expected {
+				t.Errorf("expected\n  %s\ngot\n  %s", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestExpand_Batched(t *testing.T) {
+	// the document is in many folders, all of which are in the root folder
+	relationships := []Relationship{
+		{SubjectType: "user", SubjectID: "jon", ResourceType: "folder", ResourceID: "root", Relation: "viewer"},
+	}
+	for i := range 20 {
+		folder := fmt.Sprintf("f%d", i)
+		relationships = append(relationships,
+			Relationship{SubjectType: "folder", SubjectID: folder, ResourceType: "document", ResourceID: "readme", Relation: "parent"},
+			Relationship{SubjectType: "folder", SubjectID: "root", ResourceType: "folder", ResourceID: folder, Relation: "parent"},
+			Relationship{SubjectType: "user", SubjectID: folder, ResourceType: "folder", ResourceID: folder, Relation: "viewer"},
+		)
+	}
+
+	server := newExpandServer(t, "examples/hierarchical-relationships/schema.json", relationships)
+	store := &countingStore{PermissionStore: server.store}
+	server.store = store
+
+	resp, err := server.Expand(context.Background(), &authorizerpb.ExpandRequest{ResourceType: "document", ResourceId: "readme", Relation: "can_view"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tree := treeString(resp.GetTree())
+	for i := range 20 {
+		if expected := fmt.Sprintf("folder:f%d#viewer [user:f%d]", i, i); !strings.Contains(tree, expected) {
+			t.Errorf("expected the tree to contain '%s', got\n  %s", expected, tree)
+		}
+	}
+
+	if expected := "folder:root#viewer [user:jon]"; strings.Count(tree, expected) != 20 {
+		t.Errorf("expected the tree to contain '%s' under each folder, got\n  %s", expected, tree)
+	}
+
+	// the parents of the document, then the viewers and parents of the
+	// folders, then those of the root folder, rather than a lookup of each
+	if store.lookups > 5 {
+		t.Errorf("expected at most 5 lookups, got %d", store.lookups)
+	}
+}
+
+func TestExpand_InvalidArgument(t *testing.T) {
+	server := newExpandServer(t, "examples/nested-groups/schema.json", nil)
+
+	for _, req := range []*authorizerpb.ExpandRequest{
+		{ResourceType: "document", Relation: "viewer"},
+		{ResourceType: "folder", ResourceId: "readme", Relation: "viewer"},
+		{ResourceType: "document", ResourceId: "readme", Relation: "editor"},
+	} {
+		if _, err := server.Expand(context.Background(), req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("expected InvalidArgument for %v, got %v", req, err)
+		}
+	}
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// defaultFelderaURL is the address of the Feldera pipeline manager of
+// docker-compose.yml.
+const defaultFelderaURL = "http://localhost:8080"
+
+// felderaClient pushes changes to the tables of a Feldera pipeline and
+// consumes the change streams of its views over its HTTP API.
+type felderaClient struct {
+	httpClient *http.Client
+	baseURL    string
+	pipeline   string
+}
+
+// egressChunk is a chunk of the change stream of a view in Feldera's JSON
+// format, with a change for each row inserted into or deleted from the view.
+type egressChunk struct {
+	SequenceNumber int64          `json:"sequence_number"`
+	JSONData       []egressChange `json:"json_data"`
+}
+
+type egressChange struct {
+	Insert json.RawMessage `json:"insert,omitempty"`
+	Delete json.RawMessage `json:"delete,omitempty"`
+}
+
+// egressStream is the change stream of a view.
+type egressStream struct {
+	body   io.ReadCloser
+	reader *bufio.Reader
+}
+
+// openEgress subscribes to the change stream of a view. The stream has every
+// change of the view after openEgress returns.
+func (c *felderaClient) openEgress(ctx context.Context, view string) (*egressStream, error) {
+	endpoint := fmt.Sprintf("%s/v0/pipelines/%s/egress/%s?format=json", c.baseURL, url.PathEscape(c.pipeline), url.PathEscape(view))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return &egressStream{body: resp.Body, reader: bufio.NewReader(resp.Body)}, nil
+}
+
+// next returns the changes of the next chunk of the stream, or io.EOF once
+// the pipeline closes it.
+func (s *egressStream) next() ([]egressChange, error) {
+	for {
+		line, err := s.reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var chunk egressChunk
+			if err := json.Unmarshal(line, &chunk); err != nil {
+				return nil, fmt.Errorf("failed to decode the change stream: %w", err)
+			}
+
+			return chunk.JSONData, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (s *egressStream) Close() error {
+	return s.body.Close()
+}
+
+// query runs an ad-hoc query of the materialized tables and views of the
+// pipeline, calling fn with each row of the result.
+func (c *felderaClient) query(ctx context.Context, sql string, fn func(json.RawMessage) error) error {
+	endpoint := fmt.Sprintf("%s/v0/pipelines/%s/query?%s", c.baseURL, url.PathEscape(c.pipeline), url.Values{"sql": {sql}, "format": {"json"}}.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var row json.RawMessage
+		if err := decoder.Decode(&row); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to decode the result of '%s': %w", sql, err)
+		}
+
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+}
+
+// do sends the request to the pipeline manager, and returns an error unless
+// it responds with a 2xx status.
+func (c *felderaClient) do(req *http.Request) (*http.Response, error) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("unexpected status '%s' from '%s': %s", resp.Status, req.URL, body)
+	}
+
+	return resp, nil
+}
+
+// RelationshipChange is a derived relationship granted or revoked by a step
+// of the pipeline.
+type RelationshipChange struct {
+	Relationship Relationship
+	Revoked      bool
+}
+
+// watchDerivedRelationships streams the changes of the derived_relationships
+// view, calling fn with the changes of each chunk, until the context is
+// canceled, the pipeline closes the stream, or fn returns an error. The stream
+// is subscribed to before a snapshot of the view is taken, so that no change
+// is missed, and the changes which preceded the snapshot leave its rows as
+// they were.
+func (c *felderaClient) watchDerivedRelationships(ctx context.Context, fn func([]RelationshipChange) error) error {
+	stream, err := c.openEgress(ctx, "derived_relationships")
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	rows := newDerivedRowCounts()
+	err = c.query(ctx, "SELECT * FROM derived_relationships", func(row json.RawMessage) error {
+		var r egressRow
+		if err := json.Unmarshal(row, &r); err != nil {
+			return fmt.Errorf("failed to decode derived relationship: %w", err)
+		}
+
+		rows.insert(rowKey{keyOf(r.Relationship), r.Depth})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load the snapshot of 'derived_relationships': %w", err)
+	}
+
+	for {
+		chunk, err := stream.next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		changes, err := rows.netRelationshipChanges(chunk)
+		if err != nil {
+			return err
+		}
+
+		if len(changes) > 0 {
+			if err := fn(changes); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// derivedRowCounts counts the rows of each relationship of the
+// derived_relationships view. With a maximum depth the view has a row for each
+// depth a relationship is derived at, and like relationshipIndex.depths, a
+// relationship is derived while it has any.
+type derivedRowCounts struct {
+	rows   map[rowKey]struct{}
+	depths map[derivedKey]int
+}
+
+func newDerivedRowCounts() *derivedRowCounts {
+	return &derivedRowCounts{rows: map[rowKey]struct{}{}, depths: map[derivedKey]int{}}
+}
+
+func (x *derivedRowCounts) insert(key rowKey) {
+	if _, ok := x.rows[key]; ok {
+		return
+	}
+
+	x.rows[key] = struct{}{}
+	x.depths[key.derivedKey]++
+}
+
+func (x *derivedRowCounts) delete(key rowKey) {
+	if _, ok := x.rows[key]; !ok {
+		return
+	}
+
+	delete(x.rows, key)
+	if x.depths[key.derivedKey]--; x.depths[key.derivedKey] == 0 {
+		delete(x.depths, key.derivedKey)
+	}
+}
+
+// netRelationshipChanges applies a chunk of the change stream of
+// derived_relationships, and returns the relationships it granted, whose
+// first row it inserted, and those it revoked, whose last row it deleted. A
+// step which changes the depth of a relationship deletes and inserts its rows,
+// which isn't a change of the relationship, even across chunks.
+func (x *derivedRowCounts) netRelationshipChanges(chunk []egressChange) ([]RelationshipChange, error) {
+	var order []derivedKey
+	relationships := map[derivedKey]Relationship{}
+	derived := map[derivedKey]bool{}
+	for _, change := range chunk {
+		row, inserted := change.Insert, true
+		if row == nil {
+			row, inserted = change.Delete, false
+		}
+
+		if row == nil {
+			continue
+		}
+
+		var r egressRow
+		if err := json.Unmarshal(row, &r); err != nil {
+			return nil, fmt.Errorf("failed to decode derived relationship: %w", err)
+		}
+
+		key := rowKey{keyOf(r.Relationship), r.Depth}
+		if _, ok := relationships[key.derivedKey]; !ok {
+			order = append(order, key.derivedKey)
+			relationships[key.derivedKey] = r.Relationship
+			derived[key.derivedKey] = x.depths[key.derivedKey] > 0
+		}
+
+		if inserted {
+			x.insert(key)
+		} else {
+			x.delete(key)
+		}
+	}
+
+	var changes []RelationshipChange
+	for _, key := range order {
+		if derived[key] == (x.depths[key] > 0) {
+			continue
+		}
+
+		changes = append(changes, RelationshipChange{Relationship: relationships[key], Revoked: derived[key]})
+	}
+
+	return changes, nil
+}
+
+// relationshipRow is a row of the relationships table of the program, with
+// every column set so that a deleted row matches the written one.
+type relationshipRow struct {
+	SubjectType     string     `json:"subject_type"`
+	SubjectID       string     `json:"subject_id"`
+	SubjectRelation string     `json:"subject_relation"`
+	ResourceType    string     `json:"resource_type"`
+	ResourceID      string     `json:"resource_id"`
+	Relation        string     `json:"relationship"`
+	CaveatName      string     `json:"caveat_name"`
+	CaveatContext   string     `json:"caveat_context"`
+	ExpiresAt       *Timestamp `json:"expires_at"`
+}
+
+func newRelationshipRow(r Relationship) relationshipRow {
+	row := relationshipRow{
+		SubjectType:     r.SubjectType,
+		SubjectID:       r.SubjectID,
+		SubjectRelation: r.SubjectRelation,
+		ResourceType:    r.ResourceType,
+		ResourceID:      r.ResourceID,
+		Relation:        r.Relation,
+		CaveatName:      r.CaveatName,
+		CaveatContext:   r.CaveatContext,
+		ExpiresAt:       r.ExpiresAt,
+	}
+	if row.CaveatContext == "" {
+		row.CaveatContext = "{}"
+	}
+
+	return row
+}
+
+// revisionRow is a row of the revisions table of the program.
+type revisionRow struct {
+	Revision  int64     `json:"revision"`
+	WrittenAt Timestamp `json:"written_at"`
+}
+
+type ingressChange struct {
+	Insert any `json:"insert,omitempty"`
+	Delete any `json:"delete,omitempty"`
+}
+
+// writeRelationships pushes the writes and deletes to the relationships table,
+// and then the revision to the revisions table, so that the pipeline reaches
+// the revision in the same step as the writes or a later one.
+func (c *felderaClient) writeRelationships(ctx context.Context, writes, deletes []Relationship, revision int64) error {
+	var changes []ingressChange
+	for _, r := range deletes {
+		changes = append(changes, ingressChange{Delete: newRelationshipRow(r)})
+	}
+
+	for _, r := range writes {
+		changes = append(changes, ingressChange{Insert: newRelationshipRow(r)})
+	}
+
+	if err := c.push(ctx, "relationships", changes); err != nil {
+		return err
+	}
+
+	return c.push(ctx, "revisions", []ingressChange{
+		{Insert: revisionRow{Revision: revision, WrittenAt: Timestamp{time.Unix(0, revision).UTC()}}},
+	})
+}
+
+// push inserts and deletes rows of a table of the pipeline over its HTTP
+// ingress API.
+func (c *felderaClient) push(ctx context.Context, table string, changes []ingressChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, change := range changes {
+		if err := encoder.Encode(change); err != nil {
+			return err
+		}
+	}
+
+	endpoint := fmt.Sprintf("%s/v0/pipelines/%s/ingress/%s?format=json&update_format=insert_delete", c.baseURL, url.PathEscape(c.pipeline), table)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, &body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
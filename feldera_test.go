@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	authorizerpb "github.com/jon-whit/feldera-rebac/protos/gen/go/authorizer/v1alpha1"
+	"google.golang.org/grpc"
+)
+
+// newTestFeldera returns a felderaClient of a stand-in for the pipeline
+// manager, which answers the snapshot query of derived_relationships with its
+// rows, and streams the chunks from the egress endpoint of
+// derived_relationships and then closes the stream.
+func newTestFeldera(t *testing.T, snapshot []string, chunks ...string) *felderaClient {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v0/pipelines/rebac/query" && r.URL.Query().Get("sql") == "SELECT * FROM derived_relationships" {
+			for _, row := range snapshot {
+				fmt.Fprintln(w, row)
+			}
+			return
+		}
+
+		if r.Method != http.MethodPost || r.URL.Path != "/v0/pipelines/rebac/egress/derived_relationships" || r.URL.Query().Get("format") != "json" {
+			http.Error(w, fmt.Sprintf("unexpected request %s %s", r.Method, r.URL), http.StatusNotFound)
+			return
+		}
+
+		for _, chunk := range chunks {
+			fmt.Fprintln(w, chunk)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return &felderaClient{httpClient: server.Client(), baseURL: server.URL, pipeline: "rebac"}
+}
+
+// watchStream is a grpc.ServerStreamingServer which records the responses
+// sent to it.
+type watchStream struct {
+	grpc.ServerStream
+	ctx       context.Context
+	responses []*authorizerpb.WatchResponse
+}
+
+func (s *watchStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *watchStream) Send(resp *authorizerpb.WatchResponse) error {
+	s.responses = append(s.responses, resp)
+	return nil
+}
+
+func TestNetRelationshipChanges(t *testing.T) {
+	var chunk egressChunk
+	err := json.Unmarshal([]byte(`{"sequence_number": 3, "json_data": [
+		{"insert": {"subject_type": "user", "subject_id": "jon", "subject_relation": "", "resource_type": "document", "resource_id": "1", "relationship": "can_view", "caveats": [], "expires_at": null, "depth": 2}},
+		{"delete": {"subject_type": "user", "subject_id": "jon", "subject_relation": "", "resource_type": "document", "resource_id": "1", "relationship": "can_view", "caveats": [], "expires_at": null, "depth": 3}},
+		{"delete": {"subject_type": "user", "subject_id": "bob", "subject_relation": "", "resource_type": "document", "resource_id": "1", "relationship": "can_view", "caveats": [], "expires_at": "2025-01-01 00:00:00", "depth": 1}}
+	]}`), &chunk)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rows := newDerivedRowCounts()
+	for _, row := range []egressRow{
+		{Relationship: Relationship{SubjectType: "user", SubjectID: "jon", ResourceType: "document", ResourceID: "1", Relation: "can_view", Caveats: []string{}}, Depth: 3},
+		{Relationship: Relationship{SubjectType: "user", SubjectID: "bob", ResourceType: "document", ResourceID: "1", Relation: "can_view", Caveats: []string{}, ExpiresAt: &Timestamp{time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}}, Depth: 1},
+	} {
+		rows.insert(rowKey{keyOf(row.Relationship), row.Depth})
+	}
+
+	changes, err := rows.netRelationshipChanges(chunk.JSONData)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the depth of user:jon changed, which isn't a change of its relationship
+	expected := []RelationshipChange{
+		{
+			Relationship: Relationship{
+				SubjectType:  "user",
+				SubjectID:    "bob",
+				ResourceType: "document",
+				ResourceID:   "1",
+				Relation:     "can_view",
+				Caveats:      []string{},
+				ExpiresAt:    &Timestamp{time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+			},
+			Revoked: true,
+		},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("expected %+v, got %+v", expected, changes)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	feldera := newTestFeldera(t,
+		[]string{`{"subject_type": "user", "subject_id": "*", "subject_relation": "", "resource_type": "document", "resource_id": "2", "relationship": "can_view", "caveats": []}`},
+		`{"sequence_number": 0, "json_data": [{"insert": {"subject_type": "user", "subject_id": "jon", "subject_relation": "", "resource_type": "document", "resource_id": "1", "relationship": "can_view", "caveats": []}}, {"insert": {"subject_type": "user", "subject_id": "jon", "subject_relation": "", "resource_type": "folder", "resource_id": "a", "relationship": "can_view", "caveats": []}}]}`,
+		``,
+		`{"sequence_number": 1, "json_data": [{"insert": {"subject_type": "user", "subject_id": "bob", "subject_relation": "", "resource_type": "document", "resource_id": "1", "relationship": "can_view", "caveats": []}}]}`,
+		`{"sequence_number": 2, "json_data": [{"delete": {"subject_type": "user", "subject_id": "*", "subject_relation": "", "resource_type": "document", "resource_id": "2", "relationship": "can_view", "caveats": []}}]}`,
+	)
+
+	server := &authorizerServer{feldera: feldera}
+	stream := &watchStream{ctx: context.Background()}
+	if err := server.Watch(&authorizerpb.WatchRequest{ResourceType: "document", SubjectType: "user", SubjectId: "jon"}, stream); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got []string
+	for _, resp := range stream.responses {
+		for _, update := range resp.GetUpdates() {
+			r := update.GetRelationship()
+			got = append(got, fmt.Sprintf("%s %s:%s#%s@%s:%s", update.GetOperation(), r.GetResourceType(), r.GetResourceId(), r.GetRelation(), r.GetSubjectType(), r.GetSubjectId()))
+		}
+	}
+
+	expected := []string{
+		"OPERATION_GRANTED document:1#can_view@user:jon",
+		"OPERATION_REVOKED document:2#can_view@user:*",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+
+	if len(stream.responses) != 2 {
+		t.Errorf("expected a response for each step with matching changes, got %d", len(stream.responses))
+	}
+}
+
+func TestWatch_Depths(t *testing.T) {
+	chunk := func(sequence int, changes ...string) string {
+		return fmt.Sprintf(`{"sequence_number": %d, "json_data": [%s]}`, sequence, strings.Join(changes, ", "))
+	}
+
+	insert := func(subjectID string, depth int) string {
+		return fmt.Sprintf(`{"insert": %s}`, derivedRow(subjectID, "1", depth))
+	}
+
+	remove := func(subjectID string, depth int) string {
+		return fmt.Sprintf(`{"delete": %s}`, derivedRow(subjectID, "1", depth))
+	}
+
+	// a relationship is granted by its first row and revoked by its last,
+	// whichever depths and chunks its rows are inserted and deleted at
+	feldera := newTestFeldera(t,
+		[]string{derivedRow("jon", "1", 1)},
+		chunk(0, insert("jon", 2)),
+		chunk(1, remove("jon", 1)),
+		chunk(2, insert("bob", 1), insert("bob", 2)),
+		chunk(3, remove("jon", 2)),
+		chunk(4, remove("bob", 2)),
+		chunk(5, insert("jon", 3)),
+		chunk(6, remove("bob", 1), insert("bob", 3)),
+		chunk(7, remove("bob", 3)),
+	)
+
+	server := &authorizerServer{feldera: feldera}
+	stream := &watchStream{ctx: context.Background()}
+	if err := server.Watch(&authorizerpb.WatchRequest{ResourceType: "document"}, stream); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got []string
+	for _, resp := range stream.responses {
+		for _, update := range resp.GetUpdates() {
+			got = append(got, fmt.Sprintf("%s user:%s", update.GetOperation(), update.GetRelationship().GetSubjectId()))
+		}
+	}
+
+	expected := []string{
+		"OPERATION_GRANTED user:bob",
+		"OPERATION_REVOKED user:jon",
+		"OPERATION_GRANTED user:jon",
+		"OPERATION_REVOKED user:bob",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestWatch_Dev(t *testing.T) {
+	server := &authorizerServer{}
+	err := server.Watch(&authorizerpb.WatchRequest{}, &watchStream{ctx: context.Background()})
+	if err == nil {
+		t.Fatalf("expected Watch to fail without a Feldera pipeline")
+	}
+}
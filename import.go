@@ -0,0 +1,404 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// errInvalidRelationship is returned by validateRelationship for relationships
+// the pipeline would ignore.
+var errInvalidRelationship = errors.New("invalid relationship")
+
+// validateRelationship returns an error unless r has every required column and
+// matches a type restriction of the rules, including its caveat and whether
+// it may expire, as the pipeline requires of the relationships it derives
+// from.
+func validateRelationship(rules SchemaQueryRules, r Relationship) error {
+	if r.ResourceType == "" || r.ResourceID == "" || r.Relation == "" || r.SubjectType == "" || r.SubjectID == "" {
+		return fmt.Errorf("%w '%s': resource_type, resource_id, relationship, subject_type and subject_id are required", errInvalidRelationship, r)
+	}
+
+	if !validCaveatContext(r.CaveatContext) {
+		return fmt.Errorf("%w '%s': caveat_context is not a JSON object", errInvalidRelationship, r)
+	}
+
+	if reason := rejectionReason(rules, r); reason != "" {
+		return fmt.Errorf("%w '%s': %s", errInvalidRelationship, r, reason)
+	}
+
+	return nil
+}
+
+// importRelationships reads the relationships of r in the format, validates
+// them against the rules, and calls write with each batch of up to batchSize
+// valid relationships. Invalid relationships fail the import, or are logged
+// and skipped if skipInvalid is set. It returns the number of relationships
+// written and skipped.
+func importRelationships(r io.Reader, format string, rules SchemaQueryRules, batchSize int, skipInvalid bool, write func([]Relationship) error) (int, int, error) {
+	var imported, skipped int
+	batch := make([]Relationship, 0, batchSize)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		if err := write(batch); err != nil {
+			return err
+		}
+
+		imported += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	err := readRelationships(r, format, func(relationship Relationship) error {
+		if err := validateRelationship(rules, relationship); err != nil {
+			if !skipInvalid {
+				return err
+			}
+
+			log.Printf("skipping %v", err)
+			skipped++
+			return nil
+		}
+
+		batch = append(batch, relationship)
+		if len(batch) < batchSize {
+			return nil
+		}
+
+		return flush()
+	})
+	if err != nil {
+		return imported, skipped, err
+	}
+
+	return imported, skipped, flush()
+}
+
+// copyRelationships writes the relationships to the relationships table of
+// bootstrap-pg.sql with COPY.
+func copyRelationships(ctx context.Context, tx pgx.Tx, relationships []Relationship) error {
+	rows := make([][]any, 0, len(relationships))
+	for _, r := range relationships {
+		row := newRelationshipRow(r)
+
+		var expiresAt *time.Time
+		if row.ExpiresAt != nil {
+			expiresAt = &row.ExpiresAt.Time
+		}
+
+		rows = append(rows, []any{row.SubjectType, row.SubjectID, row.SubjectRelation, row.ResourceType, row.ResourceID, row.Relation, row.CaveatName, row.CaveatContext, expiresAt})
+	}
+
+	_, err := tx.CopyFrom(ctx, pgx.Identifier{"relationships"}, relationshipColumns, pgx.CopyFromRows(rows))
+	return err
+}
+
+// insertRelationships pushes the relationships to the relationships table of
+// the pipeline.
+func (c *felderaClient) insertRelationships(ctx context.Context, relationships []Relationship) error {
+	changes := make([]ingressChange, 0, len(relationships))
+	for _, r := range relationships {
+		changes = append(changes, ingressChange{Insert: newRelationshipRow(r)})
+	}
+
+	return c.push(ctx, "relationships", changes)
+}
+
+// readFelderaRelationships reads the relationships table of the pipeline,
+// which also has the relationships written by WriteRelationships.
+func (c *felderaClient) readFelderaRelationships(ctx context.Context, fn func(Relationship) error) error {
+	return c.query(ctx, "SELECT * FROM relationships", func(row json.RawMessage) error {
+		var r Relationship
+		if err := json.Unmarshal(row, &r); err != nil {
+			return fmt.Errorf("failed to decode relationship: %w", err)
+		}
+
+		if r.CaveatName == "" {
+			r.CaveatContext = ""
+		}
+
+		return fn(r)
+	})
+}
+
+// relationshipWriter writes relationships in one of the formats read by
+// readRelationships.
+type relationshipWriter interface {
+	Write(r Relationship) error
+	Flush() error
+}
+
+func newRelationshipWriter(w io.Writer, format string) (relationshipWriter, error) {
+	switch format {
+	case "csv":
+		writer := csv.NewWriter(w)
+		return &csvRelationshipWriter{writer}, writer.Write(relationshipColumns)
+	case "ndjson":
+		buffered := bufio.NewWriter(w)
+		return &ndjsonRelationshipWriter{buffered, json.NewEncoder(buffered)}, nil
+	case "zed":
+		return &zedRelationshipWriter{bufio.NewWriter(w)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported relationships format '%s'", format)
+	}
+}
+
+type csvRelationshipWriter struct {
+	writer *csv.Writer
+}
+
+func (w *csvRelationshipWriter) Write(r Relationship) error {
+	var expiresAt string
+	if r.ExpiresAt != nil {
+		expiresAt = r.ExpiresAt.UTC().Format(timestampLayout)
+	}
+
+	return w.writer.Write([]string{r.SubjectType, r.SubjectID, r.SubjectRelation, r.ResourceType, r.ResourceID, r.Relation, r.CaveatName, r.CaveatContext, expiresAt})
+}
+
+func (w *csvRelationshipWriter) Flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+type ndjsonRelationshipWriter struct {
+	writer  *bufio.Writer
+	encoder *json.Encoder
+}
+
+func (w *ndjsonRelationshipWriter) Write(r Relationship) error {
+	return w.encoder.Encode(r)
+}
+
+func (w *ndjsonRelationshipWriter) Flush() error {
+	return w.writer.Flush()
+}
+
+type zedRelationshipWriter struct {
+	writer *bufio.Writer
+}
+
+// Write writes the relationship in the form parsed by parseRelationship.
+func (w *zedRelationshipWriter) Write(r Relationship) error {
+	tuple := Relationship{
+		SubjectType:     r.SubjectType,
+		SubjectID:       r.SubjectID,
+		SubjectRelation: r.SubjectRelation,
+		ResourceType:    r.ResourceType,
+		ResourceID:      r.ResourceID,
+		Relation:        r.Relation,
+	}.String()
+
+	if r.CaveatName != "" {
+		tuple += "[" + r.CaveatName
+		if r.CaveatContext != "" {
+			tuple += ":" + r.CaveatContext
+		}
+		tuple += "]"
+	}
+
+	if r.ExpiresAt != nil {
+		tuple += "[expiration:" + r.ExpiresAt.UTC().Format(time.RFC3339Nano) + "]"
+	}
+
+	_, err := fmt.Fprintln(w.writer, tuple)
+	return err
+}
+
+func (w *zedRelationshipWriter) Flush() error {
+	return w.writer.Flush()
+}
+
+func runImport(args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: import [flags] <relationships file>")
+		flags.PrintDefaults()
+	}
+	schemaPath := flags.String("schema-path", "schema.json", "Path to the (.json) schema file the relationships are validated against")
+	format := flags.String("format", "", "The format of the relationships file (csv, ndjson or zed), by default inferred from its extension")
+	to := flags.String("to", "postgres", "Where to import the relationships to: the relationships table in 'postgres', or the pipeline's relationships table in 'feldera'")
+	postgresURI := flags.String("postgres-uri", defaultPostgresURI, "The URI of the Postgres database with the relationships table")
+	felderaURL := flags.String("feldera-url", defaultFelderaURL, "The URL of the Feldera pipeline manager")
+	pipeline := flags.String("pipeline", "rebac", "The name of the Feldera pipeline")
+	batchSize := flags.Int("batch-size", 10000, "The number of relationships written at a time")
+	skipInvalid := flags.Bool("skip-invalid", false, "Skip relationships which aren't allowed by the schema instead of failing the import")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 || *batchSize <= 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	path := flags.Arg(0)
+	if *format == "" {
+		var err error
+		*format, err = relationshipFormat(path)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+	}
+
+	schema, err := loadSchema(*schemaPath)
+	if err != nil {
+		log.Fatalf("failed to load schema: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("failed to open relationships file: %v", err)
+	}
+	defer f.Close()
+
+	ctx := context.Background()
+	start := time.Now()
+	var total int
+	progress := func(n int) {
+		total += n
+		log.Printf("imported %d relationships (%.0f/s)", total, float64(total)/time.Since(start).Seconds())
+	}
+
+	var write func([]Relationship) error
+	var commit func() error
+	switch *to {
+	case "postgres":
+		conn, err := pgx.Connect(ctx, *postgresURI)
+		if err != nil {
+			log.Fatalf("failed to connect to postgres: %v", err)
+		}
+		defer conn.Close(ctx)
+
+		// the relationships are imported in a single transaction, so that a
+		// failed import leaves the table as it was
+		tx, err := conn.Begin(ctx)
+		if err != nil {
+			log.Fatalf("failed to begin transaction: %v", err)
+		}
+		defer tx.Rollback(ctx)
+
+		write = func(batch []Relationship) error {
+			if err := copyRelationships(ctx, tx, batch); err != nil {
+				return err
+			}
+
+			progress(len(batch))
+			return nil
+		}
+		commit = func() error { return tx.Commit(ctx) }
+	case "feldera":
+		feldera := &felderaClient{httpClient: http.DefaultClient, baseURL: *felderaURL, pipeline: *pipeline}
+		write = func(batch []Relationship) error {
+			if err := feldera.insertRelationships(ctx, batch); err != nil {
+				return err
+			}
+
+			progress(len(batch))
+			return nil
+		}
+		commit = func() error { return nil }
+	default:
+		log.Fatalf("unknown import destination '%s', expected postgres or feldera", *to)
+	}
+
+	rules, err := mapSchemaToQueryRules(schema)
+	if err != nil {
+		log.Fatalf("failed to map schema to rules: %v", err)
+	}
+
+	imported, skipped, err := importRelationships(f, *format, rules, *batchSize, *skipInvalid, write)
+	if err != nil {
+		log.Fatalf("failed to import '%s': %v", path, err)
+	}
+
+	if err := commit(); err != nil {
+		log.Fatalf("failed to commit the import: %v", err)
+	}
+
+	fmt.Printf("imported %d relationships, skipped %d, in %s\n", imported, skipped, time.Since(start).Round(time.Millisecond))
+}
+
+func runExport(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", "", "The format of the relationships (csv, ndjson or zed), by default inferred from the extension of -output, or ndjson")
+	output := flags.String("output", "", "Path to the file to export the relationships to. The relationships are written to stdout if empty")
+	from := flags.String("from", "postgres", "Where to export the relationships from: the relationships table in 'postgres', or the pipeline's relationships table in 'feldera'")
+	postgresURI := flags.String("postgres-uri", defaultPostgresURI, "The URI of the Postgres database with the relationships table")
+	felderaURL := flags.String("feldera-url", defaultFelderaURL, "The URL of the Feldera pipeline manager")
+	pipeline := flags.String("pipeline", "rebac", "The name of the Feldera pipeline")
+	flags.Parse(args)
+
+	if *format == "" {
+		*format = "ndjson"
+		if *output != "" {
+			var err error
+			*format, err = relationshipFormat(*output)
+			if err != nil {
+				log.Fatalf("%v", err)
+			}
+		}
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			log.Fatalf("failed to create '%s': %v", *output, err)
+		}
+		defer f.Close()
+
+		out = f
+	}
+
+	writer, err := newRelationshipWriter(out, *format)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	var exported int
+	write := func(r Relationship) error {
+		exported++
+		return writer.Write(r)
+	}
+
+	ctx := context.Background()
+	switch *from {
+	case "postgres":
+		conn, err := pgx.Connect(ctx, *postgresURI)
+		if err != nil {
+			log.Fatalf("failed to connect to postgres: %v", err)
+		}
+		defer conn.Close(ctx)
+
+		if err := readPostgresRelationships(ctx, conn, write); err != nil {
+			log.Fatalf("failed to export relationships: %v", err)
+		}
+	case "feldera":
+		feldera := &felderaClient{httpClient: http.DefaultClient, baseURL: *felderaURL, pipeline: *pipeline}
+		if err := feldera.readFelderaRelationships(ctx, write); err != nil {
+			log.Fatalf("failed to export relationships: %v", err)
+		}
+	default:
+		log.Fatalf("unknown export source '%s', expected postgres or feldera", *from)
+	}
+
+	if err := writer.Flush(); err != nil {
+		log.Fatalf("failed to write relationships: %v", err)
+	}
+
+	log.Printf("exported %d relationships", exported)
+}
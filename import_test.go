@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var importRules = SchemaQueryRules{
+	RelationTypeRestrictions: []RelationTypeRestriction{
+		{ResourceType: "document", Relation: "viewer", SubjectType: "user"},
+		{ResourceType: "document", Relation: "viewer", SubjectType: "user", Wildcard: true},
+		{ResourceType: "document", Relation: "viewer", SubjectType: "group", SubjectRelation: "member"},
+		{ResourceType: "document", Relation: "editor", SubjectType: "user", Caveat: "ip_allowlist", WithExpiration: true},
+	},
+}
+
+func TestReadZedRelationships(t *testing.T) {
+	input := `// the output of 'zed relationship read'
+document:1 viewer user:jon
+document:1 viewer group:eng#member
+
+document:2#editor@user:bob[ip_allowlist:{"cidr": "10.0.0.0/8"}][expiration:2030-01-01T00:00:00Z]
+document:3 editor user:alice [ip_allowlist]
+`
+
+	var relationships []string
+	err := readRelationships(strings.NewReader(input), "zed", func(r Relationship) error {
+		relationships = append(relationships, r.String()+" "+r.CaveatContext)
+		if r.ExpiresAt != nil {
+			relationships[len(relationships)-1] += " " + r.ExpiresAt.Format(time.RFC3339)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{
+		"document:1#viewer@user:jon ",
+		"document:1#viewer@group:eng#member ",
+		`document:2#editor@user:bob[ip_allowlist] {"cidr": "10.0.0.0/8"} 2030-01-01T00:00:00Z`,
+		"document:3#editor@user:alice[ip_allowlist] ",
+	}
+	if !reflect.DeepEqual(relationships, expected) {
+		t.Errorf("expected %q, got %q", expected, relationships)
+	}
+
+	err = readRelationships(strings.NewReader("document:1 viewer user:jon\ndocument:1 viewer\n"), "zed", func(Relationship) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected an error on line 2, got %v", err)
+	}
+}
+
+func TestImportRelationships(t *testing.T) {
+	input := `document:1 viewer user:jon
+document:1 viewer user:*
+document:1 viewer group:eng#member
+document:1 viewer user:bob[ip_allowlist]
+document:2 editor user:bob[ip_allowlist:{"cidr": "10.0.0.0/8"}][expiration:2030-01-01T00:00:00Z]
+document:2 owner user:bob
+document:3 viewer user:alice
+`
+
+	var batches [][]string
+	write := func(batch []Relationship) error {
+		var tuples []string
+		for _, r := range batch {
+			tuples = append(tuples, r.String())
+		}
+
+		batches = append(batches, tuples)
+		return nil
+	}
+
+	imported, skipped, err := importRelationships(strings.NewReader(input), "zed", importRules, 2, true, write)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if imported != 5 || skipped != 2 {
+		t.Errorf("expected 5 relationships imported and 2 skipped, got %d and %d", imported, skipped)
+	}
+
+	expected := [][]string{
+		{"document:1#viewer@user:jon", "document:1#viewer@user:*"},
+		{"document:1#viewer@group:eng#member", "document:2#editor@user:bob[ip_allowlist]"},
+		{"document:3#viewer@user:alice"},
+	}
+	if !reflect.DeepEqual(batches, expected) {
+		t.Errorf("expected the batches %v, got %v", expected, batches)
+	}
+
+	// without -skip-invalid the first invalid relationship fails the import
+	_, _, err = importRelationships(strings.NewReader(input), "zed", importRules, 2, false, func([]Relationship) error { return nil })
+	if !errors.Is(err, errInvalidRelationship) || !strings.Contains(err.Error(), "line 4") {
+		t.Errorf("expected an invalid relationship on line 4, got %v", err)
+	}
+}
+
+func TestImportRelationships_Feldera(t *testing.T) {
+	var pushes [][]relationshipRow
+	feldera := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v0/pipelines/rebac/ingress/relationships" {
+			http.Error(w, "unexpected request", http.StatusNotFound)
+			return
+		}
+
+		var rows []relationshipRow
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var change struct {
+				Insert relationshipRow `json:"insert"`
+			}
+			if err := json.Unmarshal(scanner.Bytes(), &change); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			rows = append(rows, change.Insert)
+		}
+
+		pushes = append(pushes, rows)
+	}))
+	defer feldera.Close()
+
+	client := &felderaClient{httpClient: feldera.Client(), baseURL: feldera.URL, pipeline: "rebac"}
+
+	input := "subject_type,subject_id,subject_relation,resource_type,resource_id,relationship\n" +
+		"user,jon,,document,1,viewer\n" +
+		"user,bob,,document,1,viewer\n" +
+		"user,alice,,document,2,viewer\n"
+
+	imported, _, err := importRelationships(strings.NewReader(input), "csv", importRules, 2, false, func(batch []Relationship) error {
+		return client.insertRelationships(context.Background(), batch)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if imported != 3 || len(pushes) != 2 || len(pushes[0]) != 2 || len(pushes[1]) != 1 {
+		t.Fatalf("expected 3 relationships pushed in 2 batches, got %v", pushes)
+	}
+
+	if row := pushes[1][0]; row.SubjectID != "alice" || row.CaveatContext != "{}" {
+		t.Errorf("unexpected row %+v", row)
+	}
+}
+
+func TestExportRelationships_RoundTrip(t *testing.T) {
+	expiresAt := &Timestamp{time.Date(2030, 1, 1, 12, 30, 0, 0, time.UTC)}
+	relationships := []Relationship{
+		{SubjectType: "user", SubjectID: "jon", ResourceType: "document", ResourceID: "1", Relation: "viewer"},
+		{SubjectType: "group", SubjectID: "eng", SubjectRelation: "member", ResourceType: "document", ResourceID: "1", Relation: "viewer"},
+		{SubjectType: "user", SubjectID: "bob", ResourceType: "document", ResourceID: "2", Relation: "editor", CaveatName: "ip_allowlist", CaveatContext: `{"cidr":"10.0.0.0/8"}`, ExpiresAt: expiresAt},
+	}
+
+	for _, format := range []string{"csv", "ndjson", "zed"} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			writer, err := newRelationshipWriter(&buf, format)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			for _, r := range relationships {
+				if err := writer.Write(r); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			if err := writer.Flush(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var read []Relationship
+			err = readRelationships(&buf, format, func(r Relationship) error {
+				read = append(read, r)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(read, relationships) {
+				t.Errorf("expected %v, got %v", relationships, read)
+			}
+		})
+	}
+}
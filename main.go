@@ -13,27 +13,75 @@ import (
 var schemaPathFlag = flag.String("schema-path", "schema.json", "Path to the (.json) schema file")
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "serve":
+			runServe(os.Args[2:])
+			return
+		case "program":
+			runProgram(os.Args[2:])
+			return
+		case "cycles":
+			runCycles(os.Args[2:])
+			return
+		case "test":
+			runTest(os.Args[2:])
+			return
+		case "pipeline":
+			runPipeline(os.Args[2:])
+			return
+		case "sync-rules":
+			runSyncRules(os.Args[2:])
+			return
+		case "import":
+			runImport(os.Args[2:])
+			return
+		case "export":
+			runExport(os.Args[2:])
+			return
+		case "rejected":
+			runRejected(os.Args[2:])
+			return
+		case "check-compat":
+			runCheckCompat(os.Args[2:])
+			return
+		}
+	}
+
 	flag.Parse()
 
-	schemaPath := *schemaPathFlag
+	schema, err := loadSchema(*schemaPathFlag)
+	if err != nil {
+		log.Fatalf("failed to load schema: %v", err)
+	}
+
+	rules, err := mapSchemaToQueryRules(schema)
+	if err != nil {
+		log.Fatalf("failed to map schema to rules: %v", err)
+	}
+
+	fmt.Println(rules.ToSQL())
+}
 
+// loadSchema reads a (.json) schema file.
+func loadSchema(schemaPath string) (*authorizerpb.Schema, error) {
 	jsonBytes, err := os.ReadFile(schemaPath)
 	if err != nil {
-		log.Fatalf("failed to open schema file: %v", err)
+		return nil, fmt.Errorf("failed to open schema file: %w", err)
 	}
 
 	var schema authorizerpb.Schema
 	if err := protojson.Unmarshal(jsonBytes, &schema); err != nil {
-		log.Fatalf("failed to unmarshal schema: %v", err)
+		return nil, fmt.Errorf("failed to unmarshal schema: %w", err)
 	}
 
-	rules := mapSchemaToQueryRules(&schema)
-
-	fmt.Println(rules.ToSQL())
+	return &schema, nil
 }
 
-// map authorizerpb.Schema to SchemaQueryRules
-func mapSchemaToQueryRules(schema *authorizerpb.Schema) SchemaQueryRules {
+// map authorizerpb.Schema to SchemaQueryRules, or return an error if the
+// negated rules of the schema can't be stratified or may exclude subjects from
+// the wildcard subject
+func mapSchemaToQueryRules(schema *authorizerpb.Schema) (SchemaQueryRules, error) {
 
 	var typeRestrictions []RelationTypeRestriction
 	for typeName, typeDefinition := range schema.GetTypeDefinitions() {
@@ -44,111 +92,56 @@ func mapSchemaToQueryRules(schema *authorizerpb.Schema) SchemaQueryRules {
 					Relation:        relationName,
 					SubjectType:     subjectTypeRestriction.GetResourceType(),
 					SubjectRelation: subjectTypeRestriction.GetRelation(),
+					Wildcard:        subjectTypeRestriction.GetWildcard(),
+					Caveat:          subjectTypeRestriction.GetCaveat(),
+					WithExpiration:  subjectTypeRestriction.GetWithExpiration(),
 				})
 			}
 		}
 	}
 
-	unaryRules, binaryRules := rules(schema)
+	unaryRules, binaryRules, negatedBinaryRules, err := rules(schema)
+	if err != nil {
+		return SchemaQueryRules{}, err
+	}
 
-	return SchemaQueryRules{
+	queryRules := SchemaQueryRules{
 		RelationTypeRestrictions: typeRestrictions,
 		UnaryRules:               unaryRules,
 		BinaryRules:              binaryRules,
+		NegatedBinaryRules:       negatedBinaryRules,
 	}
-}
 
-func expandPermissionExpressionRef(
-	typedef *authorizerpb.TypeDefinition,
-	permissionName string,
-	exp *authorizerpb.PermissionExpressionRef,
-) ([]UnaryRule, []BinaryRule) {
-	var unaryRules []UnaryRule
-	var binaryRules []BinaryRule
-
-	resourceType := typedef.GetName()
-
-	switch permissionExp := exp.GetExpression().(type) {
-	case *authorizerpb.PermissionExpressionRef_UnaryExpression:
-		rule := UnaryRule{
-			ResourceType:    resourceType,
-			SourceRelation:  permissionExp.UnaryExpression.GetSourceRelation(),
-			DerivedRelation: permissionName,
-		}
-		unaryRules = append(unaryRules, rule)
-	case *authorizerpb.PermissionExpressionRef_HierarchicalExpression:
-		// get the type restrictions for base relation (e.g. parent)
-		baseRelationName := permissionExp.HierarchicalExpression.GetBase()
-		baseRelation, ok := typedef.GetRelations()[baseRelationName]
-		if !ok {
-			panic(fmt.Sprintf("undefined relation '%s'", baseRelationName))
-		}
-
-		for _, typeRestriction := range baseRelation.GetTypeRestrictions() {
-			binaryRules = append(binaryRules, BinaryRule{
-				FirstResourceType:  typeRestriction.GetResourceType(),
-				FirstRelation:      permissionExp.HierarchicalExpression.GetTarget(),
-				SecondResourceType: resourceType,
-				SecondRelation:     baseRelationName,
-				DerivedRelation:    permissionName,
-			})
-		}
-	case *authorizerpb.PermissionExpressionRef_SetExpression:
-		unary, binary := expandSetExpression(typedef, permissionName, permissionExp.SetExpression)
-		unaryRules = append(unaryRules, unary...)
-		binaryRules = append(binaryRules, binary...)
-	default:
-		panic("unexpected PermissionExpressionRef type")
+	negatedBinaryRules, err = stratify(queryRules)
+	if err != nil {
+		return SchemaQueryRules{}, err
 	}
 
-	return unaryRules, binaryRules
-}
-
-func expandSetExpression(
-	typedef *authorizerpb.TypeDefinition,
-	permissionName string,
-	setExp *authorizerpb.PermissionSetExpressionRef,
-) ([]UnaryRule, []BinaryRule) {
-	var unaryRules []UnaryRule
-	var binaryRules []BinaryRule
-
-	switch exp := setExp.SetExpression.(type) {
-	case *authorizerpb.PermissionSetExpressionRef_Union_:
-		for _, operand := range exp.Union.Operands {
-			operandUnaryRules, operandBinaryRules := expandPermissionExpressionRef(typedef, permissionName, operand)
-			unaryRules = append(unaryRules, operandUnaryRules...)
-			binaryRules = append(binaryRules, operandBinaryRules...)
-		}
-	case *authorizerpb.PermissionSetExpressionRef_Intersection_:
-		if len(exp.Intersection.Operands) != 2 {
-			panic("intersection must have exactly two operands")
-		}
-
-		for _, operand := range exp.Intersection.Operands {
-			operandUnaryRules, operandBinaryRules := expandPermissionExpressionRef(typedef, permissionName, operand)
-			unaryRules = append(unaryRules, operandUnaryRules...)
-			binaryRules = append(binaryRules, operandBinaryRules...)
-		}
-	default:
-		panic("unexpected PermissionSetExpression type")
+	queryRules.NegatedBinaryRules = negatedBinaryRules
+	if err := checkWildcardExclusions(queryRules); err != nil {
+		return SchemaQueryRules{}, err
 	}
 
-	return unaryRules, binaryRules
+	return queryRules, nil
 }
 
-func rules(schema *authorizerpb.Schema) ([]UnaryRule, []BinaryRule) {
+func rules(schema *authorizerpb.Schema) ([]UnaryRule, []BinaryRule, []BinaryRule, error) {
 	var unaryRules []UnaryRule
 	var binaryRules []BinaryRule
+	var negatedBinaryRules []BinaryRule
 
 	for _, typeDefinition := range schema.GetTypeDefinitions() {
 		for permissionName, permission := range typeDefinition.GetPermissions() {
+			_, unary, binary, negated, err := expandPermissionExpressionRefV2(schema, typeDefinition, permissionName, permission.GetExpression())
+			if err != nil {
+				return nil, nil, nil, err
+			}
 
-			permissionExp := permission.GetExpression()
-			_, unary, binary := expandPermissionExpressionRefV2(schema, typeDefinition, permissionName, permissionExp)
 			unaryRules = append(unaryRules, unary...)
 			binaryRules = append(binaryRules, binary...)
+			negatedBinaryRules = append(negatedBinaryRules, negated...)
 		}
 	}
 
-	return unaryRules, binaryRules
+	return unaryRules, binaryRules, negatedBinaryRules, nil
 }
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// pipelinePollInterval is how often the status of a pipeline is polled while
+// waiting for it to compile or to change its deployment status.
+const pipelinePollInterval = time.Second
+
+// felderaPipeline is the status of a pipeline as reported by the pipeline
+// manager.
+type felderaPipeline struct {
+	Name             string `json:"name"`
+	ProgramStatus    string `json:"program_status"`
+	DeploymentStatus string `json:"deployment_status"`
+
+	ProgramError    *programError    `json:"program_error"`
+	DeploymentError *deploymentError `json:"deployment_error"`
+}
+
+// programError are the errors of the compilation of a pipeline's program.
+type programError struct {
+	SQLCompilation *struct {
+		Messages []sqlCompilerMessage `json:"messages"`
+	} `json:"sql_compilation"`
+	RustCompilation *struct {
+		Stderr string `json:"stderr"`
+	} `json:"rust_compilation"`
+	SystemError *string `json:"system_error"`
+}
+
+// sqlCompilerMessage is an error or warning of the SQL compiler, positioned
+// in the program.
+type sqlCompilerMessage struct {
+	StartLineNumber int    `json:"start_line_number"`
+	StartColumn     int    `json:"start_column"`
+	Warning         bool   `json:"warning"`
+	ErrorType       string `json:"error_type"`
+	Message         string `json:"message"`
+}
+
+// String formats the errors of the compiler, the errors of the SQL compiler
+// positioned in program.sql.
+func (e *programError) String() string {
+	if e == nil {
+		return "no error reported"
+	}
+
+	var lines []string
+	if e.SQLCompilation != nil {
+		for _, m := range e.SQLCompilation.Messages {
+			if !m.Warning {
+				lines = append(lines, fmt.Sprintf("program.sql:%d:%d: %s: %s", m.StartLineNumber, m.StartColumn, m.ErrorType, m.Message))
+			}
+		}
+	}
+
+	if e.RustCompilation != nil && e.RustCompilation.Stderr != "" {
+		lines = append(lines, e.RustCompilation.Stderr)
+	}
+
+	if e.SystemError != nil {
+		lines = append(lines, *e.SystemError)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+type deploymentError struct {
+	Message string `json:"message"`
+}
+
+// pipelineRequest creates or replaces a pipeline.
+type pipelineRequest struct {
+	Name          string         `json:"name"`
+	Description   string         `json:"description"`
+	RuntimeConfig map[string]any `json:"runtime_config"`
+	ProgramConfig map[string]any `json:"program_config"`
+	ProgramCode   string         `json:"program_code"`
+}
+
+// putPipeline creates the pipeline with the program, or replaces the program
+// of the existing pipeline, which the pipeline manager then compiles.
+func (c *felderaClient) putPipeline(ctx context.Context, description, program string) error {
+	body, err := json.Marshal(pipelineRequest{
+		Name:          c.pipeline,
+		Description:   description,
+		RuntimeConfig: map[string]any{},
+		ProgramConfig: map[string]any{},
+		ProgramCode:   program,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.pipelineURL(""), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+// getPipeline returns the status of the pipeline.
+func (c *felderaClient) getPipeline(ctx context.Context) (*felderaPipeline, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.pipelineURL(""), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var pipeline felderaPipeline
+	if err := json.NewDecoder(resp.Body).Decode(&pipeline); err != nil {
+		return nil, fmt.Errorf("failed to decode pipeline '%s': %w", c.pipeline, err)
+	}
+
+	return &pipeline, nil
+}
+
+// pipelineAction asks the pipeline manager to start, pause or shut down the
+// pipeline, which it does asynchronously.
+func (c *felderaClient) pipelineAction(ctx context.Context, action string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.pipelineURL("/"+action), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+func (c *felderaClient) pipelineURL(path string) string {
+	return fmt.Sprintf("%s/v0/pipelines/%s%s", c.baseURL, url.PathEscape(c.pipeline), path)
+}
+
+// errProgramFailed is returned by waitForProgram if the program of the
+// pipeline failed to compile.
+var errProgramFailed = errors.New("the program failed to compile")
+
+// waitForProgram polls the pipeline until its program has compiled, and
+// returns an error wrapping errProgramFailed with the compiler's errors if it
+// failed to.
+func (c *felderaClient) waitForProgram(ctx context.Context, interval time.Duration) error {
+	for {
+		pipeline, err := c.getPipeline(ctx)
+		if err != nil {
+			return err
+		}
+
+		switch pipeline.ProgramStatus {
+		case "Success":
+			return nil
+		case "SqlError", "RustError", "SystemError":
+			return fmt.Errorf("%w (%s):\n%s", errProgramFailed, pipeline.ProgramStatus, pipeline.ProgramError)
+		}
+
+		if err := sleep(ctx, interval); err != nil {
+			return fmt.Errorf("the program is still compiling (%s): %w", pipeline.ProgramStatus, err)
+		}
+	}
+}
+
+// waitForDeployment polls the pipeline until its deployment status is the
+// given status, and returns an error if the deployment failed.
+func (c *felderaClient) waitForDeployment(ctx context.Context, status string, interval time.Duration) error {
+	for {
+		pipeline, err := c.getPipeline(ctx)
+		if err != nil {
+			return err
+		}
+
+		if pipeline.DeploymentStatus == status {
+			return nil
+		}
+
+		if pipeline.DeploymentStatus == "Failed" {
+			message := "unknown error"
+			if pipeline.DeploymentError != nil {
+				message = pipeline.DeploymentError.Message
+			}
+
+			return fmt.Errorf("the pipeline failed: %s", message)
+		}
+
+		if err := sleep(ctx, interval); err != nil {
+			return fmt.Errorf("the pipeline is still %s: %w", pipeline.DeploymentStatus, err)
+		}
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// pipelineActions are the actions of the pipeline command which change the
+// deployment status of the pipeline, by the endpoint of the action and the
+// status the pipeline is in once it has taken effect.
+var pipelineActions = map[string]struct {
+	endpoint, status string
+}{
+	"start": {"start", "Running"},
+	"pause": {"pause", "Paused"},
+	"stop":  {"shutdown", "Shutdown"},
+}
+
+func runPipeline(args []string) {
+	flags := flag.NewFlagSet("pipeline", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: pipeline [flags] deploy|start|pause|stop|status")
+		flags.PrintDefaults()
+	}
+	felderaURL := flags.String("feldera-url", defaultFelderaURL, "The URL of the Feldera pipeline manager")
+	pipelineName := flags.String("pipeline", "rebac", "The name of the Feldera pipeline")
+	programPath := flags.String("program", "", "Path to the SQL program to deploy. The program is generated with -schema-path, -max-depth, -strata and -postgres-output if empty")
+	options := programFlags(flags)
+	timeout := flags.Duration("timeout", 10*time.Minute, "How long to wait for the program to compile, or for the pipeline to change its status")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	feldera := &felderaClient{httpClient: http.DefaultClient, baseURL: *felderaURL, pipeline: *pipelineName}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	command := flags.Arg(0)
+	switch command {
+	case "deploy":
+		var program string
+		if *programPath != "" {
+			sql, err := os.ReadFile(*programPath)
+			if err != nil {
+				log.Fatalf("failed to read program: %v", err)
+			}
+
+			program = string(sql)
+		} else {
+			opts, err := options()
+			if err != nil {
+				log.Fatalf("failed to generate program: %v", err)
+			}
+
+			sql, err := GenerateProgram(opts)
+			if err != nil {
+				log.Fatalf("failed to generate program: %v", err)
+			}
+
+			program = sql
+		}
+
+		if err := feldera.putPipeline(ctx, "A Feldera pipeline deriving the relationship graph of a ReBAC schema.", program); err != nil {
+			log.Fatalf("failed to deploy pipeline '%s': %v", *pipelineName, err)
+		}
+
+		log.Printf("waiting for the program of pipeline '%s' to compile", *pipelineName)
+		if err := feldera.waitForProgram(ctx, pipelinePollInterval); err != nil {
+			log.Fatalf("failed to compile pipeline '%s': %v", *pipelineName, err)
+		}
+
+		log.Printf("compiled the program of pipeline '%s'", *pipelineName)
+	case "start", "pause", "stop":
+		action := pipelineActions[command]
+		if err := feldera.pipelineAction(ctx, action.endpoint); err != nil {
+			log.Fatalf("failed to %s pipeline '%s': %v", command, *pipelineName, err)
+		}
+
+		if err := feldera.waitForDeployment(ctx, action.status, pipelinePollInterval); err != nil {
+			log.Fatalf("failed to %s pipeline '%s': %v", command, *pipelineName, err)
+		}
+
+		log.Printf("pipeline '%s' is %s", *pipelineName, strings.ToLower(action.status))
+	case "status":
+		pipeline, err := feldera.getPipeline(ctx)
+		if err != nil {
+			log.Fatalf("failed to get pipeline '%s': %v", *pipelineName, err)
+		}
+
+		fmt.Printf("program: %s\ndeployment: %s\n", pipeline.ProgramStatus, pipeline.DeploymentStatus)
+		if strings.HasSuffix(pipeline.ProgramStatus, "Error") {
+			fmt.Println(pipeline.ProgramError)
+		}
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+}
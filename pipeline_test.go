@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testPipelineManager is a fake of the pipeline manager's /v0/pipelines API,
+// which reports each status of a pipeline's program once before the next,
+// and changes its deployment status when an action is posted.
+type testPipelineManager struct {
+	mu       sync.Mutex
+	program  string
+	statuses []string
+	failure  *programError
+
+	deployment string
+	actions    []string
+}
+
+func newTestPipelineManager(t *testing.T, manager *testPipelineManager) *felderaClient {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		manager.mu.Lock()
+		defer manager.mu.Unlock()
+
+		path, ok := strings.CutPrefix(r.URL.Path, "/v0/pipelines/rebac")
+		if !ok {
+			http.Error(w, "unknown pipeline", http.StatusNotFound)
+			return
+		}
+
+		switch {
+		case r.Method == http.MethodPut && path == "":
+			var req pipelineRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name != "rebac" {
+				http.Error(w, "invalid pipeline", http.StatusBadRequest)
+				return
+			}
+
+			manager.program = req.ProgramCode
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodGet && path == "":
+			status := manager.statuses[0]
+			if len(manager.statuses) > 1 {
+				manager.statuses = manager.statuses[1:]
+			}
+
+			json.NewEncoder(w).Encode(map[string]any{
+				"name":              "rebac",
+				"program_status":    status,
+				"program_error":     manager.failure,
+				"deployment_status": manager.deployment,
+			})
+		case r.Method == http.MethodPost:
+			action := strings.TrimPrefix(path, "/")
+			manager.actions = append(manager.actions, action)
+			manager.deployment = map[string]string{"start": "Running", "pause": "Paused", "shutdown": "Shutdown"}[action]
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			http.Error(w, "unexpected request", http.StatusMethodNotAllowed)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return &felderaClient{httpClient: server.Client(), baseURL: server.URL, pipeline: "rebac"}
+}
+
+func TestPipeline_Deploy(t *testing.T) {
+	manager := &testPipelineManager{statuses: []string{"Pending", "CompilingSql", "CompilingRust", "Success"}, deployment: "Shutdown"}
+	feldera := newTestPipelineManager(t, manager)
+
+	program, err := GenerateProgram(ProgramOptions{Strata: 1})
+	if err != nil {
+		t.Fatalf("failed to generate program: %v", err)
+	}
+
+	ctx := context.Background()
+	if err := feldera.putPipeline(ctx, "test", program); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	manager.mu.Lock()
+	deployed := manager.program
+	manager.mu.Unlock()
+
+	if deployed != program {
+		t.Errorf("expected the generated program to be deployed")
+	}
+
+	if err := feldera.waitForProgram(ctx, time.Millisecond); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, action := range []string{"start", "pause", "stop"} {
+		if err := feldera.pipelineAction(ctx, pipelineActions[action].endpoint); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if err := feldera.waitForDeployment(ctx, pipelineActions[action].status, time.Millisecond); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	if actions := strings.Join(manager.actions, ","); actions != "start,pause,shutdown" {
+		t.Errorf("expected the actions start, pause and shutdown, got %s", actions)
+	}
+}
+
+func TestPipeline_CompileError(t *testing.T) {
+	var failure programError
+	if err := json.Unmarshal([]byte(`{
+		"sql_compilation": {"exit_code": 1, "messages": [
+			{"start_line_number": 12, "start_column": 5, "warning": true, "error_type": "Unused", "message": "unused view"},
+			{"start_line_number": 42, "start_column": 7, "warning": false, "error_type": "Error parsing SQL", "message": "Encountered \"FORM\""}
+		]},
+		"rust_compilation": null,
+		"system_error": null
+	}`), &failure); err != nil {
+		t.Fatalf("failed to decode the program error: %v", err)
+	}
+
+	manager := &testPipelineManager{statuses: []string{"CompilingSql", "SqlError"}, failure: &failure}
+	feldera := newTestPipelineManager(t, manager)
+
+	err := feldera.waitForProgram(context.Background(), time.Millisecond)
+	if !errors.Is(err, errProgramFailed) {
+		t.Fatalf("expected the program to fail to compile, got %v", err)
+	}
+
+	if !strings.Contains(err.Error(), `program.sql:42:7: Error parsing SQL: Encountered "FORM"`) {
+		t.Errorf("expected the error to be reported with its position, got %v", err)
+	}
+
+	if strings.Contains(err.Error(), "unused view") {
+		t.Errorf("expected warnings not to be reported, got %v", err)
+	}
+}
+
+func TestPipeline_Timeout(t *testing.T) {
+	manager := &testPipelineManager{statuses: []string{"CompilingRust"}}
+	feldera := newTestPipelineManager(t, manager)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := feldera.waitForProgram(ctx, time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the wait to time out, got %v", err)
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	authorizerpb "github.com/jon-whit/feldera-rebac/protos/gen/go/authorizer/v1alpha1"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+// newTestPostgresServer returns an authorizerServer backed by a postgresStore
+// of a mock of the Postgres database of bootstrap-pg.sql.
+func newTestPostgresServer(t *testing.T) (*authorizerServer, pgxmock.PgxPoolIface) {
+	t.Helper()
+
+	pool, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("failed to create the postgres mock: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := pool.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+
+		pool.Close()
+	})
+
+	return &authorizerServer{store: &postgresStore{pool: pool}}, pool
+}
+
+// derivedRows returns rows of the derived_relationships table.
+func derivedRows(rows ...[]any) *pgxmock.Rows {
+	result := pgxmock.NewRows(strings.Split(derivedRelationshipColumns, ", "))
+	for _, row := range rows {
+		result.AddRow(row...)
+	}
+
+	return result
+}
+
+func TestPostgresStore_Check(t *testing.T) {
+	server, pool := newTestPostgresServer(t)
+
+	expired := time.Now().Add(-time.Minute)
+	unexpired := time.Now().Add(time.Hour)
+
+	pool.ExpectQuery("from depth_limited_subjects").
+		WithArgs("user", "", []string{"jon", "*"}).
+		WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(true))
+	pool.ExpectQuery("from derived_relationships").
+		WithArgs("document", []string{"1", "2", "3", "4"}, "can_view", "user", "", []string{"jon", "*"}).
+		WillReturnRows(derivedRows(
+			[]any{"user", "jon", "", "document", "1", "can_view", "[]", (*time.Time)(nil)},
+			[]any{"user", "*", "", "document", "2", "can_view", "[]", &unexpired},
+			[]any{"user", "jon", "", "document", "3", "can_view", "[]", &expired},
+		))
+
+	resp, err := server.Check(context.Background(), &authorizerpb.CheckRequest{
+		ResourceType: "document",
+		ResourceIds:  []string{"1", "2", "3", "4"},
+		Relation:     "can_view",
+		SubjectType:  "user",
+		SubjectId:    "jon",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the depth limit is only reported for the resources which may be missing
+	expected := map[string][2]bool{"1": {true, false}, "2": {true, false}, "3": {false, true}, "4": {false, true}}
+	for resourceID, want := range expected {
+		result := resp.GetResultsByResourceId()[resourceID]
+		if got := [2]bool{result.GetHasRelation(), result.GetDepthLimitReached()}; got != want {
+			t.Errorf("expected has_relation and depth_limit_reached %v for document:%s, got %v", want, resourceID, got)
+		}
+	}
+}
+
+func TestPostgresStore_Caveats(t *testing.T) {
+	server, pool := newTestPostgresServer(t)
+
+	pool.ExpectQuery("from derived_relationships").
+		WithArgs("document", []string{"1"}, "can_view").
+		WillReturnRows(derivedRows(
+			[]any{"user", "jon", "", "document", "1", "can_view", `[{"name": "a", "context": {}}, {"name": "b", "context": {"x": 1}}]`, (*time.Time)(nil)},
+		))
+
+	resources, err := server.store.ResourceRelationships(context.Background(), "document", []string{"1"}, "can_view")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	relationships := resources["1"]
+	if len(relationships) != 1 {
+		t.Fatalf("expected 1 relationship, got %v", relationships)
+	}
+
+	// the caveats are decoded from the JSON array of the row
+	if expected := []string{`{"name": "a", "context": {}}`, `{"name": "b", "context": {"x": 1}}`}; !slices.Equal(relationships[0].Caveats, expected) {
+		t.Errorf("expected the caveats %v, got %v", expected, relationships[0].Caveats)
+	}
+}
+
+func TestPostgresStore_CheckBulk(t *testing.T) {
+	server, pool := newTestPostgresServer(t)
+
+	batch := pool.ExpectBatch()
+	batch.ExpectQuery("from derived_relationships").
+		WithArgs("document", "1", "can_view", "user", "", []string{"jon", "*"}).
+		WillReturnRows(derivedRows([]any{"user", "jon", "", "document", "1", "can_view", "[]", (*time.Time)(nil)}))
+	batch.ExpectQuery("from depth_limited_subjects").
+		WithArgs("user", "", []string{"jon", "*"}).
+		WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+	batch.ExpectQuery("from derived_relationships").
+		WithArgs("document", "1", "can_view", "user", "", []string{"bob", "*"}).
+		WillReturnRows(derivedRows())
+	batch.ExpectQuery("from depth_limited_subjects").
+		WithArgs("user", "", []string{"bob", "*"}).
+		WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(true))
+
+	resp, err := server.CheckBulk(context.Background(), &authorizerpb.CheckBulkRequest{
+		Items: []*authorizerpb.CheckBulkItem{
+			{ResourceType: "document", ResourceId: "1", Relation: "can_view", SubjectType: "user", SubjectId: "jon"},
+			{ResourceType: "document", ResourceId: "1", Relation: "can_view", SubjectType: "user", SubjectId: "bob"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(resp.GetResults()) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(resp.GetResults()))
+	}
+
+	if result := resp.GetResults()[0].GetResult(); !result.GetHasRelation() || result.GetDepthLimitReached() {
+		t.Errorf("expected user:jon to have can_view on document:1, got %v", result)
+	}
+
+	if result := resp.GetResults()[1].GetResult(); result.GetHasRelation() || !result.GetDepthLimitReached() {
+		t.Errorf("expected user:bob not to have can_view on document:1 with the depth limit reached, got %v", result)
+	}
+}
+
+func TestPostgresStore_Lookups(t *testing.T) {
+	server, pool := newTestPostgresServer(t)
+
+	pool.ExpectQuery("select distinct resource_id").
+		WithArgs("user", []string{"jon", "*"}, "", "can_view", "document").
+		WillReturnRows(pgxmock.NewRows([]string{"resource_id"}).AddRow("1").AddRow("public"))
+	pool.ExpectQuery("select distinct subject_id").
+		WithArgs("document", "public", "can_view", "user", "").
+		WillReturnRows(pgxmock.NewRows([]string{"subject_id"}).AddRow("*").AddRow("jon"))
+
+	resources, err := server.LookupResources(context.Background(), &authorizerpb.LookupResourcesRequest{
+		ResourceType: "document",
+		Relation:     "can_view",
+		SubjectType:  "user",
+		SubjectId:    "jon",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if expected := []string{"1", "public"}; !reflect.DeepEqual(resources.GetResourceIds(), expected) {
+		t.Errorf("expected %v, got %v", expected, resources.GetResourceIds())
+	}
+
+	subjects, err := server.LookupSubjects(context.Background(), &authorizerpb.LookupSubjectsRequest{
+		ResourceType: "document",
+		ResourceId:   "public",
+		Relation:     "can_view",
+		SubjectType:  "user",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if expected := []string{"*", "jon"}; !reflect.DeepEqual(subjects.GetSubjectIds(), expected) {
+		t.Errorf("expected %v, got %v", expected, subjects.GetSubjectIds())
+	}
+}
+
+func TestPostgresStore_DepthLimitReached(t *testing.T) {
+	server, pool := newTestPostgresServer(t)
+
+	for _, reached := range []bool{true, false} {
+		pool.ExpectQuery("from depth_limited_subjects").
+			WithArgs("group", "member", []string{"eng"}).
+			WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(reached))
+
+		got, err := server.store.DepthLimitReached(context.Background(), "group", "member", []string{"eng"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got != reached {
+			t.Errorf("expected depth limit reached %t, got %t", reached, got)
+		}
+	}
+}
+
+func TestPostgresStore_RevisionReached(t *testing.T) {
+	server, pool := newTestPostgresServer(t)
+
+	// the marker is read from the table of the derived relationships, which
+	// the same connector writes
+	for _, reached := range []bool{true, false} {
+		pool.ExpectQuery("from derived_relationships where resource_type = ''").
+			WithArgs("42").
+			WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(reached))
+
+		got, err := server.store.RevisionReached(context.Background(), 42)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got != reached {
+			t.Errorf("expected revision reached %t, got %t", reached, got)
+		}
+	}
+}
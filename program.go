@@ -0,0 +1,182 @@
+package main
+
+import (
+	_ "embed"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+//go:embed program.sql.tmpl
+var programTemplateText string
+
+var programTemplate = template.Must(template.New("program.sql").Funcs(template.FuncMap{
+	"redisKey": redisKeyExpression,
+}).Parse(programTemplateText))
+
+// redisKeyExpression returns the SQL expression of the Redis key of the
+// columns, which escapes and joins them as redisKey does.
+func redisKeyExpression(columns ...string) string {
+	escaped := make([]string, len(columns))
+	for i, column := range columns {
+		escaped[i] = fmt.Sprintf(`REPLACE(REPLACE(%s, '\', '\\'), '%s', '\%s')`, column, redisKeySeparator, redisKeySeparator)
+	}
+
+	return strings.Join(escaped, " || '"+redisKeySeparator+"' || ")
+}
+
+// ProgramOptions configure the Feldera SQL program which derives the
+// relationship graph.
+type ProgramOptions struct {
+
+	// MaxDepth bounds the number of derivation steps (userset expansions and
+	// rule applications) a derived relationship may be the result of. Cyclic
+	// relationships make the recursive views diverge unless the depth is
+	// bounded. Zero means unbounded.
+	MaxDepth int
+
+	// Strata is the number of strata of negated rules the program applies.
+	// Each stratum derives the relationships again, so it should be the
+	// highest stratum of the negated rules of the schema (see programStrata).
+	// Negated rules of higher strata aren't applied.
+	Strata int
+
+	// PostgresOutput writes the derived relationships, and the markers of the
+	// revisions and depth limited subjects, to the tables of bootstrap-pg.sql
+	// as well as to Redis.
+	PostgresOutput bool
+}
+
+// programStratum is a stratum of the derived relationships of the program.
+type programStratum struct {
+	Stratum  int
+	MaxDepth int
+
+	// Name prefixes the views of the stratum. The views of the last stratum
+	// aren't prefixed, so the last stratum is the derived_relationships view.
+	Name string
+
+	// Derived is the derived_relationships view of the stratum, and Previous
+	// the prefix of the views of the previous stratum.
+	Derived  string
+	Previous string
+
+	Last bool
+}
+
+// GenerateProgram generates the Feldera SQL program for the given options.
+func GenerateProgram(opts ProgramOptions) (string, error) {
+	if opts.MaxDepth < 0 {
+		return "", fmt.Errorf("max depth must not be negative, got %d", opts.MaxDepth)
+	}
+
+	if opts.Strata < 0 {
+		return "", fmt.Errorf("strata must not be negative, got %d", opts.Strata)
+	}
+
+	data := struct {
+		ProgramOptions
+		Strata []programStratum
+
+		// RevisionRetentionHours is how long the pipeline keeps revisions
+		// and their markers.
+		RevisionRetentionHours int
+	}{ProgramOptions: opts, RevisionRetentionHours: int(revisionRetention / time.Hour)}
+
+	for i := 0; i <= opts.Strata; i++ {
+		stratum := programStratum{
+			Stratum:  i,
+			MaxDepth: opts.MaxDepth,
+			Name:     fmt.Sprintf("stratum%d_", i),
+			Previous: fmt.Sprintf("stratum%d_", i-1),
+			Last:     i == opts.Strata,
+		}
+		if stratum.Last {
+			stratum.Name = ""
+		}
+
+		// columns are qualified by derived_relationships in every stratum
+		stratum.Derived = "derived_relationships"
+		if stratum.Name != "" {
+			stratum.Derived = stratum.Name + "derived_relationships AS derived_relationships"
+		}
+
+		data.Strata = append(data.Strata, stratum)
+	}
+
+	var sql strings.Builder
+	if err := programTemplate.Execute(&sql, data); err != nil {
+		return "", err
+	}
+
+	return sql.String(), nil
+}
+
+// programStrata returns the number of strata of the program applying the
+// negated rules: the highest stratum of the rules, or strata if it is set
+// (non-zero). It returns an error if strata is lower, since the negated rules
+// of the higher strata wouldn't be applied.
+func programStrata(rules SchemaQueryRules, strata int) (int, error) {
+	required := maxStratum(rules.NegatedBinaryRules)
+	if strata == 0 {
+		return required, nil
+	}
+
+	if strata < required {
+		return 0, fmt.Errorf("the negated rules of the schema need %d strata, got %d", required, strata)
+	}
+
+	return strata, nil
+}
+
+// programFlags defines the flags of the program on flags, which the program
+// and pipeline commands share, and returns a function returning the options
+// of the program once they're parsed. The strata of the program are derived
+// from the negated rules of the schema.
+func programFlags(flags *flag.FlagSet) func() (ProgramOptions, error) {
+	schemaPath := flags.String("schema-path", "schema.json", "Path to the (.json) schema file whose negated rules (exclusions) the program applies")
+	maxDepth := flags.Int("max-depth", 0, "The maximum derivation depth of derived relationships (0 is unbounded)")
+	strata := flags.Int("strata", 0, "The number of strata of negated rules (exclusions) to apply, at least the highest stratum of the negated rules of the schema (0 derives it from the schema)")
+	postgresOutput := flags.Bool("postgres-output", false, "Also write the derived relationships to Postgres, for 'serve -postgres'")
+
+	return func() (ProgramOptions, error) {
+		schema, err := loadSchema(*schemaPath)
+		if err != nil {
+			return ProgramOptions{}, fmt.Errorf("failed to load schema: %w", err)
+		}
+
+		rules, err := mapSchemaToQueryRules(schema)
+		if err != nil {
+			return ProgramOptions{}, fmt.Errorf("failed to map schema to rules: %w", err)
+		}
+
+		strata, err := programStrata(rules, *strata)
+		if err != nil {
+			return ProgramOptions{}, err
+		}
+
+		return ProgramOptions{MaxDepth: *maxDepth, Strata: strata, PostgresOutput: *postgresOutput}, nil
+	}
+}
+
+func runProgram(args []string) {
+	flags := flag.NewFlagSet("program", flag.ExitOnError)
+	options := programFlags(flags)
+	flags.Parse(args)
+
+	opts, err := options()
+	if err != nil {
+		log.Fatalf("failed to generate program: %v", err)
+	}
+
+	sql, err := GenerateProgram(opts)
+	if err != nil {
+		log.Fatalf("failed to generate program: %v", err)
+	}
+
+	fmt.Fprint(os.Stdout, sql)
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerateProgram_UpToDate(t *testing.T) {
+	schema, err := loadSchema("schema.json")
+	if err != nil {
+		t.Fatalf("failed to load schema: %v", err)
+	}
+
+	strata, err := programStrata(mustMapSchemaToQueryRules(t, schema), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sql, err := GenerateProgram(ProgramOptions{Strata: strata})
+	if err != nil {
+		t.Fatalf("failed to generate program: %v", err)
+	}
+
+	committed, err := os.ReadFile("program.sql")
+	if err != nil {
+		t.Fatalf("failed to read program.sql: %v", err)
+	}
+
+	if sql != string(committed) {
+		t.Errorf("program.sql is out of date, regenerate it with 'go run . program > program.sql'")
+	}
+}
+
+func TestGenerateProgram_MaxDepth(t *testing.T) {
+	unbounded, err := GenerateProgram(ProgramOptions{})
+	if err != nil {
+		t.Fatalf("failed to generate program: %v", err)
+	}
+
+	if strings.Contains(unbounded, "depth") {
+		t.Errorf("expected an unbounded program not to track the depth of derived relationships")
+	}
+
+	bounded, err := GenerateProgram(ProgramOptions{MaxDepth: 8})
+	if err != nil {
+		t.Fatalf("failed to generate program: %v", err)
+	}
+
+	for _, expected := range []string{
+		"depth integer not null",
+		"0 AS depth",
+		"GREATEST(lhs.depth, rhs.depth) + 1 AS depth",
+		"derived_relationships.depth < 8",
+		"CREATE MATERIALIZED VIEW depth_limited_subjects",
+		"WHERE derived_relationships.depth >= 8;",
+	} {
+		if !strings.Contains(bounded, expected) {
+			t.Errorf("expected the bounded program to contain '%s'", expected)
+		}
+	}
+
+	if _, err := GenerateProgram(ProgramOptions{MaxDepth: -1}); err == nil {
+		t.Errorf("expected an error for a negative max depth")
+	}
+}
+
+func TestGenerateProgram_Strata(t *testing.T) {
+	unstratified, err := GenerateProgram(ProgramOptions{})
+	if err != nil {
+		t.Fatalf("failed to generate program: %v", err)
+	}
+
+	for _, unexpected := range []string{"stratum0_", "negated_binary_rules.stratum"} {
+		if strings.Contains(unstratified, unexpected) {
+			t.Errorf("expected a program without strata not to contain '%s'", unexpected)
+		}
+	}
+
+	stratified, err := GenerateProgram(ProgramOptions{Strata: 2})
+	if err != nil {
+		t.Fatalf("failed to generate program: %v", err)
+	}
+
+	for _, expected := range []string{
+		"CREATE MATERIALIZED VIEW stratum0_derived_relationships AS",
+		"SELECT * FROM stratum0_derived_relationships",
+		"negated_binary_rules.stratum = 1 AND",
+		"FROM stratum0_derived_relationships AS excluded",
+		"CREATE MATERIALIZED VIEW stratum1_derived_relationships AS",
+		"SELECT * FROM stratum1_derived_relationships",
+		"negated_binary_rules.stratum = 2 AND",
+		"FROM stratum1_derived_relationships AS excluded",
+		"CREATE MATERIALIZED VIEW derived_relationships AS",
+	} {
+		if !strings.Contains(stratified, expected) {
+			t.Errorf("expected the stratified program to contain '%s'", expected)
+		}
+	}
+
+	if strings.Contains(stratified, "stratum2_") {
+		t.Errorf("expected the last stratum to be the derived_relationships view")
+	}
+
+	if _, err := GenerateProgram(ProgramOptions{Strata: -1}); err == nil {
+		t.Errorf("expected an error for negative strata")
+	}
+}
+
+func TestGenerateProgram_PostgresOutput(t *testing.T) {
+	redisOnly, err := GenerateProgram(ProgramOptions{MaxDepth: 8})
+	if err != nil {
+		t.Fatalf("failed to generate program: %v", err)
+	}
+
+	if strings.Contains(redisOnly, "postgres_output") {
+		t.Errorf("expected the program not to write to Postgres without PostgresOutput")
+	}
+
+	postgres, err := GenerateProgram(ProgramOptions{MaxDepth: 8, PostgresOutput: true})
+	if err != nil {
+		t.Fatalf("failed to generate program: %v", err)
+	}
+
+	for _, expected := range []string{
+		`"table": "derived_relationships"`,
+		`"table": "depth_limited_subjects"`,
+		"CREATE MATERIALIZED VIEW postgres_derived_relationships",
+		"CREATE INDEX postgres_derived_relationships_idx ON postgres_derived_relationships(",
+		"CAST(revision_markers.revision AS VARCHAR),\n    'revision',",
+		"CREATE INDEX depth_limited_subjects_idx ON depth_limited_subjects(",
+	} {
+		if !strings.Contains(postgres, expected) {
+			t.Errorf("expected the program to contain '%s'", expected)
+		}
+	}
+
+	// the revision markers are written by the connector of the derived
+	// relationships, rather than by a connector of their own
+	if strings.Contains(postgres, `"table": "revision_markers"`) {
+		t.Errorf("expected the revision markers not to have a postgres_output connector")
+	}
+
+	// the Redis outputs are unchanged
+	if strings.Count(postgres, `"name": "redis_output"`) != strings.Count(redisOnly, `"name": "redis_output"`) {
+		t.Errorf("expected the program to write to Redis as well as to Postgres")
+	}
+}
+
+func TestGenerateProgram_RedisKeys(t *testing.T) {
+	sql, err := GenerateProgram(ProgramOptions{MaxDepth: 8})
+	if err != nil {
+		t.Fatalf("failed to generate program: %v", err)
+	}
+
+	for _, expected := range []string{
+		`REPLACE(REPLACE(combined_derived_relationships.resource_type, '\', '\\'), ':', '\:') || ':' || REPLACE(REPLACE(combined_derived_relationships.resource_id, '\', '\\'), ':', '\:')`,
+		`'r:' || REPLACE(REPLACE(combined_derived_relationships.resource_type, '\', '\\'), ':', '\:')`,
+		`'s:' || REPLACE(REPLACE(combined_derived_relationships.subject_type, '\', '\\'), ':', '\:')`,
+		`"key_fields": ["resource_key"]`,
+		`"key_fields": ["subject_key"]`,
+		`"key_fields": ["marker_key"]`,
+	} {
+		if !strings.Contains(sql, expected) {
+			t.Errorf("expected the program to contain '%s'", expected)
+		}
+	}
+
+	// the revision markers are written by the connectors of the derived
+	// relationships, rather than by connectors of their own
+	if !strings.Contains(sql, "CREATE VIEW revision_markers AS") {
+		t.Errorf("expected revision_markers not to have connectors")
+	}
+
+	for _, key := range []string{":resource'", ":subject'"} {
+		if !strings.Contains(sql, `'revision:' || CAST(revision_markers.revision AS VARCHAR) || '`+key) {
+			t.Errorf("expected redis_derived_relationships to have the marker key '%s", key)
+		}
+	}
+
+	// the egress store follows the markers in the stream of the derived
+	// relationships
+	egress := sql[strings.Index(sql, "CREATE MATERIALIZED VIEW egress_derived_relationships"):]
+	if egress = egress[:strings.Index(egress, ";")]; !strings.Contains(egress, "FROM derived_relationships") || !strings.Contains(egress, "revision_markers.revision") {
+		t.Errorf("expected egress_derived_relationships to have the derived relationships and the revision markers")
+	}
+
+	// the fields of the keys are escaped rather than joined by the connectors
+	if strings.Contains(sql, `"key_fields": ["subject_type"`) || strings.Contains(sql, `"key_fields": ["resource_type"`) || strings.Contains(sql, `"key_fields": ["marker", "subject_type"`) {
+		t.Errorf("expected no redis_output connector to join the fields of a relationship")
+	}
+}
+
+func TestGenerateProgram_RevisionRetention(t *testing.T) {
+	sql, err := GenerateProgram(ProgramOptions{})
+	if err != nil {
+		t.Fatalf("failed to generate program: %v", err)
+	}
+
+	// the markers are retracted, and the revisions discarded, after the
+	// retention the reads assume older revisions are reflected after
+	retention := fmt.Sprintf("INTERVAL '%d' HOUR", int(revisionRetention/time.Hour))
+	for _, expected := range []string{
+		"written_at timestamp not null LATENESS " + retention,
+		"WHERE revisions.written_at > NOW() - " + retention,
+	} {
+		if !strings.Contains(sql, expected) {
+			t.Errorf("expected the program to contain '%s'", expected)
+		}
+	}
+}
+
+func TestProgramStrata(t *testing.T) {
+	rules := SchemaQueryRules{
+		NegatedBinaryRules: []BinaryRule{
+			{FirstResourceType: "document", FirstRelation: "viewer", SecondResourceType: "document", SecondRelation: "banned", DerivedRelation: "can_view", Stratum: 1},
+			{FirstResourceType: "document", FirstRelation: "editor", SecondResourceType: "document", SecondRelation: "can_view", DerivedRelation: "can_edit", Stratum: 2},
+		},
+	}
+
+	if strata, err := programStrata(rules, 0); err != nil || strata != 2 {
+		t.Errorf("expected the strata to be derived as 2, got %d (%v)", strata, err)
+	}
+
+	if strata, err := programStrata(rules, 3); err != nil || strata != 3 {
+		t.Errorf("expected the strata to be 3, got %d (%v)", strata, err)
+	}
+
+	// the negated rule of the second stratum would be dropped
+	if _, err := programStrata(rules, 1); err == nil {
+		t.Errorf("expected an error for fewer strata than the negated rules need")
+	}
+
+	if strata, err := programStrata(SchemaQueryRules{}, 0); err != nil || strata != 0 {
+		t.Errorf("expected no strata without negated rules, got %d (%v)", strata, err)
+	}
+}
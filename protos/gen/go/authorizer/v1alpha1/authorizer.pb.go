@@ -25,7 +25,8 @@ type Schema struct {
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	TypeDefinitions map[string]*TypeDefinition `protobuf:"bytes,1,rep,name=type_definitions,json=typeDefinitions,proto3" json:"type_definitions,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	TypeDefinitions map[string]*TypeDefinition   `protobuf:"bytes,1,rep,name=type_definitions,json=typeDefinitions,proto3" json:"type_definitions,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Caveats         map[string]*CaveatDefinition `protobuf:"bytes,2,rep,name=caveats,proto3" json:"caveats,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
 }
 
 func (x *Schema) Reset() {
@@ -67,6 +68,80 @@ func (x *Schema) GetTypeDefinitions() map[string]*TypeDefinition {
 	return nil
 }
 
+func (x *Schema) GetCaveats() map[string]*CaveatDefinition {
+	if x != nil {
+		return x.Caveats
+	}
+	return nil
+}
+
+// CaveatDefinition defines a condition, written as a CEL expression, which is
+// evaluated at Check time for relationships written with the caveat.
+type CaveatDefinition struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// parameters maps the name of each parameter of the expression to its type
+	// (e.g. 'int', 'string', 'ipaddress', 'timestamp' or 'list<string>').
+	Parameters map[string]string `protobuf:"bytes,2,rep,name=parameters,proto3" json:"parameters,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Expression string            `protobuf:"bytes,3,opt,name=expression,proto3" json:"expression,omitempty"`
+}
+
+func (x *CaveatDefinition) Reset() {
+	*x = CaveatDefinition{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CaveatDefinition) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CaveatDefinition) ProtoMessage() {}
+
+func (x *CaveatDefinition) ProtoReflect() protoreflect.Message {
+	mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CaveatDefinition.ProtoReflect.Descriptor instead.
+func (*CaveatDefinition) Descriptor() ([]byte, []int) {
+	return file_authorizer_v1alpha1_authorizer_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CaveatDefinition) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *CaveatDefinition) GetParameters() map[string]string {
+	if x != nil {
+		return x.Parameters
+	}
+	return nil
+}
+
+func (x *CaveatDefinition) GetExpression() string {
+	if x != nil {
+		return x.Expression
+	}
+	return ""
+}
+
 type TypeDefinition struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -80,7 +155,7 @@ type TypeDefinition struct {
 func (x *TypeDefinition) Reset() {
 	*x = TypeDefinition{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[1]
+		mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[2]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -93,7 +168,7 @@ func (x *TypeDefinition) String() string {
 func (*TypeDefinition) ProtoMessage() {}
 
 func (x *TypeDefinition) ProtoReflect() protoreflect.Message {
-	mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[1]
+	mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[2]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -106,7 +181,7 @@ func (x *TypeDefinition) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use TypeDefinition.ProtoReflect.Descriptor instead.
 func (*TypeDefinition) Descriptor() ([]byte, []int) {
-	return file_authorizer_v1alpha1_authorizer_proto_rawDescGZIP(), []int{1}
+	return file_authorizer_v1alpha1_authorizer_proto_rawDescGZIP(), []int{2}
 }
 
 func (x *TypeDefinition) GetName() string {
@@ -142,7 +217,7 @@ type Relation struct {
 func (x *Relation) Reset() {
 	*x = Relation{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[2]
+		mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[3]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -155,7 +230,7 @@ func (x *Relation) String() string {
 func (*Relation) ProtoMessage() {}
 
 func (x *Relation) ProtoReflect() protoreflect.Message {
-	mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[2]
+	mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[3]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -168,7 +243,7 @@ func (x *Relation) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Relation.ProtoReflect.Descriptor instead.
 func (*Relation) Descriptor() ([]byte, []int) {
-	return file_authorizer_v1alpha1_authorizer_proto_rawDescGZIP(), []int{2}
+	return file_authorizer_v1alpha1_authorizer_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *Relation) GetName() string {
@@ -192,12 +267,22 @@ type RelationTypeRestriction struct {
 
 	ResourceType string `protobuf:"bytes,1,opt,name=resource_type,json=resourceType,proto3" json:"resource_type,omitempty"`
 	Relation     string `protobuf:"bytes,2,opt,name=relation,proto3" json:"relation,omitempty"`
+	// wildcard permits relationships to the subject 'resource_type:*', which
+	// grant the relation to every subject of the given type.
+	Wildcard bool `protobuf:"varint,3,opt,name=wildcard,proto3" json:"wildcard,omitempty"`
+	// caveat is the name of the caveat relationships for this restriction must
+	// be written with (e.g. 'user with ip_allowlist').
+	Caveat string `protobuf:"bytes,4,opt,name=caveat,proto3" json:"caveat,omitempty"`
+	// with_expiration permits relationships for this restriction to be written
+	// with an expiration time (e.g. 'user with expiration'). Relationships with
+	// an expiration are invalid for restrictions without it.
+	WithExpiration bool `protobuf:"varint,5,opt,name=with_expiration,json=withExpiration,proto3" json:"with_expiration,omitempty"`
 }
 
 func (x *RelationTypeRestriction) Reset() {
 	*x = RelationTypeRestriction{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[3]
+		mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[4]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -210,7 +295,7 @@ func (x *RelationTypeRestriction) String() string {
 func (*RelationTypeRestriction) ProtoMessage() {}
 
 func (x *RelationTypeRestriction) ProtoReflect() protoreflect.Message {
-	mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[3]
+	mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[4]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -223,7 +308,7 @@ func (x *RelationTypeRestriction) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use RelationTypeRestriction.ProtoReflect.Descriptor instead.
 func (*RelationTypeRestriction) Descriptor() ([]byte, []int) {
-	return file_authorizer_v1alpha1_authorizer_proto_rawDescGZIP(), []int{3}
+	return file_authorizer_v1alpha1_authorizer_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *RelationTypeRestriction) GetResourceType() string {
@@ -240,6 +325,27 @@ func (x *RelationTypeRestriction) GetRelation() string {
 	return ""
 }
 
+func (x *RelationTypeRestriction) GetWildcard() bool {
+	if x != nil {
+		return x.Wildcard
+	}
+	return false
+}
+
+func (x *RelationTypeRestriction) GetCaveat() string {
+	if x != nil {
+		return x.Caveat
+	}
+	return ""
+}
+
+func (x *RelationTypeRestriction) GetWithExpiration() bool {
+	if x != nil {
+		return x.WithExpiration
+	}
+	return false
+}
+
 type Permission struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -252,7 +358,7 @@ type Permission struct {
 func (x *Permission) Reset() {
 	*x = Permission{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[4]
+		mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[5]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -265,7 +371,7 @@ func (x *Permission) String() string {
 func (*Permission) ProtoMessage() {}
 
 func (x *Permission) ProtoReflect() protoreflect.Message {
-	mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[4]
+	mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[5]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -278,7 +384,7 @@ func (x *Permission) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Permission.ProtoReflect.Descriptor instead.
 func (*Permission) Descriptor() ([]byte, []int) {
-	return file_authorizer_v1alpha1_authorizer_proto_rawDescGZIP(), []int{4}
+	return file_authorizer_v1alpha1_authorizer_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *Permission) GetName() string {
@@ -311,7 +417,7 @@ type PermissionExpressionRef struct {
 func (x *PermissionExpressionRef) Reset() {
 	*x = PermissionExpressionRef{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[5]
+		mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[6]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -324,7 +430,7 @@ func (x *PermissionExpressionRef) String() string {
 func (*PermissionExpressionRef) ProtoMessage() {}
 
 func (x *PermissionExpressionRef) ProtoReflect() protoreflect.Message {
-	mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[5]
+	mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[6]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -337,7 +443,7 @@ func (x *PermissionExpressionRef) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PermissionExpressionRef.ProtoReflect.Descriptor instead.
 func (*PermissionExpressionRef) Descriptor() ([]byte, []int) {
-	return file_authorizer_v1alpha1_authorizer_proto_rawDescGZIP(), []int{5}
+	return file_authorizer_v1alpha1_authorizer_proto_rawDescGZIP(), []int{6}
 }
 
 func (m *PermissionExpressionRef) GetExpression() isPermissionExpressionRef_Expression {
@@ -401,7 +507,7 @@ type UnaryPermissionExpression struct {
 func (x *UnaryPermissionExpression) Reset() {
 	*x = UnaryPermissionExpression{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[6]
+		mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[7]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -414,7 +520,7 @@ func (x *UnaryPermissionExpression) String() string {
 func (*UnaryPermissionExpression) ProtoMessage() {}
 
 func (x *UnaryPermissionExpression) ProtoReflect() protoreflect.Message {
-	mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[6]
+	mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[7]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -427,7 +533,7 @@ func (x *UnaryPermissionExpression) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UnaryPermissionExpression.ProtoReflect.Descriptor instead.
 func (*UnaryPermissionExpression) Descriptor() ([]byte, []int) {
-	return file_authorizer_v1alpha1_authorizer_proto_rawDescGZIP(), []int{6}
+	return file_authorizer_v1alpha1_authorizer_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *UnaryPermissionExpression) GetSourceRelation() string {
@@ -449,7 +555,7 @@ type HierarchicalPermissionExpression struct {
 func (x *HierarchicalPermissionExpression) Reset() {
 	*x = HierarchicalPermissionExpression{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[7]
+		mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[8]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -462,7 +568,7 @@ func (x *HierarchicalPermissionExpression) String() string {
 func (*HierarchicalPermissionExpression) ProtoMessage() {}
 
 func (x *HierarchicalPermissionExpression) ProtoReflect() protoreflect.Message {
-	mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[7]
+	mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[8]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -475,7 +581,7 @@ func (x *HierarchicalPermissionExpression) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use HierarchicalPermissionExpression.ProtoReflect.Descriptor instead.
 func (*HierarchicalPermissionExpression) Descriptor() ([]byte, []int) {
-	return file_authorizer_v1alpha1_authorizer_proto_rawDescGZIP(), []int{7}
+	return file_authorizer_v1alpha1_authorizer_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *HierarchicalPermissionExpression) GetBase() string {
@@ -501,13 +607,14 @@ type PermissionSetExpressionRef struct {
 	//
 	//	*PermissionSetExpressionRef_Union_
 	//	*PermissionSetExpressionRef_Intersection_
+	//	*PermissionSetExpressionRef_Exclusion_
 	SetExpression isPermissionSetExpressionRef_SetExpression `protobuf_oneof:"set_expression"`
 }
 
 func (x *PermissionSetExpressionRef) Reset() {
 	*x = PermissionSetExpressionRef{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[8]
+		mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[9]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -520,7 +627,7 @@ func (x *PermissionSetExpressionRef) String() string {
 func (*PermissionSetExpressionRef) ProtoMessage() {}
 
 func (x *PermissionSetExpressionRef) ProtoReflect() protoreflect.Message {
-	mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[8]
+	mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[9]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -533,7 +640,7 @@ func (x *PermissionSetExpressionRef) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PermissionSetExpressionRef.ProtoReflect.Descriptor instead.
 func (*PermissionSetExpressionRef) Descriptor() ([]byte, []int) {
-	return file_authorizer_v1alpha1_authorizer_proto_rawDescGZIP(), []int{8}
+	return file_authorizer_v1alpha1_authorizer_proto_rawDescGZIP(), []int{9}
 }
 
 func (m *PermissionSetExpressionRef) GetSetExpression() isPermissionSetExpressionRef_SetExpression {
@@ -557,6 +664,13 @@ func (x *PermissionSetExpressionRef) GetIntersection() *PermissionSetExpressionR
 	return nil
 }
 
+func (x *PermissionSetExpressionRef) GetExclusion() *PermissionSetExpressionRef_Exclusion {
+	if x, ok := x.GetSetExpression().(*PermissionSetExpressionRef_Exclusion_); ok {
+		return x.Exclusion
+	}
+	return nil
+}
+
 type isPermissionSetExpressionRef_SetExpression interface {
 	isPermissionSetExpressionRef_SetExpression()
 }
@@ -569,10 +683,16 @@ type PermissionSetExpressionRef_Intersection_ struct {
 	Intersection *PermissionSetExpressionRef_Intersection `protobuf:"bytes,2,opt,name=intersection,proto3,oneof"`
 }
 
+type PermissionSetExpressionRef_Exclusion_ struct {
+	Exclusion *PermissionSetExpressionRef_Exclusion `protobuf:"bytes,3,opt,name=exclusion,proto3,oneof"`
+}
+
 func (*PermissionSetExpressionRef_Union_) isPermissionSetExpressionRef_SetExpression() {}
 
 func (*PermissionSetExpressionRef_Intersection_) isPermissionSetExpressionRef_SetExpression() {}
 
+func (*PermissionSetExpressionRef_Exclusion_) isPermissionSetExpressionRef_SetExpression() {}
+
 type PermissionSetExpressionRef_Union struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -584,7 +704,7 @@ type PermissionSetExpressionRef_Union struct {
 func (x *PermissionSetExpressionRef_Union) Reset() {
 	*x = PermissionSetExpressionRef_Union{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[12]
+		mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[15]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -597,7 +717,7 @@ func (x *PermissionSetExpressionRef_Union) String() string {
 func (*PermissionSetExpressionRef_Union) ProtoMessage() {}
 
 func (x *PermissionSetExpressionRef_Union) ProtoReflect() protoreflect.Message {
-	mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[12]
+	mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[15]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -610,7 +730,7 @@ func (x *PermissionSetExpressionRef_Union) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PermissionSetExpressionRef_Union.ProtoReflect.Descriptor instead.
 func (*PermissionSetExpressionRef_Union) Descriptor() ([]byte, []int) {
-	return file_authorizer_v1alpha1_authorizer_proto_rawDescGZIP(), []int{8, 0}
+	return file_authorizer_v1alpha1_authorizer_proto_rawDescGZIP(), []int{9, 0}
 }
 
 func (x *PermissionSetExpressionRef_Union) GetOperands() []*PermissionExpressionRef {
@@ -631,7 +751,7 @@ type PermissionSetExpressionRef_Intersection struct {
 func (x *PermissionSetExpressionRef_Intersection) Reset() {
 	*x = PermissionSetExpressionRef_Intersection{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[13]
+		mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[16]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -644,7 +764,7 @@ func (x *PermissionSetExpressionRef_Intersection) String() string {
 func (*PermissionSetExpressionRef_Intersection) ProtoMessage() {}
 
 func (x *PermissionSetExpressionRef_Intersection) ProtoReflect() protoreflect.Message {
-	mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[13]
+	mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[16]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -657,7 +777,7 @@ func (x *PermissionSetExpressionRef_Intersection) ProtoReflect() protoreflect.Me
 
 // Deprecated: Use PermissionSetExpressionRef_Intersection.ProtoReflect.Descriptor instead.
 func (*PermissionSetExpressionRef_Intersection) Descriptor() ([]byte, []int) {
-	return file_authorizer_v1alpha1_authorizer_proto_rawDescGZIP(), []int{8, 1}
+	return file_authorizer_v1alpha1_authorizer_proto_rawDescGZIP(), []int{9, 1}
 }
 
 func (x *PermissionSetExpressionRef_Intersection) GetOperands() []*PermissionExpressionRef {
@@ -667,132 +787,223 @@ func (x *PermissionSetExpressionRef_Intersection) GetOperands() []*PermissionExp
 	return nil
 }
 
+// Exclusion is the subjects of the first operand which aren't subjects of
+// the second (e.g. 'viewer - banned').
+type PermissionSetExpressionRef_Exclusion struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Operands []*PermissionExpressionRef `protobuf:"bytes,1,rep,name=operands,proto3" json:"operands,omitempty"`
+}
+
+func (x *PermissionSetExpressionRef_Exclusion) Reset() {
+	*x = PermissionSetExpressionRef_Exclusion{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[17]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PermissionSetExpressionRef_Exclusion) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PermissionSetExpressionRef_Exclusion) ProtoMessage() {}
+
+func (x *PermissionSetExpressionRef_Exclusion) ProtoReflect() protoreflect.Message {
+	mi := &file_authorizer_v1alpha1_authorizer_proto_msgTypes[17]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PermissionSetExpressionRef_Exclusion.ProtoReflect.Descriptor instead.
+func (*PermissionSetExpressionRef_Exclusion) Descriptor() ([]byte, []int) {
+	return file_authorizer_v1alpha1_authorizer_proto_rawDescGZIP(), []int{9, 2}
+}
+
+func (x *PermissionSetExpressionRef_Exclusion) GetOperands() []*PermissionExpressionRef {
+	if x != nil {
+		return x.Operands
+	}
+	return nil
+}
+
 var File_authorizer_v1alpha1_authorizer_proto protoreflect.FileDescriptor
 
 var file_authorizer_v1alpha1_authorizer_proto_rawDesc = []byte{
 	0x0a, 0x24, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x69, 0x7a, 0x65, 0x72, 0x2f, 0x76, 0x31, 0x61,
 	0x6c, 0x70, 0x68, 0x61, 0x31, 0x2f, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x69, 0x7a, 0x65, 0x72,
 	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x13, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x69, 0x7a,
-	0x65, 0x72, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x22, 0xce, 0x01, 0x0a, 0x06,
+	0x65, 0x72, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x22, 0xf5, 0x02, 0x0a, 0x06,
 	0x53, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x12, 0x5b, 0x0a, 0x10, 0x74, 0x79, 0x70, 0x65, 0x5f, 0x64,
 	0x65, 0x66, 0x69, 0x6e, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b,
 	0x32, 0x30, 0x2e, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x69, 0x7a, 0x65, 0x72, 0x2e, 0x76, 0x31,
 	0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x53, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x2e, 0x54, 0x79,
 	0x70, 0x65, 0x44, 0x65, 0x66, 0x69, 0x6e, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x45, 0x6e, 0x74,
 	0x72, 0x79, 0x52, 0x0f, 0x74, 0x79, 0x70, 0x65, 0x44, 0x65, 0x66, 0x69, 0x6e, 0x69, 0x74, 0x69,
-	0x6f, 0x6e, 0x73, 0x1a, 0x67, 0x0a, 0x14, 0x54, 0x79, 0x70, 0x65, 0x44, 0x65, 0x66, 0x69, 0x6e,
-	0x69, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b,
-	0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x39, 0x0a,
-	0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x23, 0x2e, 0x61,
+	0x6f, 0x6e, 0x73, 0x12, 0x42, 0x0a, 0x07, 0x63, 0x61, 0x76, 0x65, 0x61, 0x74, 0x73, 0x18, 0x02,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x28, 0x2e, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x69, 0x7a, 0x65,
+	0x72, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x53, 0x63, 0x68, 0x65, 0x6d,
+	0x61, 0x2e, 0x43, 0x61, 0x76, 0x65, 0x61, 0x74, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x07,
+	0x63, 0x61, 0x76, 0x65, 0x61, 0x74, 0x73, 0x1a, 0x67, 0x0a, 0x14, 0x54, 0x79, 0x70, 0x65, 0x44,
+	0x65, 0x66, 0x69, 0x6e, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12,
+	0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65,
+	0x79, 0x12, 0x39, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x23, 0x2e, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x69, 0x7a, 0x65, 0x72, 0x2e, 0x76, 0x31,
+	0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x54, 0x79, 0x70, 0x65, 0x44, 0x65, 0x66, 0x69, 0x6e,
+	0x69, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01,
+	0x1a, 0x61, 0x0a, 0x0c, 0x43, 0x61, 0x76, 0x65, 0x61, 0x74, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79,
+	0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b,
+	0x65, 0x79, 0x12, 0x3b, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x25, 0x2e, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x69, 0x7a, 0x65, 0x72, 0x2e, 0x76,
+	0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x43, 0x61, 0x76, 0x65, 0x61, 0x74, 0x44, 0x65,
+	0x66, 0x69, 0x6e, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a,
+	0x02, 0x38, 0x01, 0x22, 0xdc, 0x01, 0x0a, 0x10, 0x43, 0x61, 0x76, 0x65, 0x61, 0x74, 0x44, 0x65,
+	0x66, 0x69, 0x6e, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x55, 0x0a, 0x0a,
+	0x70, 0x61, 0x72, 0x61, 0x6d, 0x65, 0x74, 0x65, 0x72, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x35, 0x2e, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x69, 0x7a, 0x65, 0x72, 0x2e, 0x76, 0x31,
+	0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x43, 0x61, 0x76, 0x65, 0x61, 0x74, 0x44, 0x65, 0x66,
+	0x69, 0x6e, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x50, 0x61, 0x72, 0x61, 0x6d, 0x65, 0x74, 0x65,
+	0x72, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x0a, 0x70, 0x61, 0x72, 0x61, 0x6d, 0x65, 0x74,
+	0x65, 0x72, 0x73, 0x12, 0x1e, 0x0a, 0x0a, 0x65, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f,
+	0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x65, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73,
+	0x69, 0x6f, 0x6e, 0x1a, 0x3d, 0x0a, 0x0f, 0x50, 0x61, 0x72, 0x61, 0x6d, 0x65, 0x74, 0x65, 0x72,
+	0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75,
+	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02,
+	0x38, 0x01, 0x22, 0x8c, 0x03, 0x0a, 0x0e, 0x54, 0x79, 0x70, 0x65, 0x44, 0x65, 0x66, 0x69, 0x6e,
+	0x69, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x50, 0x0a, 0x09, 0x72, 0x65, 0x6c,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x32, 0x2e, 0x61,
 	0x75, 0x74, 0x68, 0x6f, 0x72, 0x69, 0x7a, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68,
 	0x61, 0x31, 0x2e, 0x54, 0x79, 0x70, 0x65, 0x44, 0x65, 0x66, 0x69, 0x6e, 0x69, 0x74, 0x69, 0x6f,
-	0x6e, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x8c, 0x03, 0x0a,
-	0x0e, 0x54, 0x79, 0x70, 0x65, 0x44, 0x65, 0x66, 0x69, 0x6e, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x12,
-	0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e,
-	0x61, 0x6d, 0x65, 0x12, 0x50, 0x0a, 0x09, 0x72, 0x65, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73,
-	0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x32, 0x2e, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x69,
-	0x7a, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x54, 0x79, 0x70,
-	0x65, 0x44, 0x65, 0x66, 0x69, 0x6e, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x52, 0x65, 0x6c, 0x61,
-	0x74, 0x69, 0x6f, 0x6e, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x09, 0x72, 0x65, 0x6c, 0x61,
-	0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x56, 0x0a, 0x0b, 0x70, 0x65, 0x72, 0x6d, 0x69, 0x73, 0x73,
-	0x69, 0x6f, 0x6e, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x34, 0x2e, 0x61, 0x75, 0x74,
-	0x68, 0x6f, 0x72, 0x69, 0x7a, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31,
-	0x2e, 0x54, 0x79, 0x70, 0x65, 0x44, 0x65, 0x66, 0x69, 0x6e, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x2e,
-	0x50, 0x65, 0x72, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79,
-	0x52, 0x0b, 0x70, 0x65, 0x72, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x1a, 0x5b, 0x0a,
-	0x0e, 0x52, 0x65, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12,
-	0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65,
-	0x79, 0x12, 0x33, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b,
-	0x32, 0x1d, 0x2e, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x69, 0x7a, 0x65, 0x72, 0x2e, 0x76, 0x31,
-	0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x52, 0x65, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52,
-	0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x1a, 0x5f, 0x0a, 0x10, 0x50, 0x65,
-	0x72, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10,
-	0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79,
-	0x12, 0x35, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32,
-	0x1f, 0x2e, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x69, 0x7a, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x61,
-	0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x50, 0x65, 0x72, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e,
-	0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x79, 0x0a, 0x08, 0x52,
-	0x65, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18,
-	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x59, 0x0a, 0x11, 0x74,
-	0x79, 0x70, 0x65, 0x5f, 0x72, 0x65, 0x73, 0x74, 0x72, 0x69, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73,
-	0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x2c, 0x2e, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x69,
+	0x6e, 0x2e, 0x52, 0x65, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79,
+	0x52, 0x09, 0x72, 0x65, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x56, 0x0a, 0x0b, 0x70,
+	0x65, 0x72, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x34, 0x2e, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x69, 0x7a, 0x65, 0x72, 0x2e, 0x76, 0x31,
+	0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x54, 0x79, 0x70, 0x65, 0x44, 0x65, 0x66, 0x69, 0x6e,
+	0x69, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x50, 0x65, 0x72, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e,
+	0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x0b, 0x70, 0x65, 0x72, 0x6d, 0x69, 0x73, 0x73, 0x69,
+	0x6f, 0x6e, 0x73, 0x1a, 0x5b, 0x0a, 0x0e, 0x52, 0x65, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73,
+	0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x33, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x69,
 	0x7a, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x52, 0x65, 0x6c,
-	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x54, 0x79, 0x70, 0x65, 0x52, 0x65, 0x73, 0x74, 0x72, 0x69, 0x63,
-	0x74, 0x69, 0x6f, 0x6e, 0x52, 0x10, 0x74, 0x79, 0x70, 0x65, 0x52, 0x65, 0x73, 0x74, 0x72, 0x69,
-	0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x22, 0x5a, 0x0a, 0x17, 0x52, 0x65, 0x6c, 0x61, 0x74, 0x69,
-	0x6f, 0x6e, 0x54, 0x79, 0x70, 0x65, 0x52, 0x65, 0x73, 0x74, 0x72, 0x69, 0x63, 0x74, 0x69, 0x6f,
-	0x6e, 0x12, 0x23, 0x0a, 0x0d, 0x72, 0x65, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x5f, 0x74, 0x79,
-	0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x72, 0x65, 0x73, 0x6f, 0x75, 0x72,
-	0x63, 0x65, 0x54, 0x79, 0x70, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x72, 0x65, 0x6c, 0x61, 0x74, 0x69,
-	0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x72, 0x65, 0x6c, 0x61, 0x74, 0x69,
-	0x6f, 0x6e, 0x22, 0x6e, 0x0a, 0x0a, 0x50, 0x65, 0x72, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e,
-	0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04,
-	0x6e, 0x61, 0x6d, 0x65, 0x12, 0x4c, 0x0a, 0x0a, 0x65, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69,
-	0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x2c, 0x2e, 0x61, 0x75, 0x74, 0x68, 0x6f,
-	0x72, 0x69, 0x7a, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x50,
-	0x65, 0x72, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x45, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73,
-	0x69, 0x6f, 0x6e, 0x52, 0x65, 0x66, 0x52, 0x0a, 0x65, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69,
-	0x6f, 0x6e, 0x22, 0xd0, 0x02, 0x0a, 0x17, 0x50, 0x65, 0x72, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6f,
-	0x6e, 0x45, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x66, 0x12, 0x5b,
-	0x0a, 0x10, 0x75, 0x6e, 0x61, 0x72, 0x79, 0x5f, 0x65, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69,
-	0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x2e, 0x2e, 0x61, 0x75, 0x74, 0x68, 0x6f,
-	0x72, 0x69, 0x7a, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x55,
-	0x6e, 0x61, 0x72, 0x79, 0x50, 0x65, 0x72, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x45, 0x78,
-	0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x48, 0x00, 0x52, 0x0f, 0x75, 0x6e, 0x61, 0x72,
-	0x79, 0x45, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x70, 0x0a, 0x17, 0x68,
-	0x69, 0x65, 0x72, 0x61, 0x72, 0x63, 0x68, 0x69, 0x63, 0x61, 0x6c, 0x5f, 0x65, 0x78, 0x70, 0x72,
-	0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x35, 0x2e, 0x61,
-	0x75, 0x74, 0x68, 0x6f, 0x72, 0x69, 0x7a, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68,
-	0x61, 0x31, 0x2e, 0x48, 0x69, 0x65, 0x72, 0x61, 0x72, 0x63, 0x68, 0x69, 0x63, 0x61, 0x6c, 0x50,
-	0x65, 0x72, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x45, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73,
-	0x69, 0x6f, 0x6e, 0x48, 0x00, 0x52, 0x16, 0x68, 0x69, 0x65, 0x72, 0x61, 0x72, 0x63, 0x68, 0x69,
-	0x63, 0x61, 0x6c, 0x45, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x58, 0x0a,
-	0x0e, 0x73, 0x65, 0x74, 0x5f, 0x65, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x18,
-	0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x2f, 0x2e, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x69, 0x7a,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01,
+	0x1a, 0x5f, 0x0a, 0x10, 0x50, 0x65, 0x72, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x45,
+	0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x35, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x69, 0x7a,
 	0x65, 0x72, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x50, 0x65, 0x72, 0x6d,
-	0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x53, 0x65, 0x74, 0x45, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73,
-	0x69, 0x6f, 0x6e, 0x52, 0x65, 0x66, 0x48, 0x00, 0x52, 0x0d, 0x73, 0x65, 0x74, 0x45, 0x78, 0x70,
-	0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x42, 0x0c, 0x0a, 0x0a, 0x65, 0x78, 0x70, 0x72, 0x65,
-	0x73, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0x44, 0x0a, 0x19, 0x55, 0x6e, 0x61, 0x72, 0x79, 0x50, 0x65,
-	0x72, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x45, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69,
-	0x6f, 0x6e, 0x12, 0x27, 0x0a, 0x0f, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x5f, 0x72, 0x65, 0x6c,
-	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0e, 0x73, 0x6f, 0x75,
-	0x72, 0x63, 0x65, 0x52, 0x65, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x22, 0x4e, 0x0a, 0x20, 0x48,
-	0x69, 0x65, 0x72, 0x61, 0x72, 0x63, 0x68, 0x69, 0x63, 0x61, 0x6c, 0x50, 0x65, 0x72, 0x6d, 0x69,
-	0x73, 0x73, 0x69, 0x6f, 0x6e, 0x45, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x12,
-	0x12, 0x0a, 0x04, 0x62, 0x61, 0x73, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x62,
-	0x61, 0x73, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74, 0x18, 0x02, 0x20,
-	0x01, 0x28, 0x09, 0x52, 0x06, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74, 0x22, 0x8e, 0x03, 0x0a, 0x1a,
-	0x50, 0x65, 0x72, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x53, 0x65, 0x74, 0x45, 0x78, 0x70,
-	0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x66, 0x12, 0x4d, 0x0a, 0x05, 0x75, 0x6e,
-	0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x35, 0x2e, 0x61, 0x75, 0x74, 0x68,
+	0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38,
+	0x01, 0x22, 0x79, 0x0a, 0x08, 0x52, 0x65, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x12, 0x0a,
+	0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d,
+	0x65, 0x12, 0x59, 0x0a, 0x11, 0x74, 0x79, 0x70, 0x65, 0x5f, 0x72, 0x65, 0x73, 0x74, 0x72, 0x69,
+	0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x2c, 0x2e, 0x61,
+	0x75, 0x74, 0x68, 0x6f, 0x72, 0x69, 0x7a, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68,
+	0x61, 0x31, 0x2e, 0x52, 0x65, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x54, 0x79, 0x70, 0x65, 0x52,
+	0x65, 0x73, 0x74, 0x72, 0x69, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x10, 0x74, 0x79, 0x70, 0x65,
+	0x52, 0x65, 0x73, 0x74, 0x72, 0x69, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x22, 0xb7, 0x01, 0x0a,
+	0x17, 0x52, 0x65, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x54, 0x79, 0x70, 0x65, 0x52, 0x65, 0x73,
+	0x74, 0x72, 0x69, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x23, 0x0a, 0x0d, 0x72, 0x65, 0x73, 0x6f,
+	0x75, 0x72, 0x63, 0x65, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x0c, 0x72, 0x65, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x54, 0x79, 0x70, 0x65, 0x12, 0x1a, 0x0a,
+	0x08, 0x72, 0x65, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x08, 0x72, 0x65, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x1a, 0x0a, 0x08, 0x77, 0x69, 0x6c,
+	0x64, 0x63, 0x61, 0x72, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08, 0x77, 0x69, 0x6c,
+	0x64, 0x63, 0x61, 0x72, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x63, 0x61, 0x76, 0x65, 0x61, 0x74, 0x18,
+	0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x63, 0x61, 0x76, 0x65, 0x61, 0x74, 0x12, 0x27, 0x0a,
+	0x0f, 0x77, 0x69, 0x74, 0x68, 0x5f, 0x65, 0x78, 0x70, 0x69, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e,
+	0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0e, 0x77, 0x69, 0x74, 0x68, 0x45, 0x78, 0x70, 0x69,
+	0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x22, 0x6e, 0x0a, 0x0a, 0x50, 0x65, 0x72, 0x6d, 0x69, 0x73,
+	0x73, 0x69, 0x6f, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x4c, 0x0a, 0x0a, 0x65, 0x78, 0x70, 0x72,
+	0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x2c, 0x2e, 0x61,
+	0x75, 0x74, 0x68, 0x6f, 0x72, 0x69, 0x7a, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68,
+	0x61, 0x31, 0x2e, 0x50, 0x65, 0x72, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x45, 0x78, 0x70,
+	0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x66, 0x52, 0x0a, 0x65, 0x78, 0x70, 0x72,
+	0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0xd0, 0x02, 0x0a, 0x17, 0x50, 0x65, 0x72, 0x6d, 0x69,
+	0x73, 0x73, 0x69, 0x6f, 0x6e, 0x45, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x52,
+	0x65, 0x66, 0x12, 0x5b, 0x0a, 0x10, 0x75, 0x6e, 0x61, 0x72, 0x79, 0x5f, 0x65, 0x78, 0x70, 0x72,
+	0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x2e, 0x2e, 0x61,
+	0x75, 0x74, 0x68, 0x6f, 0x72, 0x69, 0x7a, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68,
+	0x61, 0x31, 0x2e, 0x55, 0x6e, 0x61, 0x72, 0x79, 0x50, 0x65, 0x72, 0x6d, 0x69, 0x73, 0x73, 0x69,
+	0x6f, 0x6e, 0x45, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x48, 0x00, 0x52, 0x0f,
+	0x75, 0x6e, 0x61, 0x72, 0x79, 0x45, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x12,
+	0x70, 0x0a, 0x17, 0x68, 0x69, 0x65, 0x72, 0x61, 0x72, 0x63, 0x68, 0x69, 0x63, 0x61, 0x6c, 0x5f,
+	0x65, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x35, 0x2e, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x69, 0x7a, 0x65, 0x72, 0x2e, 0x76, 0x31,
+	0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x48, 0x69, 0x65, 0x72, 0x61, 0x72, 0x63, 0x68, 0x69,
+	0x63, 0x61, 0x6c, 0x50, 0x65, 0x72, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x45, 0x78, 0x70,
+	0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x48, 0x00, 0x52, 0x16, 0x68, 0x69, 0x65, 0x72, 0x61,
+	0x72, 0x63, 0x68, 0x69, 0x63, 0x61, 0x6c, 0x45, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f,
+	0x6e, 0x12, 0x58, 0x0a, 0x0e, 0x73, 0x65, 0x74, 0x5f, 0x65, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73,
+	0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x2f, 0x2e, 0x61, 0x75, 0x74, 0x68,
 	0x6f, 0x72, 0x69, 0x7a, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e,
 	0x50, 0x65, 0x72, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x53, 0x65, 0x74, 0x45, 0x78, 0x70,
-	0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x66, 0x2e, 0x55, 0x6e, 0x69, 0x6f, 0x6e,
-	0x48, 0x00, 0x52, 0x05, 0x75, 0x6e, 0x69, 0x6f, 0x6e, 0x12, 0x62, 0x0a, 0x0c, 0x69, 0x6e, 0x74,
-	0x65, 0x72, 0x73, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32,
-	0x3c, 0x2e, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x69, 0x7a, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x61,
-	0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x50, 0x65, 0x72, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e,
-	0x53, 0x65, 0x74, 0x45, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x66,
-	0x2e, 0x49, 0x6e, 0x74, 0x65, 0x72, 0x73, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x48, 0x00, 0x52,
-	0x0c, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x73, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x1a, 0x51, 0x0a,
-	0x05, 0x55, 0x6e, 0x69, 0x6f, 0x6e, 0x12, 0x48, 0x0a, 0x08, 0x6f, 0x70, 0x65, 0x72, 0x61, 0x6e,
+	0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x66, 0x48, 0x00, 0x52, 0x0d, 0x73, 0x65,
+	0x74, 0x45, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x42, 0x0c, 0x0a, 0x0a, 0x65,
+	0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0x44, 0x0a, 0x19, 0x55, 0x6e, 0x61,
+	0x72, 0x79, 0x50, 0x65, 0x72, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x45, 0x78, 0x70, 0x72,
+	0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x27, 0x0a, 0x0f, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65,
+	0x5f, 0x72, 0x65, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x0e, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x52, 0x65, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x22,
+	0x4e, 0x0a, 0x20, 0x48, 0x69, 0x65, 0x72, 0x61, 0x72, 0x63, 0x68, 0x69, 0x63, 0x61, 0x6c, 0x50,
+	0x65, 0x72, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x45, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73,
+	0x69, 0x6f, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x62, 0x61, 0x73, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x04, 0x62, 0x61, 0x73, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x74, 0x61, 0x72, 0x67, 0x65,
+	0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74, 0x22,
+	0xc0, 0x04, 0x0a, 0x1a, 0x50, 0x65, 0x72, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x53, 0x65,
+	0x74, 0x45, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x66, 0x12, 0x4d,
+	0x0a, 0x05, 0x75, 0x6e, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x35, 0x2e,
+	0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x69, 0x7a, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70,
+	0x68, 0x61, 0x31, 0x2e, 0x50, 0x65, 0x72, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x53, 0x65,
+	0x74, 0x45, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x66, 0x2e, 0x55,
+	0x6e, 0x69, 0x6f, 0x6e, 0x48, 0x00, 0x52, 0x05, 0x75, 0x6e, 0x69, 0x6f, 0x6e, 0x12, 0x62, 0x0a,
+	0x0c, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x73, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x3c, 0x2e, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x69, 0x7a, 0x65, 0x72,
+	0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x50, 0x65, 0x72, 0x6d, 0x69, 0x73,
+	0x73, 0x69, 0x6f, 0x6e, 0x53, 0x65, 0x74, 0x45, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f,
+	0x6e, 0x52, 0x65, 0x66, 0x2e, 0x49, 0x6e, 0x74, 0x65, 0x72, 0x73, 0x65, 0x63, 0x74, 0x69, 0x6f,
+	0x6e, 0x48, 0x00, 0x52, 0x0c, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x73, 0x65, 0x63, 0x74, 0x69, 0x6f,
+	0x6e, 0x12, 0x59, 0x0a, 0x09, 0x65, 0x78, 0x63, 0x6c, 0x75, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x39, 0x2e, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x69, 0x7a, 0x65,
+	0x72, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x50, 0x65, 0x72, 0x6d, 0x69,
+	0x73, 0x73, 0x69, 0x6f, 0x6e, 0x53, 0x65, 0x74, 0x45, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69,
+	0x6f, 0x6e, 0x52, 0x65, 0x66, 0x2e, 0x45, 0x78, 0x63, 0x6c, 0x75, 0x73, 0x69, 0x6f, 0x6e, 0x48,
+	0x00, 0x52, 0x09, 0x65, 0x78, 0x63, 0x6c, 0x75, 0x73, 0x69, 0x6f, 0x6e, 0x1a, 0x51, 0x0a, 0x05,
+	0x55, 0x6e, 0x69, 0x6f, 0x6e, 0x12, 0x48, 0x0a, 0x08, 0x6f, 0x70, 0x65, 0x72, 0x61, 0x6e, 0x64,
+	0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x2c, 0x2e, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72,
+	0x69, 0x7a, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x50, 0x65,
+	0x72, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x45, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69,
+	0x6f, 0x6e, 0x52, 0x65, 0x66, 0x52, 0x08, 0x6f, 0x70, 0x65, 0x72, 0x61, 0x6e, 0x64, 0x73, 0x1a,
+	0x58, 0x0a, 0x0c, 0x49, 0x6e, 0x74, 0x65, 0x72, 0x73, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12,
+	0x48, 0x0a, 0x08, 0x6f, 0x70, 0x65, 0x72, 0x61, 0x6e, 0x64, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x2c, 0x2e, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x69, 0x7a, 0x65, 0x72, 0x2e, 0x76,
+	0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x50, 0x65, 0x72, 0x6d, 0x69, 0x73, 0x73, 0x69,
+	0x6f, 0x6e, 0x45, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x66, 0x52,
+	0x08, 0x6f, 0x70, 0x65, 0x72, 0x61, 0x6e, 0x64, 0x73, 0x1a, 0x55, 0x0a, 0x09, 0x45, 0x78, 0x63,
+	0x6c, 0x75, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x48, 0x0a, 0x08, 0x6f, 0x70, 0x65, 0x72, 0x61, 0x6e,
 	0x64, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x2c, 0x2e, 0x61, 0x75, 0x74, 0x68, 0x6f,
 	0x72, 0x69, 0x7a, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x50,
 	0x65, 0x72, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x45, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73,
 	0x69, 0x6f, 0x6e, 0x52, 0x65, 0x66, 0x52, 0x08, 0x6f, 0x70, 0x65, 0x72, 0x61, 0x6e, 0x64, 0x73,
-	0x1a, 0x58, 0x0a, 0x0c, 0x49, 0x6e, 0x74, 0x65, 0x72, 0x73, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e,
-	0x12, 0x48, 0x0a, 0x08, 0x6f, 0x70, 0x65, 0x72, 0x61, 0x6e, 0x64, 0x73, 0x18, 0x02, 0x20, 0x03,
-	0x28, 0x0b, 0x32, 0x2c, 0x2e, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x69, 0x7a, 0x65, 0x72, 0x2e,
-	0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x2e, 0x50, 0x65, 0x72, 0x6d, 0x69, 0x73, 0x73,
-	0x69, 0x6f, 0x6e, 0x45, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x66,
-	0x52, 0x08, 0x6f, 0x70, 0x65, 0x72, 0x61, 0x6e, 0x64, 0x73, 0x42, 0x10, 0x0a, 0x0e, 0x73, 0x65,
-	0x74, 0x5f, 0x65, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x42, 0x47, 0x5a, 0x45,
-	0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6a, 0x6f, 0x6e, 0x2d, 0x77,
-	0x68, 0x69, 0x74, 0x2f, 0x66, 0x65, 0x6c, 0x64, 0x65, 0x72, 0x61, 0x2d, 0x72, 0x65, 0x62, 0x61,
-	0x63, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2f, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x69,
-	0x7a, 0x65, 0x72, 0x2f, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x3b, 0x76, 0x31, 0x61,
-	0x6c, 0x70, 0x68, 0x61, 0x31, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x42, 0x10, 0x0a, 0x0e, 0x73, 0x65, 0x74, 0x5f, 0x65, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69,
+	0x6f, 0x6e, 0x42, 0x47, 0x5a, 0x45, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d,
+	0x2f, 0x6a, 0x6f, 0x6e, 0x2d, 0x77, 0x68, 0x69, 0x74, 0x2f, 0x66, 0x65, 0x6c, 0x64, 0x65, 0x72,
+	0x61, 0x2d, 0x72, 0x65, 0x62, 0x61, 0x63, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x73, 0x2f, 0x61,
+	0x75, 0x74, 0x68, 0x6f, 0x72, 0x69, 0x7a, 0x65, 0x72, 0x2f, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68,
+	0x61, 0x31, 0x3b, 0x76, 0x31, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x31, 0x62, 0x06, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x33,
 }
 
 var (
@@ -807,44 +1018,53 @@ func file_authorizer_v1alpha1_authorizer_proto_rawDescGZIP() []byte {
 	return file_authorizer_v1alpha1_authorizer_proto_rawDescData
 }
 
-var file_authorizer_v1alpha1_authorizer_proto_msgTypes = make([]protoimpl.MessageInfo, 14)
+var file_authorizer_v1alpha1_authorizer_proto_msgTypes = make([]protoimpl.MessageInfo, 18)
 var file_authorizer_v1alpha1_authorizer_proto_goTypes = []interface{}{
 	(*Schema)(nil),                           // 0: authorizer.v1alpha1.Schema
-	(*TypeDefinition)(nil),                   // 1: authorizer.v1alpha1.TypeDefinition
-	(*Relation)(nil),                         // 2: authorizer.v1alpha1.Relation
-	(*RelationTypeRestriction)(nil),          // 3: authorizer.v1alpha1.RelationTypeRestriction
-	(*Permission)(nil),                       // 4: authorizer.v1alpha1.Permission
-	(*PermissionExpressionRef)(nil),          // 5: authorizer.v1alpha1.PermissionExpressionRef
-	(*UnaryPermissionExpression)(nil),        // 6: authorizer.v1alpha1.UnaryPermissionExpression
-	(*HierarchicalPermissionExpression)(nil), // 7: authorizer.v1alpha1.HierarchicalPermissionExpression
-	(*PermissionSetExpressionRef)(nil),       // 8: authorizer.v1alpha1.PermissionSetExpressionRef
-	nil,                                      // 9: authorizer.v1alpha1.Schema.TypeDefinitionsEntry
-	nil,                                      // 10: authorizer.v1alpha1.TypeDefinition.RelationsEntry
-	nil,                                      // 11: authorizer.v1alpha1.TypeDefinition.PermissionsEntry
-	(*PermissionSetExpressionRef_Union)(nil), // 12: authorizer.v1alpha1.PermissionSetExpressionRef.Union
-	(*PermissionSetExpressionRef_Intersection)(nil), // 13: authorizer.v1alpha1.PermissionSetExpressionRef.Intersection
+	(*CaveatDefinition)(nil),                 // 1: authorizer.v1alpha1.CaveatDefinition
+	(*TypeDefinition)(nil),                   // 2: authorizer.v1alpha1.TypeDefinition
+	(*Relation)(nil),                         // 3: authorizer.v1alpha1.Relation
+	(*RelationTypeRestriction)(nil),          // 4: authorizer.v1alpha1.RelationTypeRestriction
+	(*Permission)(nil),                       // 5: authorizer.v1alpha1.Permission
+	(*PermissionExpressionRef)(nil),          // 6: authorizer.v1alpha1.PermissionExpressionRef
+	(*UnaryPermissionExpression)(nil),        // 7: authorizer.v1alpha1.UnaryPermissionExpression
+	(*HierarchicalPermissionExpression)(nil), // 8: authorizer.v1alpha1.HierarchicalPermissionExpression
+	(*PermissionSetExpressionRef)(nil),       // 9: authorizer.v1alpha1.PermissionSetExpressionRef
+	nil,                                      // 10: authorizer.v1alpha1.Schema.TypeDefinitionsEntry
+	nil,                                      // 11: authorizer.v1alpha1.Schema.CaveatsEntry
+	nil,                                      // 12: authorizer.v1alpha1.CaveatDefinition.ParametersEntry
+	nil,                                      // 13: authorizer.v1alpha1.TypeDefinition.RelationsEntry
+	nil,                                      // 14: authorizer.v1alpha1.TypeDefinition.PermissionsEntry
+	(*PermissionSetExpressionRef_Union)(nil), // 15: authorizer.v1alpha1.PermissionSetExpressionRef.Union
+	(*PermissionSetExpressionRef_Intersection)(nil), // 16: authorizer.v1alpha1.PermissionSetExpressionRef.Intersection
+	(*PermissionSetExpressionRef_Exclusion)(nil),    // 17: authorizer.v1alpha1.PermissionSetExpressionRef.Exclusion
 }
 var file_authorizer_v1alpha1_authorizer_proto_depIdxs = []int32{
-	9,  // 0: authorizer.v1alpha1.Schema.type_definitions:type_name -> authorizer.v1alpha1.Schema.TypeDefinitionsEntry
-	10, // 1: authorizer.v1alpha1.TypeDefinition.relations:type_name -> authorizer.v1alpha1.TypeDefinition.RelationsEntry
-	11, // 2: authorizer.v1alpha1.TypeDefinition.permissions:type_name -> authorizer.v1alpha1.TypeDefinition.PermissionsEntry
-	3,  // 3: authorizer.v1alpha1.Relation.type_restrictions:type_name -> authorizer.v1alpha1.RelationTypeRestriction
-	5,  // 4: authorizer.v1alpha1.Permission.expression:type_name -> authorizer.v1alpha1.PermissionExpressionRef
-	6,  // 5: authorizer.v1alpha1.PermissionExpressionRef.unary_expression:type_name -> authorizer.v1alpha1.UnaryPermissionExpression
-	7,  // 6: authorizer.v1alpha1.PermissionExpressionRef.hierarchical_expression:type_name -> authorizer.v1alpha1.HierarchicalPermissionExpression
-	8,  // 7: authorizer.v1alpha1.PermissionExpressionRef.set_expression:type_name -> authorizer.v1alpha1.PermissionSetExpressionRef
-	12, // 8: authorizer.v1alpha1.PermissionSetExpressionRef.union:type_name -> authorizer.v1alpha1.PermissionSetExpressionRef.Union
-	13, // 9: authorizer.v1alpha1.PermissionSetExpressionRef.intersection:type_name -> authorizer.v1alpha1.PermissionSetExpressionRef.Intersection
-	1,  // 10: authorizer.v1alpha1.Schema.TypeDefinitionsEntry.value:type_name -> authorizer.v1alpha1.TypeDefinition
-	2,  // 11: authorizer.v1alpha1.TypeDefinition.RelationsEntry.value:type_name -> authorizer.v1alpha1.Relation
-	4,  // 12: authorizer.v1alpha1.TypeDefinition.PermissionsEntry.value:type_name -> authorizer.v1alpha1.Permission
-	5,  // 13: authorizer.v1alpha1.PermissionSetExpressionRef.Union.operands:type_name -> authorizer.v1alpha1.PermissionExpressionRef
-	5,  // 14: authorizer.v1alpha1.PermissionSetExpressionRef.Intersection.operands:type_name -> authorizer.v1alpha1.PermissionExpressionRef
-	15, // [15:15] is the sub-list for method output_type
-	15, // [15:15] is the sub-list for method input_type
-	15, // [15:15] is the sub-list for extension type_name
-	15, // [15:15] is the sub-list for extension extendee
-	0,  // [0:15] is the sub-list for field type_name
+	10, // 0: authorizer.v1alpha1.Schema.type_definitions:type_name -> authorizer.v1alpha1.Schema.TypeDefinitionsEntry
+	11, // 1: authorizer.v1alpha1.Schema.caveats:type_name -> authorizer.v1alpha1.Schema.CaveatsEntry
+	12, // 2: authorizer.v1alpha1.CaveatDefinition.parameters:type_name -> authorizer.v1alpha1.CaveatDefinition.ParametersEntry
+	13, // 3: authorizer.v1alpha1.TypeDefinition.relations:type_name -> authorizer.v1alpha1.TypeDefinition.RelationsEntry
+	14, // 4: authorizer.v1alpha1.TypeDefinition.permissions:type_name -> authorizer.v1alpha1.TypeDefinition.PermissionsEntry
+	4,  // 5: authorizer.v1alpha1.Relation.type_restrictions:type_name -> authorizer.v1alpha1.RelationTypeRestriction
+	6,  // 6: authorizer.v1alpha1.Permission.expression:type_name -> authorizer.v1alpha1.PermissionExpressionRef
+	7,  // 7: authorizer.v1alpha1.PermissionExpressionRef.unary_expression:type_name -> authorizer.v1alpha1.UnaryPermissionExpression
+	8,  // 8: authorizer.v1alpha1.PermissionExpressionRef.hierarchical_expression:type_name -> authorizer.v1alpha1.HierarchicalPermissionExpression
+	9,  // 9: authorizer.v1alpha1.PermissionExpressionRef.set_expression:type_name -> authorizer.v1alpha1.PermissionSetExpressionRef
+	15, // 10: authorizer.v1alpha1.PermissionSetExpressionRef.union:type_name -> authorizer.v1alpha1.PermissionSetExpressionRef.Union
+	16, // 11: authorizer.v1alpha1.PermissionSetExpressionRef.intersection:type_name -> authorizer.v1alpha1.PermissionSetExpressionRef.Intersection
+	17, // 12: authorizer.v1alpha1.PermissionSetExpressionRef.exclusion:type_name -> authorizer.v1alpha1.PermissionSetExpressionRef.Exclusion
+	2,  // 13: authorizer.v1alpha1.Schema.TypeDefinitionsEntry.value:type_name -> authorizer.v1alpha1.TypeDefinition
+	1,  // 14: authorizer.v1alpha1.Schema.CaveatsEntry.value:type_name -> authorizer.v1alpha1.CaveatDefinition
+	3,  // 15: authorizer.v1alpha1.TypeDefinition.RelationsEntry.value:type_name -> authorizer.v1alpha1.Relation
+	5,  // 16: authorizer.v1alpha1.TypeDefinition.PermissionsEntry.value:type_name -> authorizer.v1alpha1.Permission
+	6,  // 17: authorizer.v1alpha1.PermissionSetExpressionRef.Union.operands:type_name -> authorizer.v1alpha1.PermissionExpressionRef
+	6,  // 18: authorizer.v1alpha1.PermissionSetExpressionRef.Intersection.operands:type_name -> authorizer.v1alpha1.PermissionExpressionRef
+	6,  // 19: authorizer.v1alpha1.PermissionSetExpressionRef.Exclusion.operands:type_name -> authorizer.v1alpha1.PermissionExpressionRef
+	20, // [20:20] is the sub-list for method output_type
+	20, // [20:20] is the sub-list for method input_type
+	20, // [20:20] is the sub-list for extension type_name
+	20, // [20:20] is the sub-list for extension extendee
+	0,  // [0:20] is the sub-list for field type_name
 }
 
 func init() { file_authorizer_v1alpha1_authorizer_proto_init() }
@@ -866,7 +1086,7 @@ func file_authorizer_v1alpha1_authorizer_proto_init() {
 			}
 		}
 		file_authorizer_v1alpha1_authorizer_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*TypeDefinition); i {
+			switch v := v.(*CaveatDefinition); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -878,7 +1098,7 @@ func file_authorizer_v1alpha1_authorizer_proto_init() {
 			}
 		}
 		file_authorizer_v1alpha1_authorizer_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Relation); i {
+			switch v := v.(*TypeDefinition); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -890,7 +1110,7 @@ func file_authorizer_v1alpha1_authorizer_proto_init() {
 			}
 		}
 		file_authorizer_v1alpha1_authorizer_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*RelationTypeRestriction); i {
+			switch v := v.(*Relation); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -902,7 +1122,7 @@ func file_authorizer_v1alpha1_authorizer_proto_init() {
 			}
 		}
 		file_authorizer_v1alpha1_authorizer_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Permission); i {
+			switch v := v.(*RelationTypeRestriction); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -914,7 +1134,7 @@ func file_authorizer_v1alpha1_authorizer_proto_init() {
 			}
 		}
 		file_authorizer_v1alpha1_authorizer_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*PermissionExpressionRef); i {
+			switch v := v.(*Permission); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -926,7 +1146,7 @@ func file_authorizer_v1alpha1_authorizer_proto_init() {
 			}
 		}
 		file_authorizer_v1alpha1_authorizer_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*UnaryPermissionExpression); i {
+			switch v := v.(*PermissionExpressionRef); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -938,7 +1158,7 @@ func file_authorizer_v1alpha1_authorizer_proto_init() {
 			}
 		}
 		file_authorizer_v1alpha1_authorizer_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*HierarchicalPermissionExpression); i {
+			switch v := v.(*UnaryPermissionExpression); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -950,6 +1170,18 @@ func file_authorizer_v1alpha1_authorizer_proto_init() {
 			}
 		}
 		file_authorizer_v1alpha1_authorizer_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*HierarchicalPermissionExpression); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_authorizer_v1alpha1_authorizer_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*PermissionSetExpressionRef); i {
 			case 0:
 				return &v.state
@@ -961,7 +1193,7 @@ func file_authorizer_v1alpha1_authorizer_proto_init() {
 				return nil
 			}
 		}
-		file_authorizer_v1alpha1_authorizer_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+		file_authorizer_v1alpha1_authorizer_proto_msgTypes[15].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*PermissionSetExpressionRef_Union); i {
 			case 0:
 				return &v.state
@@ -973,7 +1205,7 @@ func file_authorizer_v1alpha1_authorizer_proto_init() {
 				return nil
 			}
 		}
-		file_authorizer_v1alpha1_authorizer_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+		file_authorizer_v1alpha1_authorizer_proto_msgTypes[16].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*PermissionSetExpressionRef_Intersection); i {
 			case 0:
 				return &v.state
@@ -985,15 +1217,28 @@ func file_authorizer_v1alpha1_authorizer_proto_init() {
 				return nil
 			}
 		}
+		file_authorizer_v1alpha1_authorizer_proto_msgTypes[17].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PermissionSetExpressionRef_Exclusion); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
-	file_authorizer_v1alpha1_authorizer_proto_msgTypes[5].OneofWrappers = []interface{}{
+	file_authorizer_v1alpha1_authorizer_proto_msgTypes[6].OneofWrappers = []interface{}{
 		(*PermissionExpressionRef_UnaryExpression)(nil),
 		(*PermissionExpressionRef_HierarchicalExpression)(nil),
 		(*PermissionExpressionRef_SetExpression)(nil),
 	}
-	file_authorizer_v1alpha1_authorizer_proto_msgTypes[8].OneofWrappers = []interface{}{
+	file_authorizer_v1alpha1_authorizer_proto_msgTypes[9].OneofWrappers = []interface{}{
 		(*PermissionSetExpressionRef_Union_)(nil),
 		(*PermissionSetExpressionRef_Intersection_)(nil),
+		(*PermissionSetExpressionRef_Exclusion_)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -1001,7 +1246,7 @@ func file_authorizer_v1alpha1_authorizer_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_authorizer_v1alpha1_authorizer_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   14,
+			NumMessages:   18,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -0,0 +1,415 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: authorizer/v1alpha1/authorizer_service.proto
+
+package v1alpha1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	AuthorizerService_Check_FullMethodName                     = "/authorizer.v1alpha1.AuthorizerService/Check"
+	AuthorizerService_CheckBulk_FullMethodName                 = "/authorizer.v1alpha1.AuthorizerService/CheckBulk"
+	AuthorizerService_LookupResources_FullMethodName           = "/authorizer.v1alpha1.AuthorizerService/LookupResources"
+	AuthorizerService_LookupSubjects_FullMethodName            = "/authorizer.v1alpha1.AuthorizerService/LookupSubjects"
+	AuthorizerService_Expand_FullMethodName                    = "/authorizer.v1alpha1.AuthorizerService/Expand"
+	AuthorizerService_Watch_FullMethodName                     = "/authorizer.v1alpha1.AuthorizerService/Watch"
+	AuthorizerService_WriteRelationships_FullMethodName        = "/authorizer.v1alpha1.AuthorizerService/WriteRelationships"
+	AuthorizerService_ListRejectedRelationships_FullMethodName = "/authorizer.v1alpha1.AuthorizerService/ListRejectedRelationships"
+)
+
+// AuthorizerServiceClient is the client API for AuthorizerService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AuthorizerServiceClient interface {
+	Check(ctx context.Context, in *CheckRequest, opts ...grpc.CallOption) (*CheckResponse, error)
+	// CheckBulk checks each of a list of relations of resources to subjects,
+	// which may all differ, in a single round trip to the store.
+	CheckBulk(ctx context.Context, in *CheckBulkRequest, opts ...grpc.CallOption) (*CheckBulkResponse, error)
+	LookupResources(ctx context.Context, in *LookupResourcesRequest, opts ...grpc.CallOption) (*LookupResourcesResponse, error)
+	LookupSubjects(ctx context.Context, in *LookupSubjectsRequest, opts ...grpc.CallOption) (*LookupSubjectsResponse, error)
+	// Expand returns the tree of relations and permissions a permission of a
+	// resource is derived from, as defined by the schema, with the subjects of
+	// each relation.
+	Expand(ctx context.Context, in *ExpandRequest, opts ...grpc.CallOption) (*ExpandResponse, error)
+	// Watch streams the derived relationships granted and revoked by the
+	// pipeline, as the changes of the derived_relationships view.
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WatchResponse], error)
+	// WriteRelationships writes and deletes relationships of the pipeline's
+	// relationships table, and returns the revision of the write.
+	WriteRelationships(ctx context.Context, in *WriteRelationshipsRequest, opts ...grpc.CallOption) (*WriteRelationshipsResponse, error)
+	// ListRejectedRelationships lists the relationships which no type
+	// restriction of the schema allows, which the pipeline never derives from,
+	// with the reason each was rejected.
+	ListRejectedRelationships(ctx context.Context, in *ListRejectedRelationshipsRequest, opts ...grpc.CallOption) (*ListRejectedRelationshipsResponse, error)
+}
+
+type authorizerServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAuthorizerServiceClient(cc grpc.ClientConnInterface) AuthorizerServiceClient {
+	return &authorizerServiceClient{cc}
+}
+
+func (c *authorizerServiceClient) Check(ctx context.Context, in *CheckRequest, opts ...grpc.CallOption) (*CheckResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CheckResponse)
+	err := c.cc.Invoke(ctx, AuthorizerService_Check_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authorizerServiceClient) CheckBulk(ctx context.Context, in *CheckBulkRequest, opts ...grpc.CallOption) (*CheckBulkResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CheckBulkResponse)
+	err := c.cc.Invoke(ctx, AuthorizerService_CheckBulk_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authorizerServiceClient) LookupResources(ctx context.Context, in *LookupResourcesRequest, opts ...grpc.CallOption) (*LookupResourcesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LookupResourcesResponse)
+	err := c.cc.Invoke(ctx, AuthorizerService_LookupResources_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authorizerServiceClient) LookupSubjects(ctx context.Context, in *LookupSubjectsRequest, opts ...grpc.CallOption) (*LookupSubjectsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LookupSubjectsResponse)
+	err := c.cc.Invoke(ctx, AuthorizerService_LookupSubjects_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authorizerServiceClient) Expand(ctx context.Context, in *ExpandRequest, opts ...grpc.CallOption) (*ExpandResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExpandResponse)
+	err := c.cc.Invoke(ctx, AuthorizerService_Expand_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authorizerServiceClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WatchResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &AuthorizerService_ServiceDesc.Streams[0], AuthorizerService_Watch_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchRequest, WatchResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type AuthorizerService_WatchClient = grpc.ServerStreamingClient[WatchResponse]
+
+func (c *authorizerServiceClient) WriteRelationships(ctx context.Context, in *WriteRelationshipsRequest, opts ...grpc.CallOption) (*WriteRelationshipsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(WriteRelationshipsResponse)
+	err := c.cc.Invoke(ctx, AuthorizerService_WriteRelationships_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authorizerServiceClient) ListRejectedRelationships(ctx context.Context, in *ListRejectedRelationshipsRequest, opts ...grpc.CallOption) (*ListRejectedRelationshipsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListRejectedRelationshipsResponse)
+	err := c.cc.Invoke(ctx, AuthorizerService_ListRejectedRelationships_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AuthorizerServiceServer is the server API for AuthorizerService service.
+// All implementations must embed UnimplementedAuthorizerServiceServer
+// for forward compatibility.
+type AuthorizerServiceServer interface {
+	Check(context.Context, *CheckRequest) (*CheckResponse, error)
+	// CheckBulk checks each of a list of relations of resources to subjects,
+	// which may all differ, in a single round trip to the store.
+	CheckBulk(context.Context, *CheckBulkRequest) (*CheckBulkResponse, error)
+	LookupResources(context.Context, *LookupResourcesRequest) (*LookupResourcesResponse, error)
+	LookupSubjects(context.Context, *LookupSubjectsRequest) (*LookupSubjectsResponse, error)
+	// Expand returns the tree of relations and permissions a permission of a
+	// resource is derived from, as defined by the schema, with the subjects of
+	// each relation.
+	Expand(context.Context, *ExpandRequest) (*ExpandResponse, error)
+	// Watch streams the derived relationships granted and revoked by the
+	// pipeline, as the changes of the derived_relationships view.
+	Watch(*WatchRequest, grpc.ServerStreamingServer[WatchResponse]) error
+	// WriteRelationships writes and deletes relationships of the pipeline's
+	// relationships table, and returns the revision of the write.
+	WriteRelationships(context.Context, *WriteRelationshipsRequest) (*WriteRelationshipsResponse, error)
+	// ListRejectedRelationships lists the relationships which no type
+	// restriction of the schema allows, which the pipeline never derives from,
+	// with the reason each was rejected.
+	ListRejectedRelationships(context.Context, *ListRejectedRelationshipsRequest) (*ListRejectedRelationshipsResponse, error)
+	mustEmbedUnimplementedAuthorizerServiceServer()
+}
+
+// UnimplementedAuthorizerServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedAuthorizerServiceServer struct{}
+
+func (UnimplementedAuthorizerServiceServer) Check(context.Context, *CheckRequest) (*CheckResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Check not implemented")
+}
+func (UnimplementedAuthorizerServiceServer) CheckBulk(context.Context, *CheckBulkRequest) (*CheckBulkResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CheckBulk not implemented")
+}
+func (UnimplementedAuthorizerServiceServer) LookupResources(context.Context, *LookupResourcesRequest) (*LookupResourcesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LookupResources not implemented")
+}
+func (UnimplementedAuthorizerServiceServer) LookupSubjects(context.Context, *LookupSubjectsRequest) (*LookupSubjectsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LookupSubjects not implemented")
+}
+func (UnimplementedAuthorizerServiceServer) Expand(context.Context, *ExpandRequest) (*ExpandResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Expand not implemented")
+}
+func (UnimplementedAuthorizerServiceServer) Watch(*WatchRequest, grpc.ServerStreamingServer[WatchResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedAuthorizerServiceServer) WriteRelationships(context.Context, *WriteRelationshipsRequest) (*WriteRelationshipsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method WriteRelationships not implemented")
+}
+func (UnimplementedAuthorizerServiceServer) ListRejectedRelationships(context.Context, *ListRejectedRelationshipsRequest) (*ListRejectedRelationshipsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRejectedRelationships not implemented")
+}
+func (UnimplementedAuthorizerServiceServer) mustEmbedUnimplementedAuthorizerServiceServer() {}
+func (UnimplementedAuthorizerServiceServer) testEmbeddedByValue()                           {}
+
+// UnsafeAuthorizerServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AuthorizerServiceServer will
+// result in compilation errors.
+type UnsafeAuthorizerServiceServer interface {
+	mustEmbedUnimplementedAuthorizerServiceServer()
+}
+
+func RegisterAuthorizerServiceServer(s grpc.ServiceRegistrar, srv AuthorizerServiceServer) {
+	// If the following call pancis, it indicates UnimplementedAuthorizerServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&AuthorizerService_ServiceDesc, srv)
+}
+
+func _AuthorizerService_Check_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CheckRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthorizerServiceServer).Check(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AuthorizerService_Check_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthorizerServiceServer).Check(ctx, req.(*CheckRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AuthorizerService_CheckBulk_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CheckBulkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthorizerServiceServer).CheckBulk(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AuthorizerService_CheckBulk_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthorizerServiceServer).CheckBulk(ctx, req.(*CheckBulkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AuthorizerService_LookupResources_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LookupResourcesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthorizerServiceServer).LookupResources(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AuthorizerService_LookupResources_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthorizerServiceServer).LookupResources(ctx, req.(*LookupResourcesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AuthorizerService_LookupSubjects_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LookupSubjectsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthorizerServiceServer).LookupSubjects(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AuthorizerService_LookupSubjects_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthorizerServiceServer).LookupSubjects(ctx, req.(*LookupSubjectsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AuthorizerService_Expand_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExpandRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthorizerServiceServer).Expand(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AuthorizerService_Expand_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthorizerServiceServer).Expand(ctx, req.(*ExpandRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AuthorizerService_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AuthorizerServiceServer).Watch(m, &grpc.GenericServerStream[WatchRequest, WatchResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type AuthorizerService_WatchServer = grpc.ServerStreamingServer[WatchResponse]
+
+func _AuthorizerService_WriteRelationships_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WriteRelationshipsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthorizerServiceServer).WriteRelationships(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AuthorizerService_WriteRelationships_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthorizerServiceServer).WriteRelationships(ctx, req.(*WriteRelationshipsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AuthorizerService_ListRejectedRelationships_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRejectedRelationshipsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthorizerServiceServer).ListRejectedRelationships(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AuthorizerService_ListRejectedRelationships_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthorizerServiceServer).ListRejectedRelationships(ctx, req.(*ListRejectedRelationshipsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// AuthorizerService_ServiceDesc is the grpc.ServiceDesc for AuthorizerService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AuthorizerService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "authorizer.v1alpha1.AuthorizerService",
+	HandlerType: (*AuthorizerServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Check",
+			Handler:    _AuthorizerService_Check_Handler,
+		},
+		{
+			MethodName: "CheckBulk",
+			Handler:    _AuthorizerService_CheckBulk_Handler,
+		},
+		{
+			MethodName: "LookupResources",
+			Handler:    _AuthorizerService_LookupResources_Handler,
+		},
+		{
+			MethodName: "LookupSubjects",
+			Handler:    _AuthorizerService_LookupSubjects_Handler,
+		},
+		{
+			MethodName: "Expand",
+			Handler:    _AuthorizerService_Expand_Handler,
+		},
+		{
+			MethodName: "WriteRelationships",
+			Handler:    _AuthorizerService_WriteRelationships_Handler,
+		},
+		{
+			MethodName: "ListRejectedRelationships",
+			Handler:    _AuthorizerService_ListRejectedRelationships_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _AuthorizerService_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "authorizer/v1alpha1/authorizer_service.proto",
+}
@@ -162,7 +162,9 @@ create materialized view derived_negated_binary_relationships as with
             derived_relationships.resource_id,
             negated_binary_rules.derived_relationship as relationship
         from derived_relationships, negated_binary_rules
-        where derived_relationships.relationship = negated_binary_rules.prerequisite1_relationship
+        where
+            derived_relationships.resource_type = negated_binary_rules.prerequisite1_resource_type and
+            derived_relationships.relationship = negated_binary_rules.prerequisite1_relationship
     ),
     sub as (
         select
@@ -171,13 +173,25 @@ create materialized view derived_negated_binary_relationships as with
             derived_relationships.subject_relation,
             derived_relationships.resource_type,
             derived_relationships.resource_id,
+            negated_binary_rules.prerequisite1_resource_type,
             negated_binary_rules.derived_relationship as relationship
         from derived_relationships, negated_binary_rules
-        where derived_relationships.relationship = negated_binary_rules.prerequisite2_relationship
+        where
+            derived_relationships.resource_type = negated_binary_rules.prerequisite2_resource_type and
+            derived_relationships.relationship = negated_binary_rules.prerequisite2_relationship
     )
 select * from base
 where not exists (
-    select * from sub where base.resource_type = sub.resource_type and base.resource_id = sub.resource_id
+    -- only the subject (or the wildcard subject) with the second relationship
+    -- to the resource is excluded
+    select * from sub
+    where
+        base.resource_type = sub.prerequisite1_resource_type and
+        base.resource_id = sub.resource_id and
+        base.relationship = sub.relationship and
+        base.subject_type = sub.subject_type and
+        base.subject_relation = sub.subject_relation and
+        (base.subject_id = sub.subject_id or sub.subject_id = '*')
 );
 
 -- note that this doesn't work for definitions such as
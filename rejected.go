@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	authorizerpb "github.com/jon-whit/feldera-rebac/protos/gen/go/authorizer/v1alpha1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// RejectedRelationship is a row of the rejected_relationships view, a
+// relationship which no type restriction allows.
+type RejectedRelationship struct {
+	Relationship
+	Reason string `json:"reason"`
+}
+
+// rejectionReason returns why no type restriction of the rules allows r, by
+// the restriction of its resource type which it matches the most columns of,
+// or "" if one allows it. The reasons are those of the rejected_relationships
+// view of program.sql.
+func rejectionReason(rules SchemaQueryRules, r Relationship) string {
+	matched := 0
+	for _, restriction := range rules.RelationTypeRestrictions {
+		if restriction.ResourceType != r.ResourceType {
+			continue
+		}
+
+		var columns int
+		switch {
+		case restriction.Relation != r.Relation:
+			columns = 1
+		case restriction.SubjectType != r.SubjectType:
+			columns = 2
+		case restriction.SubjectRelation != r.SubjectRelation:
+			columns = 3
+		case restriction.Wildcard != (r.SubjectID == wildcardSubjectID):
+			columns = 4
+		case restriction.Caveat != r.CaveatName:
+			columns = 5
+		case r.ExpiresAt != nil && !restriction.WithExpiration:
+			columns = 6
+		default:
+			return ""
+		}
+
+		matched = max(matched, columns)
+	}
+
+	switch matched {
+	case 0:
+		return "unknown resource type"
+	case 1:
+		return "unknown relation"
+	case 2:
+		return "subject type not allowed"
+	case 3:
+		if r.SubjectRelation == "" {
+			return "missing subject relation"
+		}
+		return "subject relation not allowed"
+	case 4:
+		if r.SubjectID == wildcardSubjectID {
+			return "wildcard not allowed"
+		}
+		return "wildcard required"
+	case 5:
+		if r.CaveatName == "" {
+			return "missing caveat"
+		}
+		return "caveat not allowed"
+	default:
+		return "expiration not allowed"
+	}
+}
+
+// rejectRelationships returns the relationships which no type restriction of
+// the rules allows.
+func rejectRelationships(rules SchemaQueryRules, relationships []Relationship) []RejectedRelationship {
+	var rejected []RejectedRelationship
+	for _, r := range relationships {
+		if reason := rejectionReason(rules, r); reason != "" {
+			rejected = append(rejected, RejectedRelationship{Relationship: r, Reason: reason})
+		}
+	}
+
+	return rejected
+}
+
+// rejectedRelationships reads the rejected_relationships view of the pipeline.
+func (c *felderaClient) rejectedRelationships(ctx context.Context) ([]RejectedRelationship, error) {
+	var rejected []RejectedRelationship
+	err := c.query(ctx, "SELECT * FROM rejected_relationships", func(row json.RawMessage) error {
+		var r RejectedRelationship
+		if err := json.Unmarshal(row, &r); err != nil {
+			return fmt.Errorf("failed to decode rejected relationship: %w", err)
+		}
+
+		if r.CaveatName == "" {
+			r.CaveatContext = ""
+		}
+
+		rejected = append(rejected, r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rejected, nil
+}
+
+func (s *authorizerServer) ListRejectedRelationships(ctx context.Context, req *authorizerpb.ListRejectedRelationshipsRequest) (*authorizerpb.ListRejectedRelationshipsResponse, error) {
+	rejected := s.rejected
+	if s.feldera != nil {
+		var err error
+		rejected, err = s.feldera.rejectedRelationships(ctx)
+		if err != nil {
+			return nil, status.Errorf(codes.Unavailable, "failed to read rejected relationships: %v", err)
+		}
+	}
+
+	resp := &authorizerpb.ListRejectedRelationshipsResponse{}
+	for _, r := range rejected {
+		if (req.GetResourceType() != "" && r.ResourceType != req.GetResourceType()) ||
+			(req.GetSubjectType() != "" && r.SubjectType != req.GetSubjectType()) {
+			continue
+		}
+
+		relationship := &authorizerpb.Relationship{
+			ResourceType:    r.ResourceType,
+			ResourceId:      r.ResourceID,
+			Relation:        r.Relation,
+			SubjectType:     r.SubjectType,
+			SubjectId:       r.SubjectID,
+			SubjectRelation: r.SubjectRelation,
+			CaveatName:      r.CaveatName,
+			CaveatContext:   r.CaveatContext,
+		}
+		if r.ExpiresAt != nil {
+			relationship.ExpiresAt = timestamppb.New(r.ExpiresAt.Time)
+		}
+
+		resp.Rejected = append(resp.Rejected, &authorizerpb.RejectedRelationship{Relationship: relationship, Reason: r.Reason})
+	}
+
+	return resp, nil
+}
+
+func runRejected(args []string) {
+	flags := flag.NewFlagSet("rejected", flag.ExitOnError)
+	schemaPath := flags.String("schema-path", "schema.json", "Path to the (.json) schema file the relationships of -relationships are checked against")
+	relationshipsPath := flags.String("relationships", "", "Path to a (.csv, .ndjson or .zed) relationships file to check. The rejected_relationships view of the pipeline is read if empty")
+	format := flags.String("format", "", "The format of the relationships file (csv, ndjson or zed), by default inferred from its extension")
+	felderaURL := flags.String("feldera-url", defaultFelderaURL, "The URL of the Feldera pipeline manager")
+	pipeline := flags.String("pipeline", "rebac", "The name of the Feldera pipeline")
+	flags.Parse(args)
+
+	var rejected []RejectedRelationship
+	if *relationshipsPath != "" {
+		schema, err := loadSchema(*schemaPath)
+		if err != nil {
+			log.Fatalf("failed to load schema: %v", err)
+		}
+
+		relationships, err := readRelationshipsFile(*relationshipsPath, *format)
+		if err != nil {
+			log.Fatalf("failed to load relationships: %v", err)
+		}
+
+		rules, err := mapSchemaToQueryRules(schema)
+		if err != nil {
+			log.Fatalf("failed to map schema to rules: %v", err)
+		}
+
+		rejected = rejectRelationships(rules, relationships)
+	} else {
+		feldera := &felderaClient{httpClient: http.DefaultClient, baseURL: *felderaURL, pipeline: *pipeline}
+
+		var err error
+		rejected, err = feldera.rejectedRelationships(context.Background())
+		if err != nil {
+			log.Fatalf("failed to read rejected relationships: %v", err)
+		}
+	}
+
+	for _, r := range rejected {
+		fmt.Printf("%s: %s\n", r.Relationship, r.Reason)
+	}
+
+	if len(rejected) > 0 {
+		fmt.Printf("found %d rejected relationship(s)\n", len(rejected))
+		os.Exit(1)
+	}
+
+	fmt.Println("no rejected relationships found")
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	authorizerpb "github.com/jon-whit/feldera-rebac/protos/gen/go/authorizer/v1alpha1"
+)
+
+func TestRejectionReason(t *testing.T) {
+	expiresAt := &Timestamp{time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	tests := []struct {
+		relationship Relationship
+		reason       string
+	}{
+		{Relationship{ResourceType: "document", ResourceID: "1", Relation: "viewer", SubjectType: "user", SubjectID: "jon"}, ""},
+		{Relationship{ResourceType: "folder", ResourceID: "1", Relation: "viewer", SubjectType: "user", SubjectID: "jon"}, "unknown resource type"},
+		{Relationship{ResourceType: "document", ResourceID: "1", Relation: "owner", SubjectType: "user", SubjectID: "jon"}, "unknown relation"},
+		{Relationship{ResourceType: "document", ResourceID: "1", Relation: "viewer", SubjectType: "team", SubjectID: "eng"}, "subject type not allowed"},
+		{Relationship{ResourceType: "document", ResourceID: "1", Relation: "viewer", SubjectType: "group", SubjectID: "eng"}, "missing subject relation"},
+		{Relationship{ResourceType: "document", ResourceID: "1", Relation: "viewer", SubjectType: "group", SubjectID: "eng", SubjectRelation: "owner"}, "subject relation not allowed"},
+		{Relationship{ResourceType: "document", ResourceID: "1", Relation: "editor", SubjectType: "user", SubjectID: "*", CaveatName: "ip_allowlist"}, "wildcard not allowed"},
+		{Relationship{ResourceType: "document", ResourceID: "1", Relation: "editor", SubjectType: "user", SubjectID: "jon"}, "missing caveat"},
+		{Relationship{ResourceType: "document", ResourceID: "1", Relation: "editor", SubjectType: "user", SubjectID: "jon", CaveatName: "weekdays"}, "caveat not allowed"},
+		{Relationship{ResourceType: "document", ResourceID: "1", Relation: "viewer", SubjectType: "user", SubjectID: "jon", ExpiresAt: expiresAt}, "expiration not allowed"},
+	}
+
+	for _, test := range tests {
+		if reason := rejectionReason(importRules, test.relationship); reason != test.reason {
+			t.Errorf("expected '%s' to be rejected with '%s', got '%s'", test.relationship, test.reason, reason)
+		}
+	}
+
+	// the relationship of the README which the pipeline ignores
+	schema, err := loadSchema("schema.json")
+	if err != nil {
+		t.Fatalf("failed to load schema: %v", err)
+	}
+
+	readme := Relationship{SubjectType: "other", SubjectID: "someid", ResourceType: "subreddit", ResourceID: "r/cats", Relation: "moderator"}
+	if reason := rejectionReason(mustMapSchemaToQueryRules(t, schema), readme); reason != "subject type not allowed" {
+		t.Errorf("expected '%s' to be rejected with 'subject type not allowed', got '%s'", readme, reason)
+	}
+}
+
+func TestListRejectedRelationships(t *testing.T) {
+	feldera := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v0/pipelines/rebac/query" || r.URL.Query().Get("sql") != "SELECT * FROM rejected_relationships" {
+			http.Error(w, "unexpected request", http.StatusNotFound)
+			return
+		}
+
+		fmt.Fprintln(w, `{"subject_type": "other", "subject_id": "someid", "subject_relation": "", "resource_type": "subreddit", "resource_id": "r/cats", "relationship": "moderator", "caveat_name": "", "caveat_context": "{}", "expires_at": null, "reason": "subject type not allowed"}`)
+		fmt.Fprintln(w, `{"subject_type": "account", "subject_id": "bob", "subject_relation": "", "resource_type": "forum", "resource_id": "1", "relationship": "member", "caveat_name": "", "caveat_context": "{}", "expires_at": null, "reason": "unknown resource type"}`)
+	}))
+	defer feldera.Close()
+
+	server := &authorizerServer{feldera: &felderaClient{httpClient: feldera.Client(), baseURL: feldera.URL, pipeline: "rebac"}}
+
+	resp, err := server.ListRejectedRelationships(context.Background(), &authorizerpb.ListRejectedRelationshipsRequest{ResourceType: "subreddit"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(resp.GetRejected()) != 1 {
+		t.Fatalf("expected 1 rejected relationship, got %v", resp.GetRejected())
+	}
+
+	rejected := resp.GetRejected()[0]
+	if rejected.GetReason() != "subject type not allowed" || rejected.GetRelationship().GetSubjectId() != "someid" || rejected.GetRelationship().GetCaveatContext() != "" {
+		t.Errorf("unexpected rejected relationship %v", rejected)
+	}
+
+	// dev mode lists the relationships rejected when they were loaded
+	dev := &authorizerServer{rejected: rejectRelationships(importRules, []Relationship{
+		{ResourceType: "document", ResourceID: "1", Relation: "viewer", SubjectType: "user", SubjectID: "jon"},
+		{ResourceType: "document", ResourceID: "1", Relation: "viewer", SubjectType: "group", SubjectID: "eng"},
+	})}
+
+	resp, err = dev.ListRejectedRelationships(context.Background(), &authorizerpb.ListRejectedRelationshipsRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(resp.GetRejected()) != 1 || resp.GetRejected()[0].GetReason() != "missing subject relation" {
+		t.Errorf("expected group:eng to be rejected for its missing subject relation, got %v", resp.GetRejected())
+	}
+}
+
+func TestGenerateProgram_RejectedRelationships(t *testing.T) {
+	sql, err := GenerateProgram(ProgramOptions{})
+	if err != nil {
+		t.Fatalf("failed to generate program: %v", err)
+	}
+
+	// the reasons of the view are those of rejectionReason
+	for _, reason := range []string{
+		"unknown resource type", "unknown relation", "subject type not allowed",
+		"missing subject relation", "subject relation not allowed", "wildcard not allowed",
+		"wildcard required", "missing caveat", "caveat not allowed", "expiration not allowed",
+	} {
+		if !strings.Contains(sql, "'"+reason+"'") {
+			t.Errorf("expected the rejected_relationships view to have the reason '%s'", reason)
+		}
+	}
+
+	if !strings.Contains(sql, "CREATE MATERIALIZED VIEW rejected_relationships AS") {
+		t.Errorf("expected the program to materialize rejected_relationships")
+	}
+}
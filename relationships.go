@@ -0,0 +1,382 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// wildcardSubjectID is the subject id of a relationship which applies to every
+// subject of the subject type (e.g. 'user:*').
+const wildcardSubjectID = "*"
+
+// Relationship is a row of the relationships table, or of any of the views
+// derived from it in the Feldera program.
+type Relationship struct {
+	SubjectType     string `json:"subject_type"`
+	SubjectID       string `json:"subject_id"`
+	SubjectRelation string `json:"subject_relation"`
+	ResourceType    string `json:"resource_type"`
+	ResourceID      string `json:"resource_id"`
+	Relation        string `json:"relationship"`
+
+	// CaveatName and CaveatContext are set on relationships written with a
+	// caveat. CaveatContext is a JSON object of caveat parameter values.
+	CaveatName    string `json:"caveat_name,omitempty"`
+	CaveatContext string `json:"caveat_context,omitempty"`
+
+	// Caveats are the caveats a derived relationship is conditioned on, each
+	// encoded as a JSON caveatRef.
+	Caveats []string `json:"caveats,omitempty"`
+
+	// ExpiresAt is the time a relationship expires at, or nil if it doesn't
+	// expire. A derived relationship expires with the first relationship it
+	// was derived from.
+	ExpiresAt *Timestamp `json:"expires_at,omitempty"`
+}
+
+// Expired returns true if the relationship has expired at the given time.
+func (r Relationship) Expired(now time.Time) bool {
+	return r.ExpiresAt != nil && !r.ExpiresAt.After(now)
+}
+
+func (r Relationship) String() string {
+	subject := fmt.Sprintf("%s:%s", r.SubjectType, r.SubjectID)
+	if r.SubjectRelation != "" {
+		subject += "#" + r.SubjectRelation
+	}
+
+	if r.CaveatName != "" {
+		return fmt.Sprintf("%s:%s#%s@%s[%s]", r.ResourceType, r.ResourceID, r.Relation, subject, r.CaveatName)
+	}
+
+	return fmt.Sprintf("%s:%s#%s@%s", r.ResourceType, r.ResourceID, r.Relation, subject)
+}
+
+// parseRelationship parses a relationship in the form of its String, with an
+// optional caveat context and expiration time in the style of SpiceDB (e.g.
+// 'document:1#viewer@user:jon[ip_allowlist:{"cidr": "10.0.0.0/8"}][expiration:2030-01-01T00:00:00Z]').
+func parseRelationship(s string) (Relationship, error) {
+	var r Relationship
+
+	tuple, suffix, _ := strings.Cut(s, "[")
+	tuple = strings.TrimSpace(tuple)
+	if suffix != "" {
+		suffix = "[" + suffix
+	}
+
+	resource, subject, ok := strings.Cut(tuple, "@")
+	if !ok {
+		return r, fmt.Errorf("invalid relationship '%s': missing subject", s)
+	}
+
+	resource, r.Relation, ok = strings.Cut(resource, "#")
+	if !ok {
+		return r, fmt.Errorf("invalid relationship '%s': missing relation", s)
+	}
+
+	r.ResourceType, r.ResourceID, ok = strings.Cut(resource, ":")
+	if !ok {
+		return r, fmt.Errorf("invalid relationship '%s': missing resource id", s)
+	}
+
+	subject, r.SubjectRelation, _ = strings.Cut(subject, "#")
+	r.SubjectType, r.SubjectID, ok = strings.Cut(subject, ":")
+	if !ok {
+		return r, fmt.Errorf("invalid relationship '%s': missing subject id", s)
+	}
+
+	for suffix != "" {
+		end := closingBracket(suffix)
+		if !strings.HasPrefix(suffix, "[") || end < 0 {
+			return r, fmt.Errorf("invalid relationship '%s': unterminated '['", s)
+		}
+
+		name, value, _ := strings.Cut(suffix[1:end], ":")
+		suffix = strings.TrimSpace(suffix[end+1:])
+
+		if name == "expiration" {
+			expiresAt, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return r, fmt.Errorf("invalid relationship '%s': invalid expiration: %w", s, err)
+			}
+
+			r.ExpiresAt = &Timestamp{expiresAt.UTC()}
+			continue
+		}
+
+		r.CaveatName = name
+		r.CaveatContext = value
+		if value != "" && !json.Valid([]byte(value)) {
+			return r, fmt.Errorf("invalid relationship '%s': invalid caveat context", s)
+		}
+	}
+
+	if r.ResourceType == "" || r.ResourceID == "" || r.Relation == "" || r.SubjectType == "" || r.SubjectID == "" {
+		return r, fmt.Errorf("invalid relationship '%s'", s)
+	}
+
+	return r, nil
+}
+
+// closingBracket returns the index of the ']' closing the '[' s begins with,
+// skipping the brackets of any JSON value within them, or -1 if there is none.
+func closingBracket(s string) int {
+	depth := 0
+	inString := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case inString && c == '\\':
+			i++
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// timestampLayout is the layout of SQL TIMESTAMP values in Feldera's JSON
+// format. Timestamps are in UTC.
+const timestampLayout = "2006-01-02 15:04:05.999999"
+
+// Timestamp is a SQL TIMESTAMP encoded in Feldera's JSON format.
+type Timestamp struct {
+	time.Time
+}
+
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + t.UTC().Format(timestampLayout) + `"`), nil
+}
+
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	value := strings.Trim(string(data), `"`)
+
+	parsed, err := time.Parse(timestampLayout, value)
+	if err != nil {
+		// timestamps written by other tools may be in RFC 3339 format
+		parsed, err = time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return fmt.Errorf("invalid timestamp '%s'", value)
+		}
+	}
+
+	t.Time = parsed.UTC()
+	return nil
+}
+
+// relationshipColumns are the columns of the relationships table, in order.
+var relationshipColumns = []string{
+	"subject_type",
+	"subject_id",
+	"subject_relation",
+	"resource_type",
+	"resource_id",
+	"relationship",
+	"caveat_name",
+	"caveat_context",
+	"expires_at",
+}
+
+// relationshipFormat returns the format of a relationships file from its
+// extension.
+func relationshipFormat(path string) (string, error) {
+	switch filepath.Ext(path) {
+	case ".csv":
+		return "csv", nil
+	case ".ndjson", ".jsonl":
+		return "ndjson", nil
+	case ".zed":
+		return "zed", nil
+	default:
+		return "", fmt.Errorf("unknown format of relationships file '%s'", path)
+	}
+}
+
+// readRelationshipsFile reads the relationships of a file in the given format,
+// or in the format of its extension if format is empty.
+func readRelationshipsFile(path, format string) ([]Relationship, error) {
+	if format == "" {
+		var err error
+		format, err = relationshipFormat(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open relationships file: %w", err)
+	}
+	defer f.Close()
+
+	var relationships []Relationship
+	err = readRelationships(f, format, func(r Relationship) error {
+		relationships = append(relationships, r)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read '%s': %w", path, err)
+	}
+
+	return relationships, nil
+}
+
+// readRelationships reads relationships in the given format, calling fn for
+// each one.
+//
+// The 'csv' format has a header row naming the columns of the relationships
+// table, the 'ndjson' format has a JSON object per line with the columns of
+// the relationships table as keys, and the 'zed' format has a relationship per
+// line in the text format of SpiceDB's zed CLI.
+func readRelationships(r io.Reader, format string, fn func(Relationship) error) error {
+	switch format {
+	case "csv":
+		return readCSVRelationships(r, fn)
+	case "ndjson":
+		return readNDJSONRelationships(r, fn)
+	case "zed":
+		return readZedRelationships(r, fn)
+	default:
+		return fmt.Errorf("unsupported relationships format '%s'", format)
+	}
+}
+
+func readCSVRelationships(r io.Reader, fn func(Relationship) error) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, column := range header {
+		columns[strings.TrimSpace(column)] = i
+	}
+
+	for _, required := range relationshipColumns[:6] {
+		if _, ok := columns[required]; !ok {
+			return fmt.Errorf("missing column '%s'", required)
+		}
+	}
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		field := func(column string) string {
+			i, ok := columns[column]
+			if !ok || i >= len(record) {
+				return ""
+			}
+
+			return record[i]
+		}
+
+		relationship := Relationship{
+			SubjectType:     field("subject_type"),
+			SubjectID:       field("subject_id"),
+			SubjectRelation: field("subject_relation"),
+			ResourceType:    field("resource_type"),
+			ResourceID:      field("resource_id"),
+			Relation:        field("relationship"),
+			CaveatName:      field("caveat_name"),
+			CaveatContext:   field("caveat_context"),
+		}
+
+		if expiresAt := field("expires_at"); expiresAt != "" {
+			var t Timestamp
+			if err := t.UnmarshalJSON([]byte(expiresAt)); err != nil {
+				return fmt.Errorf("line %d: %w", line(reader), err)
+			}
+
+			relationship.ExpiresAt = &t
+		}
+
+		if err := fn(relationship); err != nil {
+			return fmt.Errorf("line %d: %w", line(reader), err)
+		}
+	}
+}
+
+// line returns the line of the last record read by a csv.Reader.
+func line(reader *csv.Reader) int {
+	l, _ := reader.FieldPos(0)
+	return l
+}
+
+func readNDJSONRelationships(r io.Reader, fn func(Relationship) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for l := 1; scanner.Scan(); l++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		var relationship Relationship
+		if err := json.Unmarshal(scanner.Bytes(), &relationship); err != nil {
+			return fmt.Errorf("line %d: %w", l, err)
+		}
+
+		if err := fn(relationship); err != nil {
+			return fmt.Errorf("line %d: %w", l, err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// readZedRelationships reads relationships in the form parsed by
+// parseRelationship (e.g. 'document:1#viewer@user:jon'), or in the form
+// printed by 'zed relationship read' (e.g. 'document:1 viewer user:jon').
+// Blank lines and '//' comments are skipped.
+func readZedRelationships(r io.Reader, fn func(Relationship) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for l := 1; scanner.Scan(); l++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "//") {
+			continue
+		}
+
+		if resource, rest, ok := strings.Cut(text, " "); ok && !strings.Contains(resource, "#") {
+			relation, subject, _ := strings.Cut(strings.TrimSpace(rest), " ")
+			text = resource + "#" + relation + "@" + strings.TrimSpace(subject)
+		}
+
+		relationship, err := parseRelationship(text)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", l, err)
+		}
+
+		if err := fn(relationship); err != nil {
+			return fmt.Errorf("line %d: %w", l, err)
+		}
+	}
+
+	return scanner.Err()
+}
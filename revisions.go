@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// revisionRetention is how long the pipeline keeps a revision and its marker,
+// in whole hours since the program is generated with it. The writes of older
+// revisions are assumed to be reflected in the store.
+const revisionRetention = time.Hour
+
+// revisionPollInterval is how often a read waiting for a revision checks for
+// its marker.
+const revisionPollInterval = 10 * time.Millisecond
+
+// revisionClock assigns increasing revisions to writes. A revision is the time
+// of the write in nanoseconds, so that the age of the revision of a token can
+// be compared to revisionRetention.
+type revisionClock struct {
+	last atomic.Int64
+}
+
+func (c *revisionClock) next() int64 {
+	for {
+		last := c.last.Load()
+		revision := max(time.Now().UnixNano(), last+1)
+		if c.last.CompareAndSwap(last, revision) {
+			return revision
+		}
+	}
+}
+
+func encodeRevision(revision int64) string {
+	return base64.RawURLEncoding.EncodeToString(binary.BigEndian.AppendUint64(nil, uint64(revision)))
+}
+
+func decodeRevision(token string) (int64, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(b) != 8 {
+		return 0, fmt.Errorf("invalid revision '%s'", token)
+	}
+
+	return int64(binary.BigEndian.Uint64(b)), nil
+}
+
+// waitForRevision blocks until the derived relationships of the writes of the
+// revision token are in the store, or fails once the freshness timeout has
+// passed. It returns immediately if the token is empty.
+func (s *authorizerServer) waitForRevision(ctx context.Context, token string) error {
+	if token == "" {
+		return nil
+	}
+
+	revision, err := decodeRevision(token)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid at_least_as_fresh: %v", err)
+	}
+
+	if time.Since(time.Unix(0, revision)) > revisionRetention {
+		return nil
+	}
+
+	deadline := time.Now().Add(s.freshnessTimeout)
+	for {
+		reached, err := s.store.RevisionReached(ctx, revision)
+		if err != nil {
+			return status.Errorf(codes.Unavailable, "failed to check the revision: %v", err)
+		}
+
+		if reached {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return status.Errorf(codes.Unavailable, "the writes of revision '%s' are not yet reflected", token)
+		}
+
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-time.After(revisionPollInterval):
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	authorizerpb "github.com/jon-whit/feldera-rebac/protos/gen/go/authorizer/v1alpha1"
+	"github.com/pashagolub/pgxmock/v4"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestRevisionToken(t *testing.T) {
+	var clock revisionClock
+
+	first, second := clock.next(), clock.next()
+	if second <= first {
+		t.Errorf("expected increasing revisions, got %d then %d", first, second)
+	}
+
+	revision, err := decodeRevision(encodeRevision(first))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if revision != first {
+		t.Errorf("expected revision %d, got %d", first, revision)
+	}
+
+	if _, err := decodeRevision("not-a-revision"); err == nil {
+		t.Errorf("expected an invalid token to fail to decode")
+	}
+}
+
+func TestCheck_AtLeastAsFresh(t *testing.T) {
+	server := newTestServer(t, []Relationship{
+		{SubjectType: "user", SubjectID: "jon", ResourceType: "document", ResourceID: "1", Relation: "can_view"},
+	})
+
+	reached := server.revisions.next()
+	pending := server.revisions.next()
+	partial := server.revisions.next()
+	for _, key := range revisionKeys(reached) {
+		if err := server.store.(*redisStore).client.Set(context.Background(), key, "{}", 0).Err(); err != nil {
+			t.Fatalf("failed to write the revision marker: %v", err)
+		}
+	}
+
+	// only the resource-first connector has written the step of the revision
+	if err := server.store.(*redisStore).client.Set(context.Background(), revisionKeys(partial)[0], "{}", 0).Err(); err != nil {
+		t.Fatalf("failed to write the revision marker: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		revision string
+		code     codes.Code
+	}{
+		{name: "reached", revision: encodeRevision(reached), code: codes.OK},
+		{name: "pending", revision: encodeRevision(pending), code: codes.Unavailable},
+		{name: "partial", revision: encodeRevision(partial), code: codes.Unavailable},
+		{name: "retracted", revision: encodeRevision(time.Now().Add(-2 * revisionRetention).UnixNano()), code: codes.OK},
+		{name: "invalid", revision: "?", code: codes.InvalidArgument},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := server.Check(context.Background(), &authorizerpb.CheckRequest{
+				ResourceType:   "document",
+				ResourceIds:    []string{"1"},
+				Relation:       "can_view",
+				SubjectType:    "user",
+				SubjectId:      "jon",
+				AtLeastAsFresh: test.revision,
+			})
+			if code := status.Code(err); code != test.code {
+				t.Errorf("expected %v, got %v (%v)", test.code, code, err)
+			}
+		})
+	}
+}
+
+func TestWriteRelationships(t *testing.T) {
+	var pushes []string
+	var rows []map[string]any
+	feldera := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		table, ok := strings.CutPrefix(r.URL.Path, "/v0/pipelines/rebac/ingress/")
+		if r.Method != http.MethodPost || !ok || r.URL.Query().Get("update_format") != "insert_delete" {
+			http.Error(w, "unexpected request", http.StatusNotFound)
+			return
+		}
+
+		pushes = append(pushes, table)
+
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var row map[string]any
+			if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			rows = append(rows, row)
+		}
+	}))
+	defer feldera.Close()
+
+	postgres, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("failed to create the postgres mock: %v", err)
+	}
+	defer postgres.Close()
+
+	// the writes are persisted to the relationships table as well
+	postgres.ExpectBegin()
+	postgres.ExpectExec("delete from relationships").
+		WithArgs("user", "bob", "", "document", "1", "viewer", "ip", `{"allowed": "10.0.0.0/8"}`, pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	postgres.ExpectCopyFrom(pgx.Identifier{"relationships"}, relationshipColumns).WillReturnResult(1)
+	postgres.ExpectCommit()
+
+	server := &authorizerServer{
+		feldera:  &felderaClient{httpClient: feldera.Client(), baseURL: feldera.URL, pipeline: "rebac"},
+		postgres: postgres,
+		rules: SchemaQueryRules{RelationTypeRestrictions: []RelationTypeRestriction{
+			{ResourceType: "document", Relation: "viewer", SubjectType: "user"},
+		}},
+	}
+
+	resp, err := server.WriteRelationships(context.Background(), &authorizerpb.WriteRelationshipsRequest{
+		Writes: []*authorizerpb.Relationship{
+			{ResourceType: "document", ResourceId: "1", Relation: "viewer", SubjectType: "user", SubjectId: "jon"},
+		},
+		Deletes: []*authorizerpb.Relationship{
+			{ResourceType: "document", ResourceId: "1", Relation: "viewer", SubjectType: "user", SubjectId: "bob", CaveatName: "ip", CaveatContext: `{"allowed": "10.0.0.0/8"}`},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	revision, err := decodeRevision(resp.GetRevision())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the revision is pushed after the relationships it was written at
+	if strings.Join(pushes, ",") != "relationships,revisions" {
+		t.Fatalf("expected pushes to relationships and then revisions, got %v", pushes)
+	}
+
+	if len(rows) != 3 {
+		t.Fatalf("expected 3 rows, got %v", rows)
+	}
+
+	deleted := rows[0]["delete"].(map[string]any)
+	if deleted["subject_id"] != "bob" || deleted["caveat_name"] != "ip" || deleted["subject_relation"] != "" {
+		t.Errorf("unexpected deleted row %v", deleted)
+	}
+
+	inserted := rows[1]["insert"].(map[string]any)
+	if inserted["subject_id"] != "jon" || inserted["caveat_context"] != "{}" || inserted["expires_at"] != nil {
+		t.Errorf("unexpected inserted row %v", inserted)
+	}
+
+	if got := rows[2]["insert"].(map[string]any)["revision"]; got != float64(revision) {
+		t.Errorf("expected revision %d, got %v", revision, got)
+	}
+
+	invalid := map[string]*authorizerpb.Relationship{
+		"without a resource id":  {ResourceType: "document", Relation: "viewer", SubjectType: "user", SubjectId: "jon"},
+		"of an unknown relation": {ResourceType: "document", ResourceId: "1", Relation: "owner", SubjectType: "user", SubjectId: "jon"},
+		"with a caveat":          {ResourceType: "document", ResourceId: "1", Relation: "viewer", SubjectType: "user", SubjectId: "jon", CaveatName: "ip"},
+		"with an expiration":     {ResourceType: "document", ResourceId: "1", Relation: "viewer", SubjectType: "user", SubjectId: "jon", ExpiresAt: timestamppb.Now()},
+	}
+
+	for name, write := range invalid {
+		pushes = nil
+
+		_, err = server.WriteRelationships(context.Background(), &authorizerpb.WriteRelationshipsRequest{
+			Writes: []*authorizerpb.Relationship{write},
+		})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("expected InvalidArgument for a write %s, got %v", name, err)
+		}
+
+		if len(pushes) != 0 {
+			t.Errorf("expected a write %s not to be pushed, got pushes to %v", name, pushes)
+		}
+	}
+
+	if err := postgres.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWriteRelationships_FailedPush(t *testing.T) {
+	feldera := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "pipeline is not running", http.StatusServiceUnavailable)
+	}))
+	defer feldera.Close()
+
+	postgres, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("failed to create the postgres mock: %v", err)
+	}
+	defer postgres.Close()
+
+	// the writes the pipeline didn't receive aren't persisted
+	postgres.ExpectBegin()
+	postgres.ExpectCopyFrom(pgx.Identifier{"relationships"}, relationshipColumns).WillReturnResult(1)
+	postgres.ExpectRollback()
+
+	server := &authorizerServer{
+		feldera:  &felderaClient{httpClient: feldera.Client(), baseURL: feldera.URL, pipeline: "rebac"},
+		postgres: postgres,
+		rules: SchemaQueryRules{RelationTypeRestrictions: []RelationTypeRestriction{
+			{ResourceType: "document", Relation: "viewer", SubjectType: "user"},
+		}},
+	}
+
+	_, err = server.WriteRelationships(context.Background(), &authorizerpb.WriteRelationshipsRequest{
+		Writes: []*authorizerpb.Relationship{
+			{ResourceType: "document", ResourceId: "1", Relation: "viewer", SubjectType: "user", SubjectId: "jon"},
+		},
+	})
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("expected Unavailable, got %v", err)
+	}
+
+	if err := postgres.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 
 	authorizerpb "github.com/jon-whit/feldera-rebac/protos/gen/go/authorizer/v1alpha1"
@@ -12,6 +13,11 @@ type SchemaQueryRules struct {
 	RelationTypeRestrictions []RelationTypeRestriction `json:"relation_type_restrictions"`
 	UnaryRules               []UnaryRule               `json:"unary_rules"`
 	BinaryRules              []BinaryRule              `json:"binary_rules"`
+
+	// NegatedBinaryRules derive the relationships of the subjects of the first
+	// relationship of each rule which don't also have the second relationship
+	// to the same resource (e.g. 'viewer - restricted').
+	NegatedBinaryRules []BinaryRule `json:"negated_binary_rules,omitempty"`
 }
 
 func (s SchemaQueryRules) ToSQL() string {
@@ -22,9 +28,9 @@ func (s SchemaQueryRules) ToSQL() string {
 		sql += "INSERT INTO type_restrictions VALUES\n"
 		for i, typeRestriction := range s.RelationTypeRestrictions {
 			if i == len(s.RelationTypeRestrictions)-1 {
-				sql += fmt.Sprintf("('%s', '%s', '%s', '%s');", typeRestriction.ResourceType, typeRestriction.Relation, typeRestriction.SubjectType, typeRestriction.SubjectRelation)
+				sql += fmt.Sprintf("('%s', '%s', '%s', '%s', %t, '%s', %t);", typeRestriction.ResourceType, typeRestriction.Relation, typeRestriction.SubjectType, typeRestriction.SubjectRelation, typeRestriction.Wildcard, typeRestriction.Caveat, typeRestriction.WithExpiration)
 			} else {
-				sql += fmt.Sprintf("('%s', '%s', '%s', '%s'),\n", typeRestriction.ResourceType, typeRestriction.Relation, typeRestriction.SubjectType, typeRestriction.SubjectRelation)
+				sql += fmt.Sprintf("('%s', '%s', '%s', '%s', %t, '%s', %t),\n", typeRestriction.ResourceType, typeRestriction.Relation, typeRestriction.SubjectType, typeRestriction.SubjectRelation, typeRestriction.Wildcard, typeRestriction.Caveat, typeRestriction.WithExpiration)
 			}
 		}
 	}
@@ -48,9 +54,22 @@ func (s SchemaQueryRules) ToSQL() string {
 		sql += "INSERT INTO binary_rules VALUES\n"
 		for i, binaryRule := range s.BinaryRules {
 			if i == len(s.BinaryRules)-1 {
-				sql += fmt.Sprintf("('%s', '%s', '%s', '%s', '%s');", binaryRule.FirstResourceType, binaryRule.FirstRelation, binaryRule.SecondResourceType, binaryRule.SecondRelation, binaryRule.DerivedRelation)
+				sql += fmt.Sprintf("('%s', '%s', '%s', '%s', '%s', '%s');", binaryRule.FirstResourceType, binaryRule.FirstRelation, binaryRule.SecondResourceType, binaryRule.SecondRelation, binaryRule.DerivedRelation, binaryRule.Kind)
 			} else {
-				sql += fmt.Sprintf("('%s', '%s', '%s', '%s', '%s'),\n", binaryRule.FirstResourceType, binaryRule.FirstRelation, binaryRule.SecondResourceType, binaryRule.SecondRelation, binaryRule.DerivedRelation)
+				sql += fmt.Sprintf("('%s', '%s', '%s', '%s', '%s', '%s'),\n", binaryRule.FirstResourceType, binaryRule.FirstRelation, binaryRule.SecondResourceType, binaryRule.SecondRelation, binaryRule.DerivedRelation, binaryRule.Kind)
+			}
+		}
+	}
+
+	if len(s.NegatedBinaryRules) > 0 {
+		sql += "\n\n"
+
+		sql += "INSERT INTO negated_binary_rules VALUES\n"
+		for i, negatedRule := range s.NegatedBinaryRules {
+			if i == len(s.NegatedBinaryRules)-1 {
+				sql += fmt.Sprintf("('%s', '%s', '%s', '%s', '%s', %d);", negatedRule.FirstResourceType, negatedRule.FirstRelation, negatedRule.SecondResourceType, negatedRule.SecondRelation, negatedRule.DerivedRelation, negatedRule.Stratum)
+			} else {
+				sql += fmt.Sprintf("('%s', '%s', '%s', '%s', '%s', %d),\n", negatedRule.FirstResourceType, negatedRule.FirstRelation, negatedRule.SecondResourceType, negatedRule.SecondRelation, negatedRule.DerivedRelation, negatedRule.Stratum)
 			}
 		}
 	}
@@ -63,14 +82,39 @@ type RelationTypeRestriction struct {
 	Relation        string `json:"relation"`
 	SubjectType     string `json:"subject_type"`
 	SubjectRelation string `json:"subject_relation"`
+
+	// Wildcard restrictions permit relationships to the subject 'subject_type:*'
+	// instead of to individual subjects of the subject type.
+	Wildcard bool `json:"wildcard"`
+
+	// Caveat is the name of the caveat relationships for this restriction are
+	// written with, or empty for relationships without a caveat.
+	Caveat string `json:"caveat"`
+
+	// WithExpiration permits relationships for this restriction to be written
+	// with an expiration time.
+	WithExpiration bool `json:"with_expiration"`
 }
 
 func (r RelationTypeRestriction) String() string {
-	if r.SubjectRelation != "" {
-		return fmt.Sprintf("%s(%s#%s, %s)", r.Relation, r.SubjectType, r.SubjectRelation, r.ResourceType)
+	subject := r.SubjectType
+	switch {
+	case r.Wildcard:
+		subject += ":*"
+	case r.SubjectRelation != "":
+		subject += "#" + r.SubjectRelation
+	}
+
+	switch {
+	case r.Caveat != "" && r.WithExpiration:
+		subject += " with " + r.Caveat + " and expiration"
+	case r.Caveat != "":
+		subject += " with " + r.Caveat
+	case r.WithExpiration:
+		subject += " with expiration"
 	}
 
-	return fmt.Sprintf("%s(%s, %s)", r.Relation, r.SubjectType, r.ResourceType)
+	return fmt.Sprintf("%s(%s, %s)", r.Relation, subject, r.ResourceType)
 }
 
 type UnaryRule struct {
@@ -79,106 +123,228 @@ type UnaryRule struct {
 	DerivedRelation string `json:"derived_relation"`
 }
 
-/*
-(1, hierarchy) - can_view(subject, folder), parent(folder, document) :- can_view(subject, document)
-(2, intersection) - viewer(subject, document), allowed(subject, document) :- can_view(subject, object)
-*/
+// String returns the rule as the row of the unary_rules table.
+func (r UnaryRule) String() string {
+	return fmt.Sprintf("unary_rules('%s', '%s', '%s')", r.ResourceType, r.SourceRelation, r.DerivedRelation)
+}
+
+// BinaryRuleKind is the kind of join of the relationships of a binary rule.
+type BinaryRuleKind string
+
+const (
+
+	// ArrowRule derives the relationship of the subjects of the first
+	// relationship to the resources with the second relationship to its
+	// resource (e.g. 'parent->can_view').
+	//
+	//	can_view(subject, folder), parent(folder, document) :- can_view(subject, document)
+	ArrowRule BinaryRuleKind = "arrow"
+
+	// IntersectionRule derives the relationship of the subjects with both
+	// relationships to the same resource (e.g. 'viewer & allowed').
+	//
+	//	viewer(subject, document), allowed(subject, document) :- can_view(subject, document)
+	IntersectionRule BinaryRuleKind = "intersection"
+)
+
 type BinaryRule struct {
-	FirstResourceType  string `json:"first_resource_type"`
-	FirstRelation      string `json:"first_relation"`
-	SecondResourceType string `json:"second_resource_type"`
-	SecondRelation     string `json:"second_relation"`
-	DerivedRelation    string `json:"derived_relation"`
+	FirstResourceType  string         `json:"first_resource_type"`
+	FirstRelation      string         `json:"first_relation"`
+	SecondResourceType string         `json:"second_resource_type"`
+	SecondRelation     string         `json:"second_relation"`
+	DerivedRelation    string         `json:"derived_relation"`
+	Kind               BinaryRuleKind `json:"kind,omitempty"`
+
+	// Stratum is the stratum of a negated rule, which excludes subjects by
+	// the relationships of the previous stratum. See stratify.
+	Stratum int `json:"stratum,omitempty"`
 }
 
+// String returns the rule as the row of the binary_rules table, or of the
+// negated_binary_rules table for a negated rule with a stratum.
+func (r BinaryRule) String() string {
+	if r.Stratum > 0 {
+		return fmt.Sprintf("negated_binary_rules('%s', '%s', '%s', '%s', '%s', %d)", r.FirstResourceType, r.FirstRelation, r.SecondResourceType, r.SecondRelation, r.DerivedRelation, r.Stratum)
+	}
+
+	if r.Kind == "" {
+		return fmt.Sprintf("binary_rules('%s', '%s', '%s', '%s', '%s')", r.FirstResourceType, r.FirstRelation, r.SecondResourceType, r.SecondRelation, r.DerivedRelation)
+	}
+
+	return fmt.Sprintf("binary_rules('%s', '%s', '%s', '%s', '%s', '%s')", r.FirstResourceType, r.FirstRelation, r.SecondResourceType, r.SecondRelation, r.DerivedRelation, r.Kind)
+}
+
+// ruleCompiler compiles the expression of a permission into rules.
+type ruleCompiler struct {
+	schema         *authorizerpb.Schema
+	typedef        *authorizerpb.TypeDefinition
+	permissionName string
+
+	unaryRules         []UnaryRule
+	binaryRules        []BinaryRule
+	negatedBinaryRules []BinaryRule
+
+	// intermediates is the number of intermediate relations derived so far.
+	intermediates int
+}
+
+// expandPermissionExpressionRefV2 returns the relation the expression of the
+// permission is derived as, and the rules which derive it.
 func expandPermissionExpressionRefV2(
 	schema *authorizerpb.Schema,
 	typedef *authorizerpb.TypeDefinition,
 	permissionName string,
 	exp *authorizerpb.PermissionExpressionRef,
-) (string, []UnaryRule, []BinaryRule) {
-	var unaryRules []UnaryRule
-	var binaryRules []BinaryRule
-	var compositeKey string
+) (string, []UnaryRule, []BinaryRule, []BinaryRule, error) {
+	c := &ruleCompiler{schema: schema, typedef: typedef, permissionName: permissionName}
+	if err := c.derive(permissionName, exp); err != nil {
+		return "", nil, nil, nil, fmt.Errorf("failed to compile '%s#%s': %w", typedef.GetName(), permissionName, err)
+	}
+
+	return permissionName, c.unaryRules, c.binaryRules, c.negatedBinaryRules, nil
+}
 
-	resourceType := typedef.GetName()
+// derive adds the rules which derive the relation from the expression.
+func (c *ruleCompiler) derive(relation string, exp *authorizerpb.PermissionExpressionRef) error {
+	resourceType := c.typedef.GetName()
 
 	switch permissionExp := exp.GetExpression().(type) {
 	case *authorizerpb.PermissionExpressionRef_UnaryExpression:
-		rule := UnaryRule{
+		c.unaryRules = append(c.unaryRules, UnaryRule{
 			ResourceType:    resourceType,
 			SourceRelation:  permissionExp.UnaryExpression.GetSourceRelation(),
-			DerivedRelation: permissionName,
-		}
-		unaryRules = append(unaryRules, rule)
-
-		compositeKey = permissionName
+			DerivedRelation: relation,
+		})
 	case *authorizerpb.PermissionExpressionRef_HierarchicalExpression:
 		// get the type restrictions for base relation (e.g. parent)
 		baseRelationName := permissionExp.HierarchicalExpression.GetBase()
-		baseRelation, ok := typedef.GetRelations()[baseRelationName]
+		baseRelation, ok := c.typedef.GetRelations()[baseRelationName]
 		if !ok {
-			panic(fmt.Sprintf("undefined relation '%s'", baseRelationName))
+			return fmt.Errorf("undefined relation '%s'", baseRelationName)
 		}
 
-		for _, typeRestriction := range baseRelation.GetTypeRestrictions() {
-			targetRelation := permissionExp.HierarchicalExpression.GetTarget()
+		targetRelation := permissionExp.HierarchicalExpression.GetTarget()
 
-			// todo: get permissionexpref from target relation
-			targetPermissionExpRef := schema.GetTypeDefinitions()[typeRestriction.GetResourceType()].GetPermissions()[targetRelation].GetExpression()
+		seen := map[string]bool{}
+		for _, typeRestriction := range baseRelation.GetTypeRestrictions() {
+			targetType := c.schema.GetTypeDefinitions()[typeRestriction.GetResourceType()]
 
-			targetCompositeKey, _, _ := expandPermissionExpressionRefV2(schema, typedef, targetRelation, targetPermissionExpRef)
+			// like SpiceDB, the arrow only applies to the types of the base
+			// relation which define the target
+			_, isRelation := targetType.GetRelations()[targetRelation]
+			_, isPermission := targetType.GetPermissions()[targetRelation]
+			if seen[targetType.GetName()] || !(isRelation || isPermission) {
+				continue
+			}
 
-			binaryRules = append(binaryRules, BinaryRule{
-				FirstResourceType:  typeRestriction.GetResourceType(),
-				FirstRelation:      targetCompositeKey,
+			seen[targetType.GetName()] = true
+			c.binaryRules = append(c.binaryRules, BinaryRule{
+				FirstResourceType:  targetType.GetName(),
+				FirstRelation:      targetRelation,
 				SecondResourceType: resourceType,
 				SecondRelation:     baseRelationName,
-				DerivedRelation:    permissionName,
+				DerivedRelation:    relation,
+				Kind:               ArrowRule,
 			})
 		}
-
-		compositeKey = permissionName
 	case *authorizerpb.PermissionExpressionRef_SetExpression:
-		unary, binary := expandSetExpression(typedef, permissionName, permissionExp.SetExpression)
-		unaryRules = append(unaryRules, unary...)
-		binaryRules = append(binaryRules, binary...)
+		switch setExp := permissionExp.SetExpression.GetSetExpression().(type) {
+		case *authorizerpb.PermissionSetExpressionRef_Union_:
+			for _, operand := range setExp.Union.GetOperands() {
+				if err := c.derive(relation, operand); err != nil {
+					return err
+				}
+			}
+		case *authorizerpb.PermissionSetExpressionRef_Intersection_:
+			operands := setExp.Intersection.GetOperands()
+			if len(operands) < 2 {
+				return errors.New("intersection must have at least two operands")
+			}
+
+			// 'a & b & c' is derived as '(a & b) & c'
+			first, err := c.operand(operands[0])
+			if err != nil {
+				return err
+			}
+
+			for i, operand := range operands[1:] {
+				second, err := c.operand(operand)
+				if err != nil {
+					return err
+				}
+
+				derived := relation
+				if i < len(operands)-2 {
+					derived = c.intermediate()
+				}
+
+				c.binaryRules = append(c.binaryRules, BinaryRule{
+					FirstResourceType:  resourceType,
+					FirstRelation:      first,
+					SecondResourceType: resourceType,
+					SecondRelation:     second,
+					DerivedRelation:    derived,
+					Kind:               IntersectionRule,
+				})
+
+				first = derived
+			}
+		case *authorizerpb.PermissionSetExpressionRef_Exclusion_:
+			// 'a - b' is derived by a negated rule, whose operands are
+			// derived as intermediate relations unless they are relations
+			// or permissions (e.g. 'can_view = (a + b) - c' as
+			// '__can_view_1__ - c')
+			operands := setExp.Exclusion.GetOperands()
+			if len(operands) != 2 {
+				return errors.New("exclusion must have exactly two operands")
+			}
+
+			first, err := c.operand(operands[0])
+			if err != nil {
+				return err
+			}
+
+			second, err := c.operand(operands[1])
+			if err != nil {
+				return err
+			}
+
+			c.negatedBinaryRules = append(c.negatedBinaryRules, BinaryRule{
+				FirstResourceType:  resourceType,
+				FirstRelation:      first,
+				SecondResourceType: resourceType,
+				SecondRelation:     second,
+				DerivedRelation:    relation,
+			})
+		default:
+			return fmt.Errorf("unexpected set expression type %T", setExp)
+		}
 	default:
-		panic("unexpected PermissionExpressionRef type")
+		return fmt.Errorf("unexpected expression type %T", permissionExp)
 	}
 
-	return compositeKey, unaryRules, binaryRules
+	return nil
 }
 
-func expandSetExpressionV2(
-	schema *authorizerpb.Schema,
-	typedef *authorizerpb.TypeDefinition,
-	permissionName string,
-	setExp *authorizerpb.PermissionSetExpressionRef,
-) ([]UnaryRule, []BinaryRule) {
-	var unaryRules []UnaryRule
-	var binaryRules []BinaryRule
-
-	switch exp := setExp.SetExpression.(type) {
-	case *authorizerpb.PermissionSetExpressionRef_Union_:
-		for _, operand := range exp.Union.Operands {
-			compositeKey, operandUnaryRules, operandBinaryRules := expandPermissionExpressionRefV2(schema, typedef, permissionName, operand)
-			unaryRules = append(unaryRules, operandUnaryRules...)
-			binaryRules = append(binaryRules, operandBinaryRules...)
-		}
-	case *authorizerpb.PermissionSetExpressionRef_Intersection_:
-		if len(exp.Intersection.Operands) != 2 {
-			panic("intersection must have exactly two operands")
-		}
+// operand returns the relation of the subjects of an operand of an
+// intersection or exclusion: the relation or permission itself, or else an
+// intermediate relation derived from the operand.
+func (c *ruleCompiler) operand(exp *authorizerpb.PermissionExpressionRef) (string, error) {
+	if unary := exp.GetUnaryExpression(); unary != nil {
+		return unary.GetSourceRelation(), nil
+	}
 
-		for _, operand := range exp.Intersection.Operands {
-			compositeKey, operandUnaryRules, operandBinaryRules := expandPermissionExpressionRefV2(schema, typedef, permissionName, operand)
-			unaryRules = append(unaryRules, operandUnaryRules...)
-			binaryRules = append(binaryRules, operandBinaryRules...)
-		}
-	default:
-		panic("unexpected PermissionSetExpression type")
+	relation := c.intermediate()
+	if err := c.derive(relation, exp); err != nil {
+		return "", err
 	}
 
-	return unaryRules, binaryRules
+	return relation, nil
+}
+
+// intermediate returns a new intermediate relation of the permission (e.g.
+// '__can_view_1__' for the first intermediate relation of 'can_view').
+func (c *ruleCompiler) intermediate() string {
+	c.intermediates++
+	return fmt.Sprintf("__%s_%d__", c.permissionName, c.intermediates)
 }
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"errors"
 	"log"
 	"reflect"
+	"strings"
 	"testing"
 
 	authorizerpb "github.com/jon-whit/feldera-rebac/protos/gen/go/authorizer/v1alpha1"
@@ -88,7 +90,10 @@ func TestExpandPermissionExpressionRefV2(t *testing.T) {
 	permissionName := "can_edit_community_appearance"
 
 	typedef := schema.GetTypeDefinitions()["subreddit"]
-	compositeKey, unaryRules, _ := expandPermissionExpressionRefV2(schema, typedef, permissionName, schema.GetTypeDefinitions()["subreddit"].Permissions[permissionName].Expression)
+	compositeKey, unaryRules, _, _, err := expandPermissionExpressionRefV2(schema, typedef, permissionName, schema.GetTypeDefinitions()["subreddit"].Permissions[permissionName].Expression)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 
 	if compositeKey != permissionName {
 		t.Errorf("expected %s, got %s", permissionName, compositeKey)
@@ -166,13 +171,19 @@ func TestExpandPermissionExpressionRefV2(t *testing.T) {
 	}
 
 	typedef = schema.GetTypeDefinitions()["document"]
-	compositeKey, unaryRules, binaryRules := expandPermissionExpressionRefV2(schema, typedef, "can_view", schema.GetTypeDefinitions()["document"].Permissions["can_view"].Expression)
+	compositeKey, unaryRules, binaryRules, _, err := expandPermissionExpressionRefV2(schema, typedef, "can_view", schema.GetTypeDefinitions()["document"].Permissions["can_view"].Expression)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 
 	_ = compositeKey
 	_ = unaryRules
 	_ = binaryRules
 
-	unaryRules, binaryRules = rules(schema)
+	unaryRules, binaryRules, _, err = rules(schema)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 	expectedUnaryRules = []UnaryRule{
 		{
 			ResourceType:    "folder",
@@ -188,14 +199,362 @@ func TestExpandPermissionExpressionRefV2(t *testing.T) {
 			SecondResourceType: "document",
 			SecondRelation:     "parent",
 			DerivedRelation:    "can_view",
+			Kind:               ArrowRule,
+		},
+	}
+
+	if !reflect.DeepEqual(unaryRules, expectedUnaryRules) {
+		t.Errorf("expected %v, got %v", expectedUnaryRules, unaryRules)
+	}
+
+	if !reflect.DeepEqual(binaryRules, expectedBinaryRules) {
+		t.Errorf("expected %v, got %v", expectedBinaryRules, binaryRules)
+	}
+}
+
+func TestMapSchemaToQueryRules_Intersection(t *testing.T) {
+	schema, err := loadSchema("examples/intersection/schema.json")
+	if err != nil {
+		t.Fatalf("failed to load schema: %v", err)
+	}
+
+	rules := mustMapSchemaToQueryRules(t, schema)
+
+	expected := []BinaryRule{
+		{
+			FirstResourceType:  "document",
+			FirstRelation:      "viewer",
+			SecondResourceType: "document",
+			SecondRelation:     "allowed",
+			DerivedRelation:    "can_view",
+			Kind:               IntersectionRule,
+		},
+	}
+	if !reflect.DeepEqual(rules.BinaryRules, expected) {
+		t.Errorf("expected %v, got %v", expected, rules.BinaryRules)
+	}
+
+	if len(rules.UnaryRules) > 0 {
+		t.Errorf("expected no unary rules, got %v", rules.UnaryRules)
+	}
+
+	if sql := rules.ToSQL(); !strings.Contains(sql, "INSERT INTO binary_rules VALUES\n('document', 'viewer', 'document', 'allowed', 'can_view', 'intersection');") {
+		t.Errorf("expected the intersection rule in the generated SQL, got\n%s", sql)
+	}
+}
+
+func TestExpandPermissionExpressionRefV2_NestedIntersection(t *testing.T) {
+	unary := func(relation string) *authorizerpb.PermissionExpressionRef {
+		return &authorizerpb.PermissionExpressionRef{
+			Expression: &authorizerpb.PermissionExpressionRef_UnaryExpression{
+				UnaryExpression: &authorizerpb.UnaryPermissionExpression{SourceRelation: relation},
+			},
+		}
+	}
+
+	typedef := &authorizerpb.TypeDefinition{Name: "document"}
+	schema := &authorizerpb.Schema{TypeDefinitions: map[string]*authorizerpb.TypeDefinition{"document": typedef}}
+
+	// can_view = viewer & (editor + owner) & allowed
+	exp := &authorizerpb.PermissionExpressionRef{
+		Expression: &authorizerpb.PermissionExpressionRef_SetExpression{
+			SetExpression: &authorizerpb.PermissionSetExpressionRef{
+				SetExpression: &authorizerpb.PermissionSetExpressionRef_Intersection_{
+					Intersection: &authorizerpb.PermissionSetExpressionRef_Intersection{
+						Operands: []*authorizerpb.PermissionExpressionRef{
+							unary("viewer"),
+							{
+								Expression: &authorizerpb.PermissionExpressionRef_SetExpression{
+									SetExpression: &authorizerpb.PermissionSetExpressionRef{
+										SetExpression: &authorizerpb.PermissionSetExpressionRef_Union_{
+											Union: &authorizerpb.PermissionSetExpressionRef_Union{
+												Operands: []*authorizerpb.PermissionExpressionRef{unary("editor"), unary("owner")},
+											},
+										},
+									},
+								},
+							},
+							unary("allowed"),
+						},
+					},
+				},
+			},
 		},
 	}
 
+	derived, unaryRules, binaryRules, _, err := expandPermissionExpressionRefV2(schema, typedef, "can_view", exp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if derived != "can_view" {
+		t.Errorf("expected 'can_view', got '%s'", derived)
+	}
+
+	expectedUnaryRules := []UnaryRule{
+		{ResourceType: "document", SourceRelation: "editor", DerivedRelation: "__can_view_1__"},
+		{ResourceType: "document", SourceRelation: "owner", DerivedRelation: "__can_view_1__"},
+	}
 	if !reflect.DeepEqual(unaryRules, expectedUnaryRules) {
 		t.Errorf("expected %v, got %v", expectedUnaryRules, unaryRules)
 	}
 
+	expectedBinaryRules := []BinaryRule{
+		{
+			FirstResourceType:  "document",
+			FirstRelation:      "viewer",
+			SecondResourceType: "document",
+			SecondRelation:     "__can_view_1__",
+			DerivedRelation:    "__can_view_2__",
+			Kind:               IntersectionRule,
+		},
+		{
+			FirstResourceType:  "document",
+			FirstRelation:      "__can_view_2__",
+			SecondResourceType: "document",
+			SecondRelation:     "allowed",
+			DerivedRelation:    "can_view",
+			Kind:               IntersectionRule,
+		},
+	}
 	if !reflect.DeepEqual(binaryRules, expectedBinaryRules) {
 		t.Errorf("expected %v, got %v", expectedBinaryRules, binaryRules)
 	}
 }
+
+func TestMapSchemaToQueryRules_Exclusion(t *testing.T) {
+	schema, err := loadSchema("examples/exclusion/schema.json")
+	if err != nil {
+		t.Fatalf("failed to load schema: %v", err)
+	}
+
+	rules := mustMapSchemaToQueryRules(t, schema)
+
+	expected := []BinaryRule{
+		{
+			FirstResourceType:  "document",
+			FirstRelation:      "viewer",
+			SecondResourceType: "document",
+			SecondRelation:     "banned",
+			DerivedRelation:    "can_view",
+			Stratum:            1,
+		},
+	}
+	if !reflect.DeepEqual(rules.NegatedBinaryRules, expected) {
+		t.Errorf("expected %v, got %v", expected, rules.NegatedBinaryRules)
+	}
+
+	if len(rules.UnaryRules) > 0 || len(rules.BinaryRules) > 0 {
+		t.Errorf("expected only a negated rule, got %v and %v", rules.UnaryRules, rules.BinaryRules)
+	}
+
+	if sql := rules.ToSQL(); !strings.Contains(sql, "INSERT INTO negated_binary_rules VALUES\n('document', 'viewer', 'document', 'banned', 'can_view', 1);") {
+		t.Errorf("expected the negated rule in the generated SQL, got\n%s", sql)
+	}
+}
+
+func TestExpandPermissionExpressionRefV2_CompoundExclusion(t *testing.T) {
+	unary := func(relation string) *authorizerpb.PermissionExpressionRef {
+		return &authorizerpb.PermissionExpressionRef{
+			Expression: &authorizerpb.PermissionExpressionRef_UnaryExpression{
+				UnaryExpression: &authorizerpb.UnaryPermissionExpression{SourceRelation: relation},
+			},
+		}
+	}
+
+	set := func(exp *authorizerpb.PermissionSetExpressionRef) *authorizerpb.PermissionExpressionRef {
+		return &authorizerpb.PermissionExpressionRef{
+			Expression: &authorizerpb.PermissionExpressionRef_SetExpression{SetExpression: exp},
+		}
+	}
+
+	exclusion := func(operands ...*authorizerpb.PermissionExpressionRef) *authorizerpb.PermissionExpressionRef {
+		return set(&authorizerpb.PermissionSetExpressionRef{
+			SetExpression: &authorizerpb.PermissionSetExpressionRef_Exclusion_{
+				Exclusion: &authorizerpb.PermissionSetExpressionRef_Exclusion{Operands: operands},
+			},
+		})
+	}
+
+	union := func(operands ...*authorizerpb.PermissionExpressionRef) *authorizerpb.PermissionExpressionRef {
+		return set(&authorizerpb.PermissionSetExpressionRef{
+			SetExpression: &authorizerpb.PermissionSetExpressionRef_Union_{
+				Union: &authorizerpb.PermissionSetExpressionRef_Union{Operands: operands},
+			},
+		})
+	}
+
+	arrow := &authorizerpb.PermissionExpressionRef{
+		Expression: &authorizerpb.PermissionExpressionRef_HierarchicalExpression{
+			HierarchicalExpression: &authorizerpb.HierarchicalPermissionExpression{Base: "parent", Target: "viewer"},
+		},
+	}
+
+	folder := &authorizerpb.TypeDefinition{
+		Name: "folder",
+		Relations: map[string]*authorizerpb.Relation{
+			"viewer": {Name: "viewer", TypeRestrictions: []*authorizerpb.RelationTypeRestriction{{ResourceType: "user"}}},
+		},
+	}
+
+	typedef := &authorizerpb.TypeDefinition{
+		Name: "document",
+		Relations: map[string]*authorizerpb.Relation{
+			"parent": {Name: "parent", TypeRestrictions: []*authorizerpb.RelationTypeRestriction{{ResourceType: "folder"}}},
+		},
+	}
+
+	schema := &authorizerpb.Schema{TypeDefinitions: map[string]*authorizerpb.TypeDefinition{"folder": folder, "document": typedef}}
+
+	negated := func(first, second, derived string) BinaryRule {
+		return BinaryRule{FirstResourceType: "document", FirstRelation: first, SecondResourceType: "document", SecondRelation: second, DerivedRelation: derived}
+	}
+
+	tests := []struct {
+		name    string
+		exp     *authorizerpb.PermissionExpressionRef
+		unary   []UnaryRule
+		binary  []BinaryRule
+		negated []BinaryRule
+	}{
+		{
+			name: "(viewer + editor) - banned",
+			exp:  exclusion(union(unary("viewer"), unary("editor")), unary("banned")),
+			unary: []UnaryRule{
+				{ResourceType: "document", SourceRelation: "viewer", DerivedRelation: "__can_view_1__"},
+				{ResourceType: "document", SourceRelation: "editor", DerivedRelation: "__can_view_1__"},
+			},
+			negated: []BinaryRule{negated("__can_view_1__", "banned", "can_view")},
+		},
+		{
+			name: "parent->viewer - banned",
+			exp:  exclusion(arrow, unary("banned")),
+			binary: []BinaryRule{
+				{FirstResourceType: "folder", FirstRelation: "viewer", SecondResourceType: "document", SecondRelation: "parent", DerivedRelation: "__can_view_1__", Kind: ArrowRule},
+			},
+			negated: []BinaryRule{negated("__can_view_1__", "banned", "can_view")},
+		},
+		{
+			name: "viewer - (banned - unbanned)",
+			exp:  exclusion(unary("viewer"), exclusion(unary("banned"), unary("unbanned"))),
+			negated: []BinaryRule{
+				negated("banned", "unbanned", "__can_view_1__"),
+				negated("viewer", "__can_view_1__", "can_view"),
+			},
+		},
+		{
+			name: "editor + (viewer - banned)",
+			exp:  union(unary("editor"), exclusion(unary("viewer"), unary("banned"))),
+			unary: []UnaryRule{
+				{ResourceType: "document", SourceRelation: "editor", DerivedRelation: "can_view"},
+			},
+			negated: []BinaryRule{negated("viewer", "banned", "can_view")},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, unaryRules, binaryRules, negatedRules, err := expandPermissionExpressionRefV2(schema, typedef, "can_view", test.exp)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(unaryRules, test.unary) {
+				t.Errorf("expected unary rules %v, got %v", test.unary, unaryRules)
+			}
+
+			if !reflect.DeepEqual(binaryRules, test.binary) {
+				t.Errorf("expected binary rules %v, got %v", test.binary, binaryRules)
+			}
+
+			if !reflect.DeepEqual(negatedRules, test.negated) {
+				t.Errorf("expected negated rules %v, got %v", test.negated, negatedRules)
+			}
+		})
+	}
+
+	// an exclusion of a single operand is an error rather than a panic
+	if _, _, _, _, err := expandPermissionExpressionRefV2(schema, typedef, "can_view", exclusion(unary("viewer"))); err == nil {
+		t.Errorf("expected an error for an exclusion of a single operand")
+	}
+}
+
+func TestMapSchemaToQueryRules_CyclicNegation(t *testing.T) {
+	schema := &authorizerpb.Schema{
+		TypeDefinitions: map[string]*authorizerpb.TypeDefinition{
+			"document": {
+				Name: "document",
+				Relations: map[string]*authorizerpb.Relation{
+					"viewer": {Name: "viewer", TypeRestrictions: []*authorizerpb.RelationTypeRestriction{{ResourceType: "user"}}},
+					"banned": {Name: "banned", TypeRestrictions: []*authorizerpb.RelationTypeRestriction{{ResourceType: "document", Relation: "can_view"}}},
+				},
+				Permissions: map[string]*authorizerpb.Permission{
+					"can_view": {Name: "can_view", Expression: exclusionExpression("viewer", "banned")},
+				},
+			},
+		},
+	}
+
+	if _, err := mapSchemaToQueryRules(schema); err == nil || !strings.Contains(err.Error(), "not stratifiable") {
+		t.Errorf("expected an error for negation which depends on itself, got %v", err)
+	}
+}
+
+// exclusionExpression returns the expression of the exclusion 'first - second'.
+func exclusionExpression(first, second string) *authorizerpb.PermissionExpressionRef {
+	var operands []*authorizerpb.PermissionExpressionRef
+	for _, relation := range []string{first, second} {
+		operands = append(operands, &authorizerpb.PermissionExpressionRef{
+			Expression: &authorizerpb.PermissionExpressionRef_UnaryExpression{
+				UnaryExpression: &authorizerpb.UnaryPermissionExpression{SourceRelation: relation},
+			},
+		})
+	}
+
+	return &authorizerpb.PermissionExpressionRef{
+		Expression: &authorizerpb.PermissionExpressionRef_SetExpression{
+			SetExpression: &authorizerpb.PermissionSetExpressionRef{
+				SetExpression: &authorizerpb.PermissionSetExpressionRef_Exclusion_{
+					Exclusion: &authorizerpb.PermissionSetExpressionRef_Exclusion{Operands: operands},
+				},
+			},
+		},
+	}
+}
+
+// mustMapSchemaToQueryRules maps the schema to rules, failing the test if it
+// can't be.
+func mustMapSchemaToQueryRules(t testing.TB, schema *authorizerpb.Schema) SchemaQueryRules {
+	t.Helper()
+
+	rules, err := mapSchemaToQueryRules(schema)
+	if err != nil {
+		t.Fatalf("failed to map schema to rules: %v", err)
+	}
+
+	return rules
+}
+
+func TestMapSchemaToQueryRules_WildcardExclusion(t *testing.T) {
+	// 'document:1#viewer@user:*' and 'document:1#banned@user:bob' would derive
+	// 'document:1#can_view@user:*', which grants bob the permission
+	schema := &authorizerpb.Schema{
+		TypeDefinitions: map[string]*authorizerpb.TypeDefinition{
+			"user": {Name: "user"},
+			"document": {
+				Name: "document",
+				Relations: map[string]*authorizerpb.Relation{
+					"viewer": {Name: "viewer", TypeRestrictions: []*authorizerpb.RelationTypeRestriction{{ResourceType: "user"}, {ResourceType: "user", Wildcard: true}}},
+					"banned": {Name: "banned", TypeRestrictions: []*authorizerpb.RelationTypeRestriction{{ResourceType: "user"}}},
+				},
+				Permissions: map[string]*authorizerpb.Permission{
+					"can_view": {Name: "can_view", Expression: exclusionExpression("viewer", "banned")},
+				},
+			},
+		},
+	}
+
+	if _, err := mapSchemaToQueryRules(schema); !errors.Is(err, errWildcardExclusion) {
+		t.Errorf("expected the exclusion of wildcard subjects to be rejected, got %v", err)
+	}
+}
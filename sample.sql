@@ -2,7 +2,7 @@ INSERT INTO relationships VALUES
     ('account', 't2_1', '', 'subreddit', 't5_1', 'moderator');
 
 INSERT INTO type_restrictions VALUES
-    ('subreddit', 'moderator', 'account', '');
+    ('subreddit', 'moderator', 'account', '', false, '', false);
 
 
 INSERT INTO relationships VALUES
@@ -0,0 +1,513 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"maps"
+	"net"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	authorizerpb "github.com/jon-whit/feldera-rebac/protos/gen/go/authorizer/v1alpha1"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+type authorizerServer struct {
+	authorizerpb.UnimplementedAuthorizerServiceServer
+
+	store PermissionStore
+
+	// schema is the schema the relationships are derived under, which Expand
+	// walks the permissions of.
+	schema *authorizerpb.Schema
+
+	// rules are the rules of the schema, which writes are validated against
+	// and derivations are explained by.
+	rules SchemaQueryRules
+
+	// caveats are the compiled caveat definitions of the schema, by name.
+	caveats map[string]compiledCaveat
+
+	// feldera is the client of the pipeline Watch streams the changes of, or
+	// nil in dev mode.
+	feldera *felderaClient
+
+	// postgres is the database of the relationships table the pipeline reads
+	// when it starts, which writes are persisted to, or nil in dev mode.
+	postgres postgresPool
+
+	// revisions assigns the revisions of writes, and freshnessTimeout is how
+	// long a read waits for the revision it must be at least as fresh as.
+	revisions        revisionClock
+	freshnessTimeout time.Duration
+
+	// rejected are the relationships no type restriction allows in dev mode,
+	// which otherwise are read from the pipeline.
+	rejected []RejectedRelationship
+}
+
+// subjectIDs returns the subject ids whose relationships apply to the subject,
+// since a relationship granted to 'subject_type:*' applies to every subject of
+// that type. The wildcard doesn't apply to usersets.
+func subjectIDs(subjectID, subjectRelation string) []string {
+	if subjectID == wildcardSubjectID || subjectRelation != "" {
+		return []string{subjectID}
+	}
+
+	return []string{subjectID, wildcardSubjectID}
+}
+
+func (s *authorizerServer) Check(ctx context.Context, req *authorizerpb.CheckRequest) (*authorizerpb.CheckResponse, error) {
+	if req.GetResourceType() == "" || req.GetRelation() == "" || req.GetSubjectType() == "" || req.GetSubjectId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "resource_type, relation, subject_type and subject_id are required")
+	}
+
+	if err := s.waitForRevision(ctx, req.GetAtLeastAsFresh()); err != nil {
+		return nil, err
+	}
+
+	subjects := subjectIDs(req.GetSubjectId(), req.GetSubjectRelation())
+
+	depthLimitReached, err := s.store.DepthLimitReached(ctx, req.GetSubjectType(), req.GetSubjectRelation(), subjects)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to check relationships: %v", err)
+	}
+
+	relationships, err := s.store.DerivedRelationships(ctx, req.GetResourceType(), req.GetResourceIds(), req.GetRelation(), req.GetSubjectType(), req.GetSubjectRelation(), subjects)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to check relationships: %v", err)
+	}
+
+	results := make(map[string]*authorizerpb.CheckResult, len(req.GetResourceIds()))
+	for _, resourceID := range req.GetResourceIds() {
+		if isOwnUserset(req.GetResourceType(), resourceID, req.GetRelation(), req.GetSubjectType(), req.GetSubjectId(), req.GetSubjectRelation()) {
+			results[resourceID] = &authorizerpb.CheckResult{
+				HasRelation:    true,
+				Permissionship: authorizerpb.Permissionship_PERMISSIONSHIP_HAS_PERMISSION,
+			}
+			continue
+		}
+
+		result, err := s.checkResult(relationships[resourceID], req.GetContext().AsMap())
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to check %s:%s: %v", req.GetResourceType(), resourceID, err)
+		}
+
+		result.DepthLimitReached = depthLimitReached && !result.GetHasRelation()
+		results[resourceID] = result
+
+		if !req.GetDebug() {
+			continue
+		}
+
+		for _, relationship := range relationships[resourceID] {
+			if relationship.Expired(time.Now()) {
+				continue
+			}
+
+			derivation, err := s.derivation(ctx, relationship)
+			if err != nil {
+				return nil, status.Errorf(codes.Unavailable, "failed to explain %s: %v", relationship, err)
+			}
+
+			result.Derivations = append(result.Derivations, derivationToProto(derivation))
+		}
+	}
+
+	return &authorizerpb.CheckResponse{ResultsByResourceId: results}, nil
+}
+
+// isOwnUserset returns true if the subject is the userset of the relation of
+// the resource, which has its relation to its own resource (e.g. every member
+// of 'group:eng#member' is a member of group:eng).
+func isOwnUserset(resourceType, resourceID, relation, subjectType, subjectID, subjectRelation string) bool {
+	return subjectRelation != "" && resourceType == subjectType && resourceID == subjectID && relation == subjectRelation
+}
+
+func (s *authorizerServer) CheckBulk(ctx context.Context, req *authorizerpb.CheckBulkRequest) (*authorizerpb.CheckBulkResponse, error) {
+	if err := s.waitForRevision(ctx, req.GetAtLeastAsFresh()); err != nil {
+		return nil, err
+	}
+
+	results := make([]*authorizerpb.CheckBulkResult, len(req.GetItems()))
+
+	// the valid items are checked against the store together, in one round
+	// trip, while the invalid ones fail without failing the others
+	var checks []RelationshipCheck
+	var checkItems []int
+	for i, item := range req.GetItems() {
+		results[i] = &authorizerpb.CheckBulkResult{Item: item}
+
+		if item.GetResourceType() == "" || item.GetResourceId() == "" || item.GetRelation() == "" || item.GetSubjectType() == "" || item.GetSubjectId() == "" {
+			results[i].Response = &authorizerpb.CheckBulkResult_Error{Error: "resource_type, resource_id, relation, subject_type and subject_id are required"}
+			continue
+		}
+
+		if isOwnUserset(item.GetResourceType(), item.GetResourceId(), item.GetRelation(), item.GetSubjectType(), item.GetSubjectId(), item.GetSubjectRelation()) {
+			results[i].Response = &authorizerpb.CheckBulkResult_Result{Result: &authorizerpb.CheckResult{
+				HasRelation:    true,
+				Permissionship: authorizerpb.Permissionship_PERMISSIONSHIP_HAS_PERMISSION,
+			}}
+			continue
+		}
+
+		checks = append(checks, RelationshipCheck{
+			ResourceType:    item.GetResourceType(),
+			ResourceID:      item.GetResourceId(),
+			Relation:        item.GetRelation(),
+			SubjectType:     item.GetSubjectType(),
+			SubjectRelation: item.GetSubjectRelation(),
+			SubjectIDs:      subjectIDs(item.GetSubjectId(), item.GetSubjectRelation()),
+		})
+		checkItems = append(checkItems, i)
+	}
+
+	checked, err := s.store.CheckRelationships(ctx, checks)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to check relationships: %v", err)
+	}
+
+	for j, i := range checkItems {
+		item := req.GetItems()[i]
+
+		requestContext := req.GetContext().AsMap()
+		maps.Copy(requestContext, item.GetContext().AsMap())
+
+		result, err := s.checkResult(checked[j].Relationships, requestContext)
+		if err != nil {
+			results[i].Response = &authorizerpb.CheckBulkResult_Error{Error: fmt.Sprintf("failed to check %s:%s: %v", item.GetResourceType(), item.GetResourceId(), err)}
+			continue
+		}
+
+		result.DepthLimitReached = checked[j].DepthLimitReached && !result.GetHasRelation()
+		results[i].Response = &authorizerpb.CheckBulkResult_Result{Result: result}
+	}
+
+	return &authorizerpb.CheckBulkResponse{Results: results}, nil
+}
+
+// checkResult combines the derived relationships matching a check. The
+// relation holds if any of them applies given the caveats they are
+// conditioned on.
+func (s *authorizerServer) checkResult(relationships []Relationship, requestContext map[string]any) (*authorizerpb.CheckResult, error) {
+	permissionship := authorizerpb.Permissionship_PERMISSIONSHIP_NO_PERMISSION
+	missing := map[string]struct{}{}
+
+	for _, relationship := range relationships {
+
+		// the pipeline retracts expired relationships, but the retraction may
+		// not have reached the store yet
+		if relationship.Expired(time.Now()) {
+			continue
+		}
+
+		refs, err := parseCaveatRefs(relationship.Caveats)
+		if err != nil {
+			return nil, err
+		}
+
+		result, missingFields, err := evaluateCaveats(s.caveats, refs, requestContext)
+		if err != nil {
+			return nil, err
+		}
+
+		switch result {
+		case authorizerpb.Permissionship_PERMISSIONSHIP_HAS_PERMISSION:
+			return &authorizerpb.CheckResult{
+				HasRelation:    true,
+				Permissionship: result,
+			}, nil
+		case authorizerpb.Permissionship_PERMISSIONSHIP_CONDITIONAL_PERMISSION:
+			permissionship = result
+			for _, field := range missingFields {
+				missing[field] = struct{}{}
+			}
+		}
+	}
+
+	return &authorizerpb.CheckResult{
+		Permissionship:       permissionship,
+		MissingContextFields: slices.Sorted(maps.Keys(missing)),
+	}, nil
+}
+
+func (s *authorizerServer) LookupResources(ctx context.Context, req *authorizerpb.LookupResourcesRequest) (*authorizerpb.LookupResourcesResponse, error) {
+	if req.GetResourceType() == "" || req.GetRelation() == "" || req.GetSubjectType() == "" || req.GetSubjectId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "resource_type, relation, subject_type and subject_id are required")
+	}
+
+	if err := s.waitForRevision(ctx, req.GetAtLeastAsFresh()); err != nil {
+		return nil, err
+	}
+
+	resourceIDs, err := s.store.LookupResourceIDs(ctx, req.GetResourceType(), req.GetRelation(), req.GetSubjectType(), req.GetSubjectRelation(), subjectIDs(req.GetSubjectId(), req.GetSubjectRelation()))
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to lookup resources: %v", err)
+	}
+
+	return &authorizerpb.LookupResourcesResponse{ResourceIds: resourceIDs}, nil
+}
+
+func (s *authorizerServer) LookupSubjects(ctx context.Context, req *authorizerpb.LookupSubjectsRequest) (*authorizerpb.LookupSubjectsResponse, error) {
+	if req.GetResourceType() == "" || req.GetResourceId() == "" || req.GetRelation() == "" || req.GetSubjectType() == "" {
+		return nil, status.Error(codes.InvalidArgument, "resource_type, resource_id, relation and subject_type are required")
+	}
+
+	if err := s.waitForRevision(ctx, req.GetAtLeastAsFresh()); err != nil {
+		return nil, err
+	}
+
+	subjectIDs, err := s.store.LookupSubjectIDs(ctx, req.GetResourceType(), req.GetResourceId(), req.GetRelation(), req.GetSubjectType(), req.GetSubjectRelation())
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to lookup subjects: %v", err)
+	}
+
+	return &authorizerpb.LookupSubjectsResponse{SubjectIds: subjectIDs}, nil
+}
+
+func (s *authorizerServer) Watch(req *authorizerpb.WatchRequest, stream authorizerpb.AuthorizerService_WatchServer) error {
+	if s.feldera == nil {
+		return status.Error(codes.Unimplemented, "watch requires a Feldera pipeline, which dev mode doesn't use")
+	}
+
+	var subjects []string
+	if req.GetSubjectId() != "" {
+		subjects = subjectIDs(req.GetSubjectId(), req.GetSubjectRelation())
+	}
+
+	err := s.feldera.watchDerivedRelationships(stream.Context(), func(changes []RelationshipChange) error {
+		var updates []*authorizerpb.RelationshipUpdate
+		for _, change := range changes {
+			r := change.Relationship
+			if (req.GetResourceType() != "" && r.ResourceType != req.GetResourceType()) ||
+				(req.GetRelation() != "" && r.Relation != req.GetRelation()) ||
+				(req.GetSubjectType() != "" && r.SubjectType != req.GetSubjectType()) ||
+				(req.GetSubjectRelation() != "" && r.SubjectRelation != req.GetSubjectRelation()) ||
+				(subjects != nil && !slices.Contains(subjects, r.SubjectID)) {
+				continue
+			}
+
+			operation := authorizerpb.RelationshipUpdate_OPERATION_GRANTED
+			if change.Revoked {
+				operation = authorizerpb.RelationshipUpdate_OPERATION_REVOKED
+			}
+
+			updates = append(updates, &authorizerpb.RelationshipUpdate{
+				Operation:    operation,
+				Relationship: relationshipToProto(r),
+			})
+		}
+
+		if len(updates) == 0 {
+			return nil
+		}
+
+		return stream.Send(&authorizerpb.WatchResponse{Updates: updates})
+	})
+	if err != nil && stream.Context().Err() == nil {
+		return status.Errorf(codes.Unavailable, "failed to watch derived relationships: %v", err)
+	}
+
+	return stream.Context().Err()
+}
+
+func (s *authorizerServer) WriteRelationships(ctx context.Context, req *authorizerpb.WriteRelationshipsRequest) (*authorizerpb.WriteRelationshipsResponse, error) {
+	if s.feldera == nil {
+		return nil, status.Error(codes.Unimplemented, "writes require a Feldera pipeline, which dev mode doesn't use")
+	}
+
+	writes, err := relationshipsFromProto(req.GetWrites())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid write: %v", err)
+	}
+
+	deletes, err := relationshipsFromProto(req.GetDeletes())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid delete: %v", err)
+	}
+
+	for _, r := range writes {
+		if err := validateRelationship(s.rules, r); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid write: %v", err)
+		}
+	}
+
+	// the writes are committed to Postgres once they're pushed to the
+	// pipeline, so that a failed push changes neither
+	tx, err := s.postgres.Begin(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := writePostgresRelationships(ctx, tx, writes, deletes); err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to persist relationships: %v", err)
+	}
+
+	revision := s.revisions.next()
+	if err := s.feldera.writeRelationships(ctx, writes, deletes, revision); err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to write relationships: %v", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to commit relationships: %v", err)
+	}
+
+	return &authorizerpb.WriteRelationshipsResponse{Revision: encodeRevision(revision)}, nil
+}
+
+// relationshipsFromProto returns the relationships of the rows of a
+// WriteRelationshipsRequest.
+func relationshipsFromProto(rows []*authorizerpb.Relationship) ([]Relationship, error) {
+	relationships := make([]Relationship, 0, len(rows))
+	for _, row := range rows {
+		if row.GetResourceType() == "" || row.GetResourceId() == "" || row.GetRelation() == "" || row.GetSubjectType() == "" || row.GetSubjectId() == "" {
+			return nil, fmt.Errorf("resource_type, resource_id, relation, subject_type and subject_id are required")
+		}
+
+		r := Relationship{
+			SubjectType:     row.GetSubjectType(),
+			SubjectID:       row.GetSubjectId(),
+			SubjectRelation: row.GetSubjectRelation(),
+			ResourceType:    row.GetResourceType(),
+			ResourceID:      row.GetResourceId(),
+			Relation:        row.GetRelation(),
+			CaveatName:      row.GetCaveatName(),
+			CaveatContext:   row.GetCaveatContext(),
+		}
+
+		if !validCaveatContext(r.CaveatContext) {
+			return nil, fmt.Errorf("caveat_context of '%s' is not a JSON object", r)
+		}
+
+		if row.GetExpiresAt() != nil {
+			r.ExpiresAt = &Timestamp{row.GetExpiresAt().AsTime()}
+		}
+
+		relationships = append(relationships, r)
+	}
+
+	return relationships, nil
+}
+
+func runServe(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := flags.String("addr", ":9090", "The address the gRPC server listens on")
+	redisAddr := flags.String("redis-addr", "localhost:6379", "The address of the Redis server the derived relationships are written to")
+	schemaPath := flags.String("schema-path", "schema.json", "Path to the (.json) schema file")
+	dev := flags.Bool("dev", false, "Serve checks from the relationships evaluated in memory instead of from Redis")
+	postgres := flags.Bool("postgres", false, "Serve checks from the derived relationships the pipeline writes to Postgres (see 'program -postgres-output') instead of from Redis")
+	egress := flags.Bool("egress", false, "Serve checks from the derived relationships followed over the pipeline's HTTP egress API and held in memory, instead of from Redis")
+	relationshipsPath := flags.String("relationships", "", "Path to a (.csv or .ndjson) relationships file to evaluate in dev mode. The relationships table is read from Postgres if empty")
+	format := flags.String("format", "", "The format of the relationships file (csv or ndjson), by default inferred from its extension")
+	postgresURI := flags.String("postgres-uri", defaultPostgresURI, "The URI of the Postgres database with the relationships table, which writes are persisted to, and the derived_relationships table with -postgres")
+	felderaURL := flags.String("feldera-url", defaultFelderaURL, "The URL of the Feldera pipeline manager whose changes Watch streams")
+	pipeline := flags.String("pipeline", "rebac", "The name of the Feldera pipeline")
+	maxDepth := flags.Int("max-depth", 0, "The maximum depth the program was generated with (see 'program -max-depth'), which -egress compares the depth of the derived relationships to")
+	freshnessTimeout := flags.Duration("freshness-timeout", 5*time.Second, "How long a read waits for the revision of at_least_as_fresh to be reflected in the store before failing. Zero fails fast")
+	flags.Parse(args)
+
+	schema, err := loadSchema(*schemaPath)
+	if err != nil {
+		log.Fatalf("failed to load schema: %v", err)
+	}
+
+	rules, err := mapSchemaToQueryRules(schema)
+	if err != nil {
+		log.Fatalf("failed to map schema to rules: %v", err)
+	}
+
+	compiledCaveats, err := compileCaveats(schema.GetCaveats())
+	if err != nil {
+		log.Fatalf("failed to compile caveats: %v", err)
+	}
+
+	// the health service reports the authorizer as serving once its store is
+	// ready to answer checks
+	healthServer := health.NewServer()
+
+	// writes are persisted to the relationships table, and with -postgres the
+	// derived relationships are read from the same database
+	pool, err := pgxpool.New(context.Background(), *postgresURI)
+	if err != nil {
+		log.Fatalf("failed to connect to postgres: %v", err)
+	}
+
+	var store PermissionStore = &redisStore{client: redis.NewClient(&redis.Options{Addr: *redisAddr})}
+	feldera := &felderaClient{httpClient: http.DefaultClient, baseURL: *felderaURL, pipeline: *pipeline}
+	var rejected []RejectedRelationship
+	switch {
+	case *dev && *egress, *dev && *postgres, *egress && *postgres:
+		log.Fatalf("-dev, -egress and -postgres are mutually exclusive")
+	case *postgres:
+		store = &postgresStore{pool: pool}
+	case *egress:
+		healthServer.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+
+		egressStore := newEgressStore(feldera, *maxDepth, func(ready bool) {
+			servingStatus := healthpb.HealthCheckResponse_NOT_SERVING
+			if ready {
+				servingStatus = healthpb.HealthCheckResponse_SERVING
+			}
+
+			healthServer.SetServingStatus("", servingStatus)
+		})
+		go egressStore.run(context.Background())
+
+		store = egressStore
+	case *dev:
+		feldera = nil
+
+		var relationships []Relationship
+		if *relationshipsPath != "" {
+			relationships, err = readRelationshipsFile(*relationshipsPath, *format)
+		} else {
+			relationships, err = loadRelationshipsFromPostgres(context.Background(), *postgresURI)
+		}
+		if err != nil {
+			log.Fatalf("failed to load relationships: %v", err)
+		}
+
+		derivations, err := EvaluateDerivations(rules, relationships, time.Now())
+		if err != nil {
+			log.Fatalf("failed to evaluate relationships: %v", err)
+		}
+
+		rejected = rejectRelationships(rules, relationships)
+
+		log.Printf("evaluated %d derived relationships from %d relationships", len(derivations), len(relationships))
+		store = newDerivationMemoryStore(derivations)
+	}
+
+	lis, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatalf("failed to listen on '%s': %v", *addr, err)
+	}
+
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, healthServer)
+	authorizerpb.RegisterAuthorizerServiceServer(server, &authorizerServer{
+		store:            store,
+		schema:           schema,
+		rules:            rules,
+		caveats:          compiledCaveats,
+		feldera:          feldera,
+		postgres:         pool,
+		freshnessTimeout: *freshnessTimeout,
+		rejected:         rejected,
+	})
+
+	log.Printf("authorizer listening on %s", lis.Addr())
+	if err := server.Serve(lis); err != nil {
+		log.Fatalf("failed to serve: %v", err)
+	}
+}
@@ -0,0 +1,507 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	authorizerpb "github.com/jon-whit/feldera-rebac/protos/gen/go/authorizer/v1alpha1"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// newTestServer returns an authorizerServer backed by an in-memory Redis
+// holding the keys the redis_output connectors of program.sql would write for
+// the given derived relationships.
+func newTestServer(t *testing.T, derived []Relationship) *authorizerServer {
+	t.Helper()
+
+	// the relationships sharing a key are aggregated into the alternatives of
+	// a single value, as redis_derived_relationships does
+	var keys []string
+	values := map[string]*redisDerivedRelationship{}
+	for _, r := range derived {
+		key := resourceKey(r.ResourceType, r.ResourceID, r.Relation, r.SubjectType, r.SubjectRelation, r.SubjectID)
+		if _, ok := values[key]; !ok {
+			keys = append(keys, key)
+			values[key] = &redisDerivedRelationship{Relationship: Relationship{
+				SubjectType: r.SubjectType, SubjectID: r.SubjectID, SubjectRelation: r.SubjectRelation,
+				ResourceType: r.ResourceType, ResourceID: r.ResourceID, Relation: r.Relation,
+			}}
+		}
+
+		alternative := redisDerivedAlternative{Caveats: []json.RawMessage{}, ExpiresAt: r.ExpiresAt}
+		for _, ref := range r.Caveats {
+			alternative.Caveats = append(alternative.Caveats, json.RawMessage(ref))
+		}
+
+		encoded, err := json.Marshal(alternative)
+		if err != nil {
+			t.Fatalf("failed to encode derived relationship: %v", err)
+		}
+
+		values[key].Alternatives = append(values[key].Alternatives, string(encoded))
+	}
+
+	mr := miniredis.RunT(t)
+	for _, key := range keys {
+		r := values[key]
+		value, err := json.Marshal(r)
+		if err != nil {
+			t.Fatalf("failed to encode derived relationship: %v", err)
+		}
+
+		mr.Set(key, string(value))
+		mr.Set(subjectKey(r.SubjectType, r.SubjectID, r.SubjectRelation, r.Relation, r.ResourceType, r.ResourceID), string(value))
+	}
+
+	return &authorizerServer{
+		store: &redisStore{client: redis.NewClient(&redis.Options{Addr: mr.Addr()})},
+	}
+}
+
+func TestCheck_Wildcard(t *testing.T) {
+	server := newTestServer(t, []Relationship{
+		{SubjectType: "user", SubjectID: "*", ResourceType: "document", ResourceID: "public", Relation: "can_view"},
+		{SubjectType: "user", SubjectID: "jon", ResourceType: "document", ResourceID: "private", Relation: "can_view"},
+	})
+
+	resp, err := server.Check(context.Background(), &authorizerpb.CheckRequest{
+		ResourceType: "document",
+		ResourceIds:  []string{"public", "private", "other"},
+		Relation:     "can_view",
+		SubjectType:  "user",
+		SubjectId:    "bob",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]bool{"public": true, "private": false, "other": false}
+	for resourceID, hasRelation := range expected {
+		if got := resp.GetResultsByResourceId()[resourceID].GetHasRelation(); got != hasRelation {
+			t.Errorf("expected has_relation=%t for document:%s, got %t", hasRelation, resourceID, got)
+		}
+	}
+}
+
+func TestCheck_Caveats(t *testing.T) {
+	server := newTestServer(t, []Relationship{
+		{
+			SubjectType:  "user",
+			SubjectID:    "jon",
+			ResourceType: "document",
+			ResourceID:   "1",
+			Relation:     "can_view",
+			Caveats:      []string{`{"name": "ip_allowlist", "context": {"cidr": "10.0.0.0/8"}}`},
+		},
+		{
+			SubjectType:  "user",
+			SubjectID:    "*",
+			ResourceType: "document",
+			ResourceID:   "2",
+			Relation:     "can_view",
+			Caveats:      []string{`{"name": "ip_allowlist", "context": {"cidr": "192.168.0.0/16"}}`},
+		},
+		{
+			SubjectType:  "user",
+			SubjectID:    "jon",
+			ResourceType: "document",
+			ResourceID:   "2",
+			Relation:     "can_view",
+			Caveats:      []string{`{"name": "ip_allowlist", "context": {"cidr": "10.0.0.0/8"}}`},
+		},
+	})
+
+	var err error
+	server.caveats, err = compileCaveats(map[string]*authorizerpb.CaveatDefinition{
+		"ip_allowlist": {
+			Name:       "ip_allowlist",
+			Parameters: map[string]string{"cidr": "string", "user_ip": "ipaddress"},
+			Expression: "user_ip.in_cidr(cidr)",
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to compile caveats: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		context  map[string]any
+		expected map[string]authorizerpb.Permissionship
+	}{
+		{
+			name: "missing context",
+			expected: map[string]authorizerpb.Permissionship{
+				"1": authorizerpb.Permissionship_PERMISSIONSHIP_CONDITIONAL_PERMISSION,
+				"2": authorizerpb.Permissionship_PERMISSIONSHIP_CONDITIONAL_PERMISSION,
+			},
+		},
+		{
+			name:    "direct relationship",
+			context: map[string]any{"user_ip": "10.0.0.1"},
+			expected: map[string]authorizerpb.Permissionship{
+				"1": authorizerpb.Permissionship_PERMISSIONSHIP_HAS_PERMISSION,
+				"2": authorizerpb.Permissionship_PERMISSIONSHIP_HAS_PERMISSION,
+			},
+		},
+		{
+			name:    "wildcard relationship",
+			context: map[string]any{"user_ip": "192.168.0.1"},
+			expected: map[string]authorizerpb.Permissionship{
+				"1": authorizerpb.Permissionship_PERMISSIONSHIP_NO_PERMISSION,
+				"2": authorizerpb.Permissionship_PERMISSIONSHIP_HAS_PERMISSION,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			requestContext, err := structpb.NewStruct(test.context)
+			if err != nil {
+				t.Fatalf("failed to build context: %v", err)
+			}
+
+			resp, err := server.Check(context.Background(), &authorizerpb.CheckRequest{
+				ResourceType: "document",
+				ResourceIds:  []string{"1", "2"},
+				Relation:     "can_view",
+				SubjectType:  "user",
+				SubjectId:    "jon",
+				Context:      requestContext,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			for resourceID, permissionship := range test.expected {
+				result := resp.GetResultsByResourceId()[resourceID]
+				if result.GetPermissionship() != permissionship {
+					t.Errorf("expected %v for document:%s, got %v", permissionship, resourceID, result.GetPermissionship())
+				}
+
+				if result.GetHasRelation() != (permissionship == authorizerpb.Permissionship_PERMISSIONSHIP_HAS_PERMISSION) {
+					t.Errorf("unexpected has_relation=%t for document:%s", result.GetHasRelation(), resourceID)
+				}
+			}
+		})
+	}
+}
+
+func TestCheck_Expiration(t *testing.T) {
+	server := newTestServer(t, []Relationship{
+		{
+			SubjectType:  "user",
+			SubjectID:    "jon",
+			ResourceType: "document",
+			ResourceID:   "expired",
+			Relation:     "can_view",
+			ExpiresAt:    &Timestamp{time.Now().Add(-time.Minute)},
+		},
+		{
+			SubjectType:  "user",
+			SubjectID:    "jon",
+			ResourceType: "document",
+			ResourceID:   "unexpired",
+			Relation:     "can_view",
+			ExpiresAt:    &Timestamp{time.Now().Add(time.Hour)},
+		},
+		// derived twice, so both share the keys of the relationship
+		{
+			SubjectType:  "user",
+			SubjectID:    "jon",
+			ResourceType: "document",
+			ResourceID:   "renewed",
+			Relation:     "can_view",
+			ExpiresAt:    &Timestamp{time.Now().Add(-time.Minute)},
+		},
+		{
+			SubjectType:  "user",
+			SubjectID:    "jon",
+			ResourceType: "document",
+			ResourceID:   "renewed",
+			Relation:     "can_view",
+		},
+	})
+
+	resp, err := server.Check(context.Background(), &authorizerpb.CheckRequest{
+		ResourceType: "document",
+		ResourceIds:  []string{"expired", "unexpired", "renewed"},
+		Relation:     "can_view",
+		SubjectType:  "user",
+		SubjectId:    "jon",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]bool{"expired": false, "unexpired": true, "renewed": true}
+	for resourceID, hasRelation := range expected {
+		if got := resp.GetResultsByResourceId()[resourceID].GetHasRelation(); got != hasRelation {
+			t.Errorf("expected has_relation=%t for document:%s, got %t", hasRelation, resourceID, got)
+		}
+	}
+}
+
+func TestCheck_DepthLimitReached(t *testing.T) {
+	server := newTestServer(t, []Relationship{
+		{SubjectType: "user", SubjectID: "jon", ResourceType: "document", ResourceID: "1", Relation: "can_view"},
+	})
+
+	if err := server.store.(*redisStore).client.Set(context.Background(), depthLimitKey("user", "jon", ""), "{}", 0).Err(); err != nil {
+		t.Fatalf("failed to write the depth limit marker: %v", err)
+	}
+
+	resp, err := server.Check(context.Background(), &authorizerpb.CheckRequest{
+		ResourceType: "document",
+		ResourceIds:  []string{"1", "2"},
+		Relation:     "can_view",
+		SubjectType:  "user",
+		SubjectId:    "jon",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result := resp.GetResultsByResourceId()["1"]; !result.GetHasRelation() || result.GetDepthLimitReached() {
+		t.Errorf("expected document:1 to be allowed without reaching the depth limit, got %v", result)
+	}
+
+	if result := resp.GetResultsByResourceId()["2"]; result.GetHasRelation() || !result.GetDepthLimitReached() {
+		t.Errorf("expected document:2 to be denied with the depth limit reached, got %v", result)
+	}
+}
+
+func TestLookup_Wildcard(t *testing.T) {
+	server := newTestServer(t, []Relationship{
+		{SubjectType: "user", SubjectID: "*", ResourceType: "document", ResourceID: "public", Relation: "can_view"},
+		{SubjectType: "user", SubjectID: "jon", ResourceType: "document", ResourceID: "private", Relation: "can_view"},
+		{SubjectType: "user", SubjectID: "jon", ResourceType: "document", ResourceID: "public", Relation: "can_view"},
+	})
+
+	resources, err := server.LookupResources(context.Background(), &authorizerpb.LookupResourcesRequest{
+		ResourceType: "document",
+		Relation:     "can_view",
+		SubjectType:  "user",
+		SubjectId:    "jon",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resourceIDs := resources.GetResourceIds()
+	sort.Strings(resourceIDs)
+	if expected := []string{"private", "public"}; !reflect.DeepEqual(resourceIDs, expected) {
+		t.Errorf("expected %v, got %v", expected, resourceIDs)
+	}
+
+	subjects, err := server.LookupSubjects(context.Background(), &authorizerpb.LookupSubjectsRequest{
+		ResourceType: "document",
+		ResourceId:   "public",
+		Relation:     "can_view",
+		SubjectType:  "user",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	subjectIDs := subjects.GetSubjectIds()
+	sort.Strings(subjectIDs)
+	if expected := []string{"*", "jon"}; !reflect.DeepEqual(subjectIDs, expected) {
+		t.Errorf("expected %v, got %v", expected, subjectIDs)
+	}
+}
+
+func TestCheck_SeparatorInIDs(t *testing.T) {
+	server := newTestServer(t, []Relationship{
+		{SubjectType: "user", SubjectID: "jon", ResourceType: "document", ResourceID: "1:can_view", Relation: "viewer"},
+		{SubjectType: "user", SubjectID: "jon", ResourceType: "document", ResourceID: "3:4", Relation: "can_view"},
+		{SubjectType: "user", SubjectID: `a\:b`, ResourceType: "document", ResourceID: "2", Relation: "can_view"},
+	})
+
+	// without escaping, document:1 would share the key of the first relationship
+	resp, err := server.Check(context.Background(), &authorizerpb.CheckRequest{
+		ResourceType: "document",
+		ResourceIds:  []string{"1"},
+		Relation:     "can_view:viewer",
+		SubjectType:  "user",
+		SubjectId:    "jon",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for resourceID, result := range resp.GetResultsByResourceId() {
+		if result.GetHasRelation() {
+			t.Errorf("expected no relation on document:%s", resourceID)
+		}
+	}
+
+	resources, err := server.LookupResources(context.Background(), &authorizerpb.LookupResourcesRequest{
+		ResourceType: "document",
+		Relation:     "can_view",
+		SubjectType:  "user",
+		SubjectId:    "jon",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if expected := []string{"3:4"}; !reflect.DeepEqual(resources.GetResourceIds(), expected) {
+		t.Errorf("expected %v, got %v", expected, resources.GetResourceIds())
+	}
+
+	subjects, err := server.LookupSubjects(context.Background(), &authorizerpb.LookupSubjectsRequest{
+		ResourceType: "document",
+		ResourceId:   "2",
+		Relation:     "can_view",
+		SubjectType:  "user",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if expected := []string{`a\:b`}; !reflect.DeepEqual(subjects.GetSubjectIds(), expected) {
+		t.Errorf("expected %v, got %v", expected, subjects.GetSubjectIds())
+	}
+}
+
+func TestCheck_Userset(t *testing.T) {
+	server := newTestServer(t, []Relationship{
+		{SubjectType: "group", SubjectID: "eng", SubjectRelation: "member", ResourceType: "document", ResourceID: "1", Relation: "can_view"},
+		{SubjectType: "group", SubjectID: "eng", ResourceType: "document", ResourceID: "2", Relation: "can_view"},
+		{SubjectType: "user", SubjectID: "jon", ResourceType: "document", ResourceID: "3", Relation: "can_view"},
+		{SubjectType: "group", SubjectID: "*", ResourceType: "document", ResourceID: "3", Relation: "can_view"},
+	})
+
+	resp, err := server.Check(context.Background(), &authorizerpb.CheckRequest{
+		ResourceType:    "document",
+		ResourceIds:     []string{"1", "2", "3"},
+		Relation:        "can_view",
+		SubjectType:     "group",
+		SubjectId:       "eng",
+		SubjectRelation: "member",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// only the relationship of the userset itself applies, not those of the
+	// group or of the wildcard subject
+	expected := map[string]bool{"1": true, "2": false, "3": false}
+	for resourceID, hasRelation := range expected {
+		if got := resp.GetResultsByResourceId()[resourceID].GetHasRelation(); got != hasRelation {
+			t.Errorf("expected has_relation=%t for document:%s, got %t", hasRelation, resourceID, got)
+		}
+	}
+
+	resp, err = server.Check(context.Background(), &authorizerpb.CheckRequest{
+		ResourceType:    "group",
+		ResourceIds:     []string{"eng", "iam"},
+		Relation:        "member",
+		SubjectType:     "group",
+		SubjectId:       "eng",
+		SubjectRelation: "member",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !resp.GetResultsByResourceId()["eng"].GetHasRelation() || resp.GetResultsByResourceId()["iam"].GetHasRelation() {
+		t.Errorf("expected group:eng#member to only be a member of group:eng, got %v", resp.GetResultsByResourceId())
+	}
+}
+
+func TestLookup_Userset(t *testing.T) {
+	server := newTestServer(t, []Relationship{
+		{SubjectType: "group", SubjectID: "eng", SubjectRelation: "member", ResourceType: "document", ResourceID: "1", Relation: "can_view"},
+		{SubjectType: "group", SubjectID: "iam", SubjectRelation: "member", ResourceType: "document", ResourceID: "1", Relation: "can_view"},
+		{SubjectType: "group", SubjectID: "eng", ResourceType: "document", ResourceID: "2", Relation: "can_view"},
+	})
+
+	resources, err := server.LookupResources(context.Background(), &authorizerpb.LookupResourcesRequest{
+		ResourceType:    "document",
+		Relation:        "can_view",
+		SubjectType:     "group",
+		SubjectId:       "eng",
+		SubjectRelation: "member",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if expected := []string{"1"}; !reflect.DeepEqual(resources.GetResourceIds(), expected) {
+		t.Errorf("expected %v, got %v", expected, resources.GetResourceIds())
+	}
+
+	subjects, err := server.LookupSubjects(context.Background(), &authorizerpb.LookupSubjectsRequest{
+		ResourceType:    "document",
+		ResourceId:      "1",
+		Relation:        "can_view",
+		SubjectType:     "group",
+		SubjectRelation: "member",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	subjectIDs := subjects.GetSubjectIds()
+	sort.Strings(subjectIDs)
+	if expected := []string{"eng", "iam"}; !reflect.DeepEqual(subjectIDs, expected) {
+		t.Errorf("expected %v, got %v", expected, subjectIDs)
+	}
+}
+
+func TestCheckBulk(t *testing.T) {
+	server := newTestServer(t, []Relationship{
+		{SubjectType: "user", SubjectID: "jon", ResourceType: "document", ResourceID: "1", Relation: "can_view"},
+		{SubjectType: "user", SubjectID: "*", ResourceType: "document", ResourceID: "public", Relation: "can_view"},
+		{SubjectType: "group", SubjectID: "eng", SubjectRelation: "member", ResourceType: "folder", ResourceID: "a", Relation: "can_edit"},
+	})
+
+	if err := server.store.(*redisStore).client.Set(context.Background(), depthLimitKey("user", "bob", ""), "{}", 0).Err(); err != nil {
+		t.Fatalf("failed to write the depth limit marker: %v", err)
+	}
+
+	resp, err := server.CheckBulk(context.Background(), &authorizerpb.CheckBulkRequest{
+		Items: []*authorizerpb.CheckBulkItem{
+			{ResourceType: "document", ResourceId: "1", Relation: "can_view", SubjectType: "user", SubjectId: "jon"},
+			{ResourceType: "document", ResourceId: "1", Relation: "can_view", SubjectType: "user", SubjectId: "bob"},
+			{ResourceType: "document", ResourceId: "public", Relation: "can_view", SubjectType: "user", SubjectId: "bob"},
+			{ResourceType: "folder", ResourceId: "a", Relation: "can_edit", SubjectType: "group", SubjectId: "eng", SubjectRelation: "member"},
+			{ResourceType: "group", ResourceId: "eng", Relation: "member", SubjectType: "group", SubjectId: "eng", SubjectRelation: "member"},
+			{ResourceType: "document", Relation: "can_view", SubjectType: "user", SubjectId: "jon"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(resp.GetResults()) != 6 {
+		t.Fatalf("expected 6 results, got %d", len(resp.GetResults()))
+	}
+
+	expected := []bool{true, false, true, true, true}
+	for i, hasRelation := range expected {
+		result := resp.GetResults()[i]
+		if result.GetError() != "" {
+			t.Errorf("unexpected error for item %d: %s", i, result.GetError())
+		}
+
+		if got := result.GetResult().GetHasRelation(); got != hasRelation {
+			t.Errorf("expected has_relation=%t for item %d (%v), got %t", hasRelation, i, result.GetItem(), got)
+		}
+	}
+
+	if !resp.GetResults()[1].GetResult().GetDepthLimitReached() {
+		t.Errorf("expected the depth limit to be reached for item 1")
+	}
+
+	if resp.GetResults()[5].GetError() == "" {
+		t.Errorf("expected an error for the item without a resource id, got %v", resp.GetResults()[5])
+	}
+}
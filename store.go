@@ -0,0 +1,528 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// PermissionStore is the store of the derived relationships the authorizer
+// serves from.
+type PermissionStore interface {
+
+	// DerivedRelationships returns the derived relationships of each of the
+	// resources to any of the subjects, by resource id. The subjects are
+	// usersets if subjectRelation isn't empty.
+	DerivedRelationships(ctx context.Context, resourceType string, resourceIDs []string, relation, subjectType, subjectRelation string, subjectIDs []string) (map[string][]Relationship, error)
+
+	// CheckRelationships returns the derived relationships matching each of
+	// the checks, and whether the depth limit was reached for their subjects.
+	CheckRelationships(ctx context.Context, checks []RelationshipCheck) ([]CheckedRelationships, error)
+
+	// DepthLimitReached returns true if the derivations of any of the subjects
+	// were cut off at the maximum depth.
+	DepthLimitReached(ctx context.Context, subjectType, subjectRelation string, subjectIDs []string) (bool, error)
+
+	// LookupResourceIDs returns the ids of the resources any of the subjects
+	// have the relation to.
+	LookupResourceIDs(ctx context.Context, resourceType, relation, subjectType, subjectRelation string, subjectIDs []string) ([]string, error)
+
+	// LookupSubjectIDs returns the ids of the subjects, or of the usersets of
+	// the subject relation, with the relation to the resource.
+	LookupSubjectIDs(ctx context.Context, resourceType, resourceID, relation, subjectType, subjectRelation string) ([]string, error)
+
+	// ResourceRelationships returns the derived relationships of every
+	// subject, including usersets, with the relation to each of the
+	// resources, by resource id.
+	ResourceRelationships(ctx context.Context, resourceType string, resourceIDs []string, relation string) (map[string][]Relationship, error)
+
+	// RevisionReached returns true if the derived relationships of the writes
+	// of the revision are in the store.
+	RevisionReached(ctx context.Context, revision int64) (bool, error)
+}
+
+// RelationshipCheck is a check of a relation of a resource to any of the
+// subjects, which are usersets if SubjectRelation isn't empty.
+type RelationshipCheck struct {
+	ResourceType    string
+	ResourceID      string
+	Relation        string
+	SubjectType     string
+	SubjectRelation string
+	SubjectIDs      []string
+}
+
+// CheckedRelationships are the derived relationships matching a
+// RelationshipCheck.
+type CheckedRelationships struct {
+	Relationships     []Relationship
+	DepthLimitReached bool
+}
+
+// redisKeySeparator separates the fields of the Redis keys, which the
+// redisKey function of the program template encodes the same way.
+const redisKeySeparator = ":"
+
+// redisKeyEscaper escapes the separator, and the escape character itself, in
+// a field of a Redis key, so that fields containing the separator can't make
+// distinct tuples collide.
+var redisKeyEscaper = strings.NewReplacer(`\`, `\\`, redisKeySeparator, `\`+redisKeySeparator)
+
+// redisKey returns the key of the fields, each escaped and joined by the
+// separator.
+func redisKey(fields ...string) string {
+	escaped := make([]string, len(fields))
+	for i, field := range fields {
+		escaped[i] = redisKeyEscaper.Replace(field)
+	}
+
+	return strings.Join(escaped, redisKeySeparator)
+}
+
+// splitRedisKey returns the fields of a key returned by redisKey.
+func splitRedisKey(key string) []string {
+	var fields []string
+	var field strings.Builder
+	for i := 0; i < len(key); i++ {
+		switch {
+		case key[i] == '\\' && i+1 < len(key):
+			i++
+			field.WriteByte(key[i])
+		case strings.HasPrefix(key[i:], redisKeySeparator):
+			fields = append(fields, field.String())
+			field.Reset()
+		default:
+			field.WriteByte(key[i])
+		}
+	}
+
+	return append(fields, field.String())
+}
+
+// resourceKeyPrefix and subjectKeyPrefix are the first fields of the
+// resource-first and subject-first keys of the derived relationships, which
+// share a keyspace. Without them the resource-first key of a relationship
+// would be the subject-first key of another whose fields are in the other
+// order.
+const (
+	resourceKeyPrefix = "r"
+	subjectKeyPrefix  = "s"
+)
+
+// resourceKey returns the key of a derived relationship as written by the
+// resource-first redis_output connector of redis_derived_relationships.
+func resourceKey(resourceType, resourceID, relation, subjectType, subjectRelation, subjectID string) string {
+	return redisKey(resourceKeyPrefix, resourceType, resourceID, relation, subjectType, subjectRelation, subjectID)
+}
+
+// subjectKey returns the key of a derived relationship as written by the
+// subject-first redis_output connector of redis_derived_relationships.
+func subjectKey(subjectType, subjectID, subjectRelation, relation, resourceType, resourceID string) string {
+	return redisKey(subjectKeyPrefix, subjectType, subjectID, subjectRelation, relation, resourceType, resourceID)
+}
+
+// depthLimitKey returns the key of the marker the redis_depth_limited_subjects
+// view writes for a subject whose derivations were cut off at the maximum
+// depth.
+func depthLimitKey(subjectType, subjectID, subjectRelation string) string {
+	return redisKey("depth_limit", subjectType, subjectID, subjectRelation)
+}
+
+// revisionKeys returns the keys of the markers the resource-first and the
+// subject-first redis_output connectors of redis_derived_relationships write
+// for a revision the pipeline has reached. Each connector writes its marker
+// with the derived relationships of the step which reached the revision.
+func revisionKeys(revision int64) []string {
+	return []string{
+		redisKey("revision", strconv.FormatInt(revision, 10), "resource"),
+		redisKey("revision", strconv.FormatInt(revision, 10), "subject"),
+	}
+}
+
+// redisDerivedRelationship is the value of the keys of a derived relationship
+// written by redis_derived_relationships, which has the alternative caveats
+// and expiry of the relationship in a single value.
+type redisDerivedRelationship struct {
+	Relationship
+	Alternatives []string `json:"alternatives"`
+}
+
+// redisDerivedAlternative is an alternative of a redisDerivedRelationship.
+type redisDerivedAlternative struct {
+	Caveats   []json.RawMessage `json:"caveats"`
+	ExpiresAt *Timestamp        `json:"expires_at"`
+}
+
+// decodeDerivedRelationships decodes the value of the key of a derived
+// relationship into the relationship of each of its alternatives.
+func decodeDerivedRelationships(encoded string) ([]Relationship, error) {
+	var derived redisDerivedRelationship
+	if err := json.Unmarshal([]byte(encoded), &derived); err != nil {
+		return nil, err
+	}
+
+	relationships := make([]Relationship, 0, len(derived.Alternatives))
+	for _, encodedAlternative := range derived.Alternatives {
+		var alternative redisDerivedAlternative
+		if err := json.Unmarshal([]byte(encodedAlternative), &alternative); err != nil {
+			return nil, fmt.Errorf("invalid alternative '%s': %w", encodedAlternative, err)
+		}
+
+		r := derived.Relationship
+		r.Caveats = nil
+		for _, ref := range alternative.Caveats {
+			r.Caveats = append(r.Caveats, string(ref))
+		}
+		r.ExpiresAt = alternative.ExpiresAt
+
+		relationships = append(relationships, r)
+	}
+
+	return relationships, nil
+}
+
+// globEscaper escapes the special characters of a Redis glob-style pattern.
+var globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+// redisStore is the PermissionStore of the derived relationships the pipeline
+// writes to Redis.
+type redisStore struct {
+	client *redis.Client
+}
+
+func (s *redisStore) DerivedRelationships(ctx context.Context, resourceType string, resourceIDs []string, relation, subjectType, subjectRelation string, subjectIDs []string) (map[string][]Relationship, error) {
+	var keys []string
+	for _, resourceID := range resourceIDs {
+		for _, subjectID := range subjectIDs {
+			keys = append(keys, resourceKey(resourceType, resourceID, relation, subjectType, subjectRelation, subjectID))
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	relationships := make(map[string][]Relationship, len(resourceIDs))
+	for i, value := range values {
+		encoded, ok := value.(string)
+		if !ok {
+			continue
+		}
+
+		derived, err := decodeDerivedRelationships(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode derived relationship '%s': %w", keys[i], err)
+		}
+
+		resourceID := resourceIDs[i/len(subjectIDs)]
+		relationships[resourceID] = append(relationships[resourceID], derived...)
+	}
+
+	return relationships, nil
+}
+
+// CheckRelationships reads the derived relationships and depth limit markers
+// of all of the checks with a single pipeline.
+func (s *redisStore) CheckRelationships(ctx context.Context, checks []RelationshipCheck) ([]CheckedRelationships, error) {
+	if len(checks) == 0 {
+		return nil, nil
+	}
+
+	var keys []string
+	checkIndexes := make([]int, 0, len(checks))
+	depthLimitKeys := make([][]string, len(checks))
+	for i, check := range checks {
+		for _, subjectID := range check.SubjectIDs {
+			keys = append(keys, resourceKey(check.ResourceType, check.ResourceID, check.Relation, check.SubjectType, check.SubjectRelation, subjectID))
+			checkIndexes = append(checkIndexes, i)
+			depthLimitKeys[i] = append(depthLimitKeys[i], depthLimitKey(check.SubjectType, subjectID, check.SubjectRelation))
+		}
+	}
+
+	if len(keys) == 0 {
+		return make([]CheckedRelationships, len(checks)), nil
+	}
+
+	pipe := s.client.Pipeline()
+	values := pipe.MGet(ctx, keys...)
+	depthLimits := make([]*redis.IntCmd, len(checks))
+	for i := range checks {
+		if len(depthLimitKeys[i]) > 0 {
+			depthLimits[i] = pipe.Exists(ctx, depthLimitKeys[i]...)
+		}
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	checked := make([]CheckedRelationships, len(checks))
+	for i, value := range values.Val() {
+		encoded, ok := value.(string)
+		if !ok {
+			continue
+		}
+
+		derived, err := decodeDerivedRelationships(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode derived relationship '%s': %w", keys[i], err)
+		}
+
+		checked[checkIndexes[i]].Relationships = append(checked[checkIndexes[i]].Relationships, derived...)
+	}
+
+	for i, depthLimit := range depthLimits {
+		checked[i].DepthLimitReached = depthLimit != nil && depthLimit.Val() > 0
+	}
+
+	return checked, nil
+}
+
+func (s *redisStore) DepthLimitReached(ctx context.Context, subjectType, subjectRelation string, subjectIDs []string) (bool, error) {
+	if len(subjectIDs) == 0 {
+		return false, nil
+	}
+
+	keys := make([]string, 0, len(subjectIDs))
+	for _, subjectID := range subjectIDs {
+		keys = append(keys, depthLimitKey(subjectType, subjectID, subjectRelation))
+	}
+
+	n, err := s.client.Exists(ctx, keys...).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+func (s *redisStore) LookupResourceIDs(ctx context.Context, resourceType, relation, subjectType, subjectRelation string, subjectIDs []string) ([]string, error) {
+	seen := map[string]struct{}{}
+	var resourceIDs []string
+	for _, subjectID := range subjectIDs {
+		ids, err := s.scanSuffixes(ctx, subjectKey(subjectType, subjectID, subjectRelation, relation, resourceType, ""))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, id := range ids {
+			if _, ok := seen[id]; ok {
+				continue
+			}
+
+			seen[id] = struct{}{}
+			resourceIDs = append(resourceIDs, id)
+		}
+	}
+
+	return resourceIDs, nil
+}
+
+func (s *redisStore) LookupSubjectIDs(ctx context.Context, resourceType, resourceID, relation, subjectType, subjectRelation string) ([]string, error) {
+	return s.scanSuffixes(ctx, resourceKey(resourceType, resourceID, relation, subjectType, subjectRelation, ""))
+}
+
+// ResourceRelationships scans the keys of all of the resources at once, since
+// each SCAN walks the whole keyspace whatever its pattern. The keys of a single
+// resource are matched by its prefix, and those of several by the prefix of
+// their type.
+func (s *redisStore) ResourceRelationships(ctx context.Context, resourceType string, resourceIDs []string, relation string) (map[string][]Relationship, error) {
+	if len(resourceIDs) == 0 {
+		return nil, nil
+	}
+
+	prefix := redisKey(resourceKeyPrefix, resourceType, resourceIDs[0], relation, "")
+	if len(resourceIDs) > 1 {
+		prefix = redisKey(resourceKeyPrefix, resourceType, "")
+	}
+
+	var keys []string
+	iter := s.client.Scan(ctx, 0, globEscaper.Replace(prefix)+"*", 0).Iterator()
+	for iter.Next(ctx) {
+		// resource-first keys are r:<type>:<id>:<relation>:<subject>...
+		fields := splitRedisKey(iter.Val())
+		if len(fields) == 7 && fields[1] == resourceType && fields[3] == relation && slices.Contains(resourceIDs, fields[2]) {
+			keys = append(keys, iter.Val())
+		}
+	}
+
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	relationships := make(map[string][]Relationship, len(resourceIDs))
+	for i, value := range values {
+		encoded, ok := value.(string)
+		if !ok {
+			continue
+		}
+
+		derived, err := decodeDerivedRelationships(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode derived relationship '%s': %w", keys[i], err)
+		}
+
+		resourceID := splitRedisKey(keys[i])[2]
+		relationships[resourceID] = append(relationships[resourceID], derived...)
+	}
+
+	return relationships, nil
+}
+
+func (s *redisStore) RevisionReached(ctx context.Context, revision int64) (bool, error) {
+	keys := revisionKeys(revision)
+	n, err := s.client.Exists(ctx, keys...).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return n == int64(len(keys)), nil
+}
+
+// scanSuffixes scans all of the keys beginning with prefix, whose last field
+// is empty, and returns the last field of each key.
+func (s *redisStore) scanSuffixes(ctx context.Context, prefix string) ([]string, error) {
+	var suffixes []string
+
+	iter := s.client.Scan(ctx, 0, globEscaper.Replace(prefix)+"*", 0).Iterator()
+	for iter.Next(ctx) {
+		fields := splitRedisKey(iter.Val())
+		suffixes = append(suffixes, fields[len(fields)-1])
+	}
+
+	return suffixes, iter.Err()
+}
+
+// memoryStore is a PermissionStore of derived relationships held in memory,
+// such as those computed by Evaluate in dev mode.
+type memoryStore struct {
+
+	// relationships are the derived relationships by their resource-first key.
+	relationships map[string][]Relationship
+
+	// derivations are the derivations of the relationships, if they were
+	// evaluated with EvaluateDerivations.
+	derivations map[derivedKey]*Derivation
+}
+
+func newMemoryStore(derived []Relationship) *memoryStore {
+	s := &memoryStore{relationships: map[string][]Relationship{}}
+	for _, r := range derived {
+		key := resourceKey(r.ResourceType, r.ResourceID, r.Relation, r.SubjectType, r.SubjectRelation, r.SubjectID)
+		s.relationships[key] = append(s.relationships[key], r)
+	}
+
+	return s
+}
+
+// newDerivationMemoryStore returns a memoryStore of the derived relationships
+// which explains each of them by its derivation.
+func newDerivationMemoryStore(derivations []*Derivation) *memoryStore {
+	s := newMemoryStore(derivedRelationships(derivations))
+	s.derivations = make(map[derivedKey]*Derivation, len(derivations))
+	for _, d := range derivations {
+		s.derivations[keyOf(d.Relationship)] = d
+	}
+
+	return s
+}
+
+func (s *memoryStore) Derivation(r Relationship) *Derivation {
+	return s.derivations[keyOf(r)]
+}
+
+func (s *memoryStore) DerivedRelationships(ctx context.Context, resourceType string, resourceIDs []string, relation, subjectType, subjectRelation string, subjectIDs []string) (map[string][]Relationship, error) {
+	relationships := make(map[string][]Relationship, len(resourceIDs))
+	for _, resourceID := range resourceIDs {
+		for _, subjectID := range subjectIDs {
+			relationships[resourceID] = append(relationships[resourceID], s.relationships[resourceKey(resourceType, resourceID, relation, subjectType, subjectRelation, subjectID)]...)
+		}
+	}
+
+	return relationships, nil
+}
+
+func (s *memoryStore) CheckRelationships(ctx context.Context, checks []RelationshipCheck) ([]CheckedRelationships, error) {
+	checked := make([]CheckedRelationships, len(checks))
+	for i, check := range checks {
+		for _, subjectID := range check.SubjectIDs {
+			checked[i].Relationships = append(checked[i].Relationships, s.relationships[resourceKey(check.ResourceType, check.ResourceID, check.Relation, check.SubjectType, check.SubjectRelation, subjectID)]...)
+		}
+	}
+
+	return checked, nil
+}
+
+// DepthLimitReached always returns false, since Evaluate doesn't bound the
+// derivation depth.
+func (s *memoryStore) DepthLimitReached(ctx context.Context, subjectType, subjectRelation string, subjectIDs []string) (bool, error) {
+	return false, nil
+}
+
+func (s *memoryStore) LookupResourceIDs(ctx context.Context, resourceType, relation, subjectType, subjectRelation string, subjectIDs []string) ([]string, error) {
+	var resourceIDs []string
+	for _, relationships := range s.relationships {
+		r := relationships[0]
+		if r.ResourceType == resourceType && r.Relation == relation && r.SubjectType == subjectType &&
+			r.SubjectRelation == subjectRelation && slices.Contains(subjectIDs, r.SubjectID) && !slices.Contains(resourceIDs, r.ResourceID) {
+			resourceIDs = append(resourceIDs, r.ResourceID)
+		}
+	}
+
+	slices.Sort(resourceIDs)
+	return resourceIDs, nil
+}
+
+func (s *memoryStore) LookupSubjectIDs(ctx context.Context, resourceType, resourceID, relation, subjectType, subjectRelation string) ([]string, error) {
+	var subjectIDs []string
+	for _, relationships := range s.relationships {
+		r := relationships[0]
+		if r.ResourceType == resourceType && r.ResourceID == resourceID && r.Relation == relation &&
+			r.SubjectType == subjectType && r.SubjectRelation == subjectRelation {
+			subjectIDs = append(subjectIDs, r.SubjectID)
+		}
+	}
+
+	slices.Sort(subjectIDs)
+	return subjectIDs, nil
+}
+
+func (s *memoryStore) ResourceRelationships(ctx context.Context, resourceType string, resourceIDs []string, relation string) (map[string][]Relationship, error) {
+	relationships := make(map[string][]Relationship, len(resourceIDs))
+	for _, rs := range s.relationships {
+		if r := rs[0]; r.ResourceType == resourceType && r.Relation == relation && slices.Contains(resourceIDs, r.ResourceID) {
+			relationships[r.ResourceID] = append(relationships[r.ResourceID], rs...)
+		}
+	}
+
+	for _, rs := range relationships {
+		slices.SortFunc(rs, compareRelationships)
+	}
+
+	return relationships, nil
+}
+
+// RevisionReached always returns true, since the relationships are evaluated
+// once and aren't written to.
+func (s *memoryStore) RevisionReached(ctx context.Context, revision int64) (bool, error) {
+	return true, nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"math/rand"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"testing/quick"
+	"time"
+)
+
+// keyFields are the fields of a Redis key, drawn from a small alphabet of the
+// separator, the escape character and a glob character so that fields
+// containing them are common.
+type keyFields [6]string
+
+func (keyFields) Generate(rand *rand.Rand, size int) reflect.Value {
+	alphabet := []string{":", `\`, "*", "a", "b"}
+
+	var fields keyFields
+	for i := range fields {
+		var field strings.Builder
+		for range rand.Intn(4) {
+			field.WriteString(alphabet[rand.Intn(len(alphabet))])
+		}
+
+		fields[i] = field.String()
+	}
+
+	return reflect.ValueOf(fields)
+}
+
+func TestRedisKey_RoundTrip(t *testing.T) {
+	roundTrip := func(fields keyFields) bool {
+		return slices.Equal(splitRedisKey(redisKey(fields[:]...)), fields[:])
+	}
+
+	if err := quick.Check(roundTrip, &quick.Config{MaxCount: 10000}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRedisKey_Distinct(t *testing.T) {
+	distinct := func(a, b keyFields) bool {
+		return a == b || redisKey(a[:]...) != redisKey(b[:]...)
+	}
+
+	if err := quick.Check(distinct, &quick.Config{MaxCount: 10000}); err != nil {
+		t.Error(err)
+	}
+
+	// the tuples which collided when the fields were joined unescaped
+	a := resourceKey("document", "1:viewer", "user", "", "", "jon")
+	b := resourceKey("document", "1", "viewer:user", "", "", "jon")
+	if a == b {
+		t.Errorf("expected distinct keys, got '%s' for both", a)
+	}
+}
+
+func TestRedisKey_DistinctForms(t *testing.T) {
+	// the resource-first key of a relationship and the subject-first key of
+	// the relationship with its fields in the other order
+	distinct := func(fields keyFields) bool {
+		return resourceKey(fields[0], fields[1], fields[2], fields[3], fields[4], fields[5]) != subjectKey(fields[0], fields[1], fields[2], fields[3], fields[4], fields[5])
+	}
+
+	if err := quick.Check(distinct, &quick.Config{MaxCount: 10000}); err != nil {
+		t.Error(err)
+	}
+
+	// 'document:1#viewer@group:eng#member' and 'member:eng#group@document:1#viewer'
+	// of a schema whose relations are named as types
+	a := resourceKey("document", "1", "viewer", "group", "member", "eng")
+	b := subjectKey("document", "1", "viewer", "group", "member", "eng")
+	if a == b {
+		t.Errorf("expected distinct keys, got '%s' for both", a)
+	}
+}
+
+func TestDecodeDerivedRelationships(t *testing.T) {
+	// a value as written by redis_derived_relationships for a relationship
+	// derived without a caveat until an expiry and with a caveat forever
+	encoded := `{
+		"subject_type": "user", "subject_id": "jon", "subject_relation": "",
+		"resource_type": "document", "resource_id": "1", "relationship": "viewer",
+		"alternatives": [
+			"{\"caveats\": [], \"expires_at\": \"2025-01-01 00:00:00\"}",
+			"{\"caveats\": [{\"name\": \"ip_allowlist\", \"context\": {}}], \"expires_at\": null}"
+		],
+		"resource_key": "document:1:viewer:user::jon",
+		"subject_key": "user:jon::viewer:document:1"
+	}`
+
+	relationships, err := decodeDerivedRelationships(encoded)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(relationships) != 2 {
+		t.Fatalf("expected 2 relationships, got %d", len(relationships))
+	}
+
+	for _, r := range relationships {
+		if r.String() != "document:1#viewer@user:jon" {
+			t.Errorf("expected document:1#viewer@user:jon, got %s", r)
+		}
+	}
+
+	expiresAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	if r := relationships[0]; len(r.Caveats) != 0 || r.ExpiresAt == nil || !r.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("expected an uncaveated relationship expiring at %s, got caveats %v expiring at %v", expiresAt, r.Caveats, r.ExpiresAt)
+	}
+
+	if r := relationships[1]; !slices.Equal(r.Caveats, []string{`{"name": "ip_allowlist", "context": {}}`}) || r.ExpiresAt != nil {
+		t.Errorf("expected a caveated relationship which doesn't expire, got caveats %v expiring at %v", r.Caveats, r.ExpiresAt)
+	}
+}
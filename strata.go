@@ -0,0 +1,189 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// relationKey identifies a relation or permission of a type (e.g.
+// 'document#viewer').
+type relationKey struct {
+	resourceType, relation string
+}
+
+func (k relationKey) String() string {
+	return k.resourceType + "#" + k.relation
+}
+
+// dependency is an edge of the dependency graph of the relations, from a
+// relation to a relation derived from it. The relationships of the latter are
+// derived in a higher stratum if the dependency is negated.
+type dependency struct {
+	from, to relationKey
+	negated  bool
+}
+
+// stratify returns the negated rules with their strata. The relationships of
+// each relation are completed in a stratum, which is above the strata of the
+// relations whose relationships exclude subjects from it. A negated rule is
+// applied in the lowest stratum in which its first prerequisite is derived and
+// its second prerequisite has been completed in the previous one. It returns an
+// error if the relationships of a relation depend on excluding subjects by
+// themselves, which can't be stratified.
+func stratify(rules SchemaQueryRules) ([]BinaryRule, error) {
+	var dependencies []dependency
+	for _, restriction := range rules.RelationTypeRestrictions {
+		if restriction.SubjectRelation != "" {
+			dependencies = append(dependencies, dependency{
+				from: relationKey{restriction.SubjectType, restriction.SubjectRelation},
+				to:   relationKey{restriction.ResourceType, restriction.Relation},
+			})
+		}
+	}
+
+	for _, rule := range rules.UnaryRules {
+		dependencies = append(dependencies, dependency{
+			from: relationKey{rule.ResourceType, rule.SourceRelation},
+			to:   relationKey{rule.ResourceType, rule.DerivedRelation},
+		})
+	}
+
+	for _, rule := range rules.BinaryRules {
+		derived := relationKey{rule.SecondResourceType, rule.DerivedRelation}
+		dependencies = append(dependencies,
+			dependency{from: relationKey{rule.FirstResourceType, rule.FirstRelation}, to: derived},
+			dependency{from: relationKey{rule.SecondResourceType, rule.SecondRelation}, to: derived},
+		)
+	}
+
+	for _, rule := range rules.NegatedBinaryRules {
+		derived := relationKey{rule.FirstResourceType, rule.DerivedRelation}
+		dependencies = append(dependencies,
+			dependency{from: relationKey{rule.FirstResourceType, rule.FirstRelation}, to: derived},
+			dependency{from: relationKey{rule.SecondResourceType, rule.SecondRelation}, to: derived, negated: true},
+		)
+	}
+
+	// Every path through the graph has at most a negated dependency of each
+	// negated rule, unless the relations of a cycle depend on negating
+	// themselves, which raises their strata without bound.
+	strata := map[relationKey]int{}
+	for changed := true; changed; {
+		changed = false
+		for _, d := range dependencies {
+			stratum := strata[d.from]
+			if d.negated {
+				stratum++
+			}
+
+			if stratum <= strata[d.to] {
+				continue
+			}
+
+			if stratum > len(rules.NegatedBinaryRules) {
+				return nil, fmt.Errorf("negation is not stratifiable, since the relationships of '%s' depend on excluding subjects by themselves", d.to)
+			}
+
+			strata[d.to] = stratum
+			changed = true
+		}
+	}
+
+	negated := slices.Clone(rules.NegatedBinaryRules)
+	for i, rule := range negated {
+		negated[i].Stratum = max(
+			strata[relationKey{rule.FirstResourceType, rule.FirstRelation}],
+			strata[relationKey{rule.SecondResourceType, rule.SecondRelation}]+1,
+		)
+	}
+
+	return negated, nil
+}
+
+// maxStratum returns the highest stratum of the negated rules, which is the
+// number of strata the program needs to apply all of them.
+func maxStratum(negated []BinaryRule) int {
+	var stratum int
+	for _, rule := range negated {
+		stratum = max(stratum, rule.Stratum)
+	}
+
+	return stratum
+}
+
+// errWildcardExclusion is returned for an exclusion whose first operand may
+// have relationships of the wildcard subject. A derived relationship of the
+// wildcard subject stands for every subject of its type, so it can't exclude
+// some of them (e.g. 'viewer - banned' with 'viewer@user:*' and
+// 'banned@user:jon'), and would grant the permission to the excluded subjects.
+var errWildcardExclusion = errors.New("the first operand of an exclusion can't have wildcard subjects")
+
+// wildcardRelations returns the relations which may have relationships of the
+// wildcard subject: those with a wildcard type restriction, and those derived
+// from them by usersets and rules which keep the subject of the wildcard. An
+// intersection only keeps it if both of its prerequisites have it.
+func wildcardRelations(rules SchemaQueryRules) map[relationKey]bool {
+	wildcards := map[relationKey]bool{}
+	for changed := true; changed; {
+		changed = false
+		derive := func(to relationKey, from ...relationKey) {
+			if wildcards[to] {
+				return
+			}
+
+			for _, key := range from {
+				if !wildcards[key] {
+					return
+				}
+			}
+
+			wildcards[to] = true
+			changed = true
+		}
+
+		for _, restriction := range rules.RelationTypeRestrictions {
+			to := relationKey{restriction.ResourceType, restriction.Relation}
+			switch {
+			case restriction.Wildcard:
+				derive(to)
+			case restriction.SubjectRelation != "":
+				derive(to, relationKey{restriction.SubjectType, restriction.SubjectRelation})
+			}
+		}
+
+		for _, rule := range rules.UnaryRules {
+			derive(relationKey{rule.ResourceType, rule.DerivedRelation}, relationKey{rule.ResourceType, rule.SourceRelation})
+		}
+
+		for _, rule := range rules.BinaryRules {
+			to := relationKey{rule.SecondResourceType, rule.DerivedRelation}
+			first := relationKey{rule.FirstResourceType, rule.FirstRelation}
+			if rule.Kind == IntersectionRule {
+				derive(to, first, relationKey{rule.SecondResourceType, rule.SecondRelation})
+			} else {
+				derive(to, first)
+			}
+		}
+
+		for _, rule := range rules.NegatedBinaryRules {
+			derive(relationKey{rule.FirstResourceType, rule.DerivedRelation}, relationKey{rule.FirstResourceType, rule.FirstRelation})
+		}
+	}
+
+	return wildcards
+}
+
+// checkWildcardExclusions returns errWildcardExclusion if the first operand of
+// a negated rule may have relationships of the wildcard subject.
+func checkWildcardExclusions(rules SchemaQueryRules) error {
+	wildcards := wildcardRelations(rules)
+	for _, rule := range rules.NegatedBinaryRules {
+		first := relationKey{rule.FirstResourceType, rule.FirstRelation}
+		if wildcards[first] {
+			return fmt.Errorf("%w: '%s' of '%s#%s' may have wildcard subjects", errWildcardExclusion, first, rule.FirstResourceType, rule.DerivedRelation)
+		}
+	}
+
+	return nil
+}
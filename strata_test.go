@@ -0,0 +1,185 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestStratify(t *testing.T) {
+	tests := []struct {
+		name     string
+		rules    SchemaQueryRules
+		expected []int
+	}{
+		{
+			name: "exclusion of relations",
+			rules: SchemaQueryRules{
+				NegatedBinaryRules: []BinaryRule{
+					{FirstResourceType: "document", FirstRelation: "viewer", SecondResourceType: "document", SecondRelation: "banned", DerivedRelation: "can_view"},
+				},
+			},
+			expected: []int{1},
+		},
+		{
+			name: "exclusion of an exclusion",
+			rules: SchemaQueryRules{
+				NegatedBinaryRules: []BinaryRule{
+					{FirstResourceType: "document", FirstRelation: "editor", SecondResourceType: "document", SecondRelation: "can_view", DerivedRelation: "can_edit"},
+					{FirstResourceType: "document", FirstRelation: "viewer", SecondResourceType: "document", SecondRelation: "banned", DerivedRelation: "can_view"},
+				},
+			},
+			expected: []int{2, 1},
+		},
+		{
+			name: "exclusion of a derived relation",
+			rules: SchemaQueryRules{
+				RelationTypeRestrictions: []RelationTypeRestriction{
+					{ResourceType: "document", Relation: "banned", SubjectType: "group", SubjectRelation: "blocked"},
+				},
+				UnaryRules: []UnaryRule{
+					{ResourceType: "group", SourceRelation: "can_view", DerivedRelation: "blocked"},
+				},
+				NegatedBinaryRules: []BinaryRule{
+					{FirstResourceType: "document", FirstRelation: "viewer", SecondResourceType: "document", SecondRelation: "banned", DerivedRelation: "can_view"},
+					{FirstResourceType: "group", FirstRelation: "member", SecondResourceType: "group", SecondRelation: "owner", DerivedRelation: "can_view"},
+				},
+			},
+			expected: []int{2, 1},
+		},
+		{
+			name: "recursion through the first prerequisite",
+			rules: SchemaQueryRules{
+				RelationTypeRestrictions: []RelationTypeRestriction{
+					{ResourceType: "document", Relation: "viewer", SubjectType: "document", SubjectRelation: "can_view"},
+				},
+				NegatedBinaryRules: []BinaryRule{
+					{FirstResourceType: "document", FirstRelation: "viewer", SecondResourceType: "document", SecondRelation: "banned", DerivedRelation: "can_view"},
+				},
+			},
+			expected: []int{1},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			negated, err := stratify(test.rules)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var strata []int
+			for _, rule := range negated {
+				strata = append(strata, rule.Stratum)
+			}
+
+			if !reflect.DeepEqual(strata, test.expected) {
+				t.Errorf("expected strata %v, got %v", test.expected, strata)
+			}
+
+			for _, rule := range test.rules.NegatedBinaryRules {
+				if rule.Stratum != 0 {
+					t.Errorf("expected the rules not to be modified, got %v", rule)
+				}
+			}
+		})
+	}
+}
+
+func TestStratify_CyclicNegation(t *testing.T) {
+	for name, rules := range map[string]SchemaQueryRules{
+		"self": {
+			NegatedBinaryRules: []BinaryRule{
+				{FirstResourceType: "document", FirstRelation: "viewer", SecondResourceType: "document", SecondRelation: "can_view", DerivedRelation: "can_view"},
+			},
+		},
+		"through a userset": {
+			RelationTypeRestrictions: []RelationTypeRestriction{
+				{ResourceType: "document", Relation: "banned", SubjectType: "document", SubjectRelation: "can_view"},
+			},
+			NegatedBinaryRules: []BinaryRule{
+				{FirstResourceType: "document", FirstRelation: "viewer", SecondResourceType: "document", SecondRelation: "banned", DerivedRelation: "can_view"},
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := stratify(rules); err == nil {
+				t.Errorf("expected an error for negation which depends on itself")
+			}
+		})
+	}
+}
+
+func TestCheckWildcardExclusions(t *testing.T) {
+	negated := []BinaryRule{
+		{FirstResourceType: "document", FirstRelation: "viewer", SecondResourceType: "document", SecondRelation: "banned", DerivedRelation: "can_view", Stratum: 1},
+	}
+
+	tests := []struct {
+		name     string
+		rules    SchemaQueryRules
+		rejected bool
+	}{
+		{
+			name: "relation without wildcards",
+			rules: SchemaQueryRules{
+				RelationTypeRestrictions: []RelationTypeRestriction{
+					{ResourceType: "document", Relation: "viewer", SubjectType: "user"},
+					{ResourceType: "document", Relation: "banned", SubjectType: "user", Wildcard: true},
+				},
+			},
+		},
+		{
+			name: "wildcard relation",
+			rules: SchemaQueryRules{
+				RelationTypeRestrictions: []RelationTypeRestriction{
+					{ResourceType: "document", Relation: "viewer", SubjectType: "user", Wildcard: true},
+				},
+			},
+			rejected: true,
+		},
+		{
+			name: "wildcard through a userset",
+			rules: SchemaQueryRules{
+				RelationTypeRestrictions: []RelationTypeRestriction{
+					{ResourceType: "group", Relation: "member", SubjectType: "user", Wildcard: true},
+					{ResourceType: "document", Relation: "viewer", SubjectType: "group", SubjectRelation: "member"},
+				},
+			},
+			rejected: true,
+		},
+		{
+			name: "wildcard through an arrow",
+			rules: SchemaQueryRules{
+				RelationTypeRestrictions: []RelationTypeRestriction{
+					{ResourceType: "folder", Relation: "reader", SubjectType: "user", Wildcard: true},
+				},
+				BinaryRules: []BinaryRule{
+					{FirstResourceType: "folder", FirstRelation: "reader", SecondResourceType: "document", SecondRelation: "parent", DerivedRelation: "viewer", Kind: ArrowRule},
+				},
+			},
+			rejected: true,
+		},
+		{
+			name: "intersection with a relation without wildcards",
+			rules: SchemaQueryRules{
+				RelationTypeRestrictions: []RelationTypeRestriction{
+					{ResourceType: "document", Relation: "public", SubjectType: "user", Wildcard: true},
+					{ResourceType: "document", Relation: "allowed", SubjectType: "user"},
+				},
+				BinaryRules: []BinaryRule{
+					{FirstResourceType: "document", FirstRelation: "public", SecondResourceType: "document", SecondRelation: "allowed", DerivedRelation: "viewer", Kind: IntersectionRule},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.rules.NegatedBinaryRules = negated
+			if err := checkWildcardExclusions(test.rules); errors.Is(err, errWildcardExclusion) != test.rejected {
+				t.Errorf("expected the exclusion to be rejected: %t, got %v", test.rejected, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ruleTable is the rows of a table of rules read by the pipeline's
+// postgres_input connectors.
+type ruleTable struct {
+	Name    string
+	Columns []string
+	Rows    [][]any
+}
+
+// ruleTables returns the rows of the rule tables of bootstrap-pg.sql for the
+// rules, each sorted so that the same rules always have the same rows.
+func ruleTables(rules SchemaQueryRules) []ruleTable {
+	tables := []ruleTable{
+		{Name: "type_restrictions", Columns: []string{"resource_type", "relation", "subject_type", "subject_relation", "wildcard", "caveat", "with_expiration"}},
+		{Name: "unary_rules", Columns: []string{"resource_type", "prerequisite_relationship", "derived_relationship"}},
+		{Name: "binary_rules", Columns: []string{"prerequisite1_resource_type", "prerequisite1_relationship", "prerequisite2_resource_type", "prerequisite2_relationship", "derived_relationship", "kind"}},
+		{Name: "negated_binary_rules", Columns: []string{"prerequisite1_resource_type", "prerequisite1_relationship", "prerequisite2_resource_type", "prerequisite2_relationship", "derived_relationship", "stratum"}},
+	}
+
+	for _, r := range rules.RelationTypeRestrictions {
+		tables[0].Rows = append(tables[0].Rows, []any{r.ResourceType, r.Relation, r.SubjectType, r.SubjectRelation, r.Wildcard, r.Caveat, r.WithExpiration})
+	}
+
+	for _, r := range rules.UnaryRules {
+		tables[1].Rows = append(tables[1].Rows, []any{r.ResourceType, r.SourceRelation, r.DerivedRelation})
+	}
+
+	for _, r := range rules.BinaryRules {
+		tables[2].Rows = append(tables[2].Rows, []any{r.FirstResourceType, r.FirstRelation, r.SecondResourceType, r.SecondRelation, r.DerivedRelation, string(r.Kind)})
+	}
+
+	for _, r := range rules.NegatedBinaryRules {
+		tables[3].Rows = append(tables[3].Rows, []any{r.FirstResourceType, r.FirstRelation, r.SecondResourceType, r.SecondRelation, r.DerivedRelation, r.Stratum})
+	}
+
+	for _, table := range tables {
+		slices.SortFunc(table.Rows, func(a, b []any) int {
+			return cmp.Compare(encodeRow(a), encodeRow(b))
+		})
+	}
+
+	return tables
+}
+
+func encodeRow(row []any) string {
+	encoded, err := json.Marshal(row)
+	if err != nil {
+		panic(err)
+	}
+
+	return string(encoded)
+}
+
+// rulesVersion returns the version of the rules, a digest of the rows of
+// their tables.
+func rulesVersion(tables []ruleTable) string {
+	digest := sha256.New()
+	for _, table := range tables {
+		fmt.Fprintln(digest, table.Name)
+		for _, row := range table.Rows {
+			fmt.Fprintln(digest, encodeRow(row))
+		}
+	}
+
+	return hex.EncodeToString(digest.Sum(nil))
+}
+
+// ruleTablesConn is the subset of *pgx.Conn syncRules uses, which tests
+// replace with a mock.
+type ruleTablesConn interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// syncRules replaces the rows of the rule tables with the rules in a single
+// transaction, along with the row of the schema_version table, unless the
+// rules of that version were synced already. It returns the version of the
+// rules, and whether the tables were replaced.
+func syncRules(ctx context.Context, conn ruleTablesConn, rules SchemaQueryRules) (string, bool, error) {
+	tables := ruleTables(rules)
+	version := rulesVersion(tables)
+
+	var synced bool
+	err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, `select exists (select 1 from schema_version where version = $1)`, version).Scan(&synced)
+		if err != nil || synced {
+			return err
+		}
+
+		for _, table := range tables {
+			if _, err := tx.Exec(ctx, "delete from "+pgx.Identifier{table.Name}.Sanitize()); err != nil {
+				return fmt.Errorf("failed to clear '%s': %w", table.Name, err)
+			}
+
+			if _, err := tx.CopyFrom(ctx, pgx.Identifier{table.Name}, table.Columns, pgx.CopyFromRows(table.Rows)); err != nil {
+				return fmt.Errorf("failed to write '%s': %w", table.Name, err)
+			}
+		}
+
+		if _, err := tx.Exec(ctx, `delete from schema_version`); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `insert into schema_version (version, synced_at) values ($1, $2)`, version, time.Now().UTC())
+		return err
+	})
+	if err != nil {
+		return "", false, err
+	}
+
+	return version, !synced, nil
+}
+
+func runSyncRules(args []string) {
+	flags := flag.NewFlagSet("sync-rules", flag.ExitOnError)
+	schemaPath := flags.String("schema-path", "schema.json", "Path to the (.json) schema file")
+	postgresURI := flags.String("postgres-uri", defaultPostgresURI, "The URI of the Postgres database with the rule tables")
+	flags.Parse(args)
+
+	schema, err := loadSchema(*schemaPath)
+	if err != nil {
+		log.Fatalf("failed to load schema: %v", err)
+	}
+
+	ctx := context.Background()
+
+	conn, err := pgx.Connect(ctx, *postgresURI)
+	if err != nil {
+		log.Fatalf("failed to connect to postgres: %v", err)
+	}
+	defer conn.Close(ctx)
+
+	rules, err := mapSchemaToQueryRules(schema)
+	if err != nil {
+		log.Fatalf("failed to map schema to rules: %v", err)
+	}
+
+	version, replaced, err := syncRules(ctx, conn, rules)
+	if err != nil {
+		log.Fatalf("failed to sync rules: %v", err)
+	}
+
+	if !replaced {
+		fmt.Printf("the rules of version %s are already synced\n", version)
+		return
+	}
+
+	fmt.Printf("synced the rules of version %s\n", version)
+}
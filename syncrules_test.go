@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"regexp"
+	"slices"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+func TestRuleTables(t *testing.T) {
+	schema, err := loadSchema("schema.json")
+	if err != nil {
+		t.Fatalf("failed to load schema: %v", err)
+	}
+
+	rules := mustMapSchemaToQueryRules(t, schema)
+	tables := ruleTables(rules)
+
+	names := make([]string, 0, len(tables))
+	for _, table := range tables {
+		names = append(names, table.Name)
+		for _, row := range table.Rows {
+			if len(row) != len(table.Columns) {
+				t.Errorf("expected a row of '%s' to have %d columns, got %v", table.Name, len(table.Columns), row)
+			}
+		}
+	}
+
+	if expected := []string{"type_restrictions", "unary_rules", "binary_rules", "negated_binary_rules"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected the tables %v, got %v", expected, names)
+	}
+
+	if len(tables[0].Rows) != len(rules.RelationTypeRestrictions) || len(tables[1].Rows) != len(rules.UnaryRules) {
+		t.Errorf("expected a row for each rule")
+	}
+
+	// the rules of a schema are compiled in no particular order
+	reversed := rules
+	reversed.RelationTypeRestrictions = slices.Clone(rules.RelationTypeRestrictions)
+	slices.Reverse(reversed.RelationTypeRestrictions)
+	reversed.UnaryRules = slices.Clone(rules.UnaryRules)
+	slices.Reverse(reversed.UnaryRules)
+
+	if rulesVersion(ruleTables(reversed)) != rulesVersion(tables) {
+		t.Errorf("expected the version not to depend on the order of the rules")
+	}
+
+	changed := reversed
+	changed.UnaryRules = changed.UnaryRules[1:]
+	if rulesVersion(ruleTables(changed)) == rulesVersion(tables) {
+		t.Errorf("expected the version to change with the rules")
+	}
+}
+
+// newTestRuleTablesConn returns a mock of the connection to the Postgres
+// database of bootstrap-pg.sql. pgx.BeginFunc rolls back every transaction
+// after it's committed, which the mock must answer with pgx.ErrTxClosed.
+func newTestRuleTablesConn(t *testing.T) pgxmock.PgxConnIface {
+	t.Helper()
+
+	conn, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("failed to create the postgres mock: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := conn.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+
+		conn.Close(context.Background())
+	})
+
+	return conn
+}
+
+func TestSyncRules(t *testing.T) {
+	schema, err := loadSchema("schema.json")
+	if err != nil {
+		t.Fatalf("failed to load schema: %v", err)
+	}
+
+	rules := mustMapSchemaToQueryRules(t, schema)
+	tables := ruleTables(rules)
+	version := rulesVersion(tables)
+
+	conn := newTestRuleTablesConn(t)
+
+	// the tables are replaced in a single transaction, and then the version
+	conn.ExpectBegin()
+	conn.ExpectQuery("from schema_version where version").
+		WithArgs(version).
+		WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+	for _, table := range tables {
+		conn.ExpectExec(regexp.QuoteMeta("delete from " + pgx.Identifier{table.Name}.Sanitize())).
+			WillReturnResult(pgxmock.NewResult("DELETE", 1))
+		conn.ExpectCopyFrom(pgx.Identifier{table.Name}, table.Columns).
+			WillReturnResult(int64(len(table.Rows)))
+	}
+	conn.ExpectExec("delete from schema_version").
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	conn.ExpectExec("insert into schema_version").
+		WithArgs(version, pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	conn.ExpectCommit()
+	conn.ExpectRollback().WillReturnError(pgx.ErrTxClosed)
+
+	synced, replaced, err := syncRules(context.Background(), conn, rules)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if synced != version || !replaced {
+		t.Errorf("expected the tables to be replaced with version %s, got %s (replaced %t)", version, synced, replaced)
+	}
+
+	// the rules of the version are already synced
+	conn.ExpectBegin()
+	conn.ExpectQuery("from schema_version where version").
+		WithArgs(version).
+		WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(true))
+	conn.ExpectCommit()
+	conn.ExpectRollback().WillReturnError(pgx.ErrTxClosed)
+
+	synced, replaced, err = syncRules(context.Background(), conn, rules)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if synced != version || replaced {
+		t.Errorf("expected the tables of version %s not to be replaced, got %s (replaced %t)", version, synced, replaced)
+	}
+}
+
+func TestSyncRules_Failed(t *testing.T) {
+	schema, err := loadSchema("schema.json")
+	if err != nil {
+		t.Fatalf("failed to load schema: %v", err)
+	}
+
+	rules := mustMapSchemaToQueryRules(t, schema)
+	tables := ruleTables(rules)
+
+	conn := newTestRuleTablesConn(t)
+
+	// a failed write rolls back the tables cleared before it
+	conn.ExpectBegin()
+	conn.ExpectQuery("from schema_version where version").
+		WithArgs(rulesVersion(tables)).
+		WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+	conn.ExpectExec(regexp.QuoteMeta("delete from " + pgx.Identifier{tables[0].Name}.Sanitize())).
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	conn.ExpectCopyFrom(pgx.Identifier{tables[0].Name}, tables[0].Columns).
+		WillReturnError(errors.New("connection reset"))
+	conn.ExpectRollback()
+
+	if _, _, err := syncRules(context.Background(), conn, rules); err == nil {
+		t.Fatalf("expected the sync to fail")
+	}
+}
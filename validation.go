@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	authorizerpb "github.com/jon-whit/feldera-rebac/protos/gen/go/authorizer/v1alpha1"
+	"google.golang.org/protobuf/types/known/structpb"
+	"gopkg.in/yaml.v3"
+)
+
+// validationFile is a file of relationships and the permissions they are
+// expected to result in under a schema, in the style of SpiceDB's validation
+// files.
+//
+//	schemaFile: schema.json
+//	relationships: |-
+//	  group:eng#member@user:jon
+//	  document:readme#viewer@group:eng#member
+//	assertions:
+//	  assertTrue:
+//	    - document:readme#can_view@user:jon
+//	  assertFalse:
+//	    - document:readme#can_view@user:bob
+//	validation:
+//	  document:readme#can_view:
+//	    - group:eng#member
+//	    - user:jon
+type validationFile struct {
+
+	// SchemaFile is the path of the (.json) schema file. Relative paths are
+	// relative to the validation file.
+	SchemaFile string `yaml:"schemaFile"`
+
+	// Relationships are the relationships to evaluate, one per line.
+	Relationships string `yaml:"relationships"`
+
+	// Assertions are checks of the relationships, optionally with a caveat
+	// context (e.g. 'document:1#can_view@user:jon with {"user_ip": "10.0.0.1"}').
+	Assertions struct {
+		AssertTrue     []string `yaml:"assertTrue"`
+		AssertCaveated []string `yaml:"assertCaveated"`
+		AssertFalse    []string `yaml:"assertFalse"`
+	} `yaml:"assertions"`
+
+	// Validation maps relations of resources (e.g. 'document:1#can_view') to
+	// every subject expected to have them, including usersets.
+	Validation map[string][]string `yaml:"validation"`
+}
+
+// validate evaluates the relationships of the validation file at path, and
+// returns a description of each of its assertions and expected relations
+// which don't hold.
+func validate(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read validation file: %w", err)
+	}
+
+	var file validationFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse validation file: %w", err)
+	}
+
+	schemaPath := file.SchemaFile
+	if !filepath.IsAbs(schemaPath) {
+		schemaPath = filepath.Join(filepath.Dir(path), schemaPath)
+	}
+
+	schema, err := loadSchema(schemaPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load schema: %w", err)
+	}
+
+	compiledCaveats, err := compileCaveats(schema.GetCaveats())
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile caveats: %w", err)
+	}
+
+	var relationships []Relationship
+	for _, line := range strings.Split(file.Relationships, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+
+		relationship, err := parseRelationship(line)
+		if err != nil {
+			return nil, err
+		}
+
+		relationships = append(relationships, relationship)
+	}
+
+	rules, err := mapSchemaToQueryRules(schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to map schema to rules: %w", err)
+	}
+
+	derived, err := Evaluate(rules, relationships, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate relationships: %w", err)
+	}
+
+	server := &authorizerServer{
+		store:   newMemoryStore(derived),
+		schema:  schema,
+		caveats: compiledCaveats,
+	}
+
+	var failures []string
+	for _, assertion := range []struct {
+		name           string
+		checks         []string
+		permissionship authorizerpb.Permissionship
+	}{
+		{"assertTrue", file.Assertions.AssertTrue, authorizerpb.Permissionship_PERMISSIONSHIP_HAS_PERMISSION},
+		{"assertCaveated", file.Assertions.AssertCaveated, authorizerpb.Permissionship_PERMISSIONSHIP_CONDITIONAL_PERMISSION},
+		{"assertFalse", file.Assertions.AssertFalse, authorizerpb.Permissionship_PERMISSIONSHIP_NO_PERMISSION},
+	} {
+		for _, check := range assertion.checks {
+			permissionship, err := checkAssertion(server, check)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s '%s': %w", assertion.name, check, err)
+			}
+
+			if permissionship != assertion.permissionship {
+				failures = append(failures, fmt.Sprintf("%s failed for %s: got %s", assertion.name, check, permissionship))
+			}
+		}
+	}
+
+	subjects := map[string][]string{}
+	for _, r := range derived {
+		resource := fmt.Sprintf("%s:%s#%s", r.ResourceType, r.ResourceID, r.Relation)
+		subject := r.SubjectType + ":" + r.SubjectID
+		if r.SubjectRelation != "" {
+			subject += "#" + r.SubjectRelation
+		}
+
+		if !slices.Contains(subjects[resource], subject) {
+			subjects[resource] = append(subjects[resource], subject)
+		}
+	}
+
+	for _, resource := range slices.Sorted(maps.Keys(file.Validation)) {
+		expected := slices.Sorted(slices.Values(file.Validation[resource]))
+		got := slices.Sorted(slices.Values(subjects[resource]))
+
+		var diff []string
+		for _, subject := range expected {
+			if !slices.Contains(got, subject) {
+				diff = append(diff, "  - "+subject)
+			}
+		}
+
+		for _, subject := range got {
+			if !slices.Contains(expected, subject) {
+				diff = append(diff, "  + "+subject)
+			}
+		}
+
+		if len(diff) > 0 {
+			failures = append(failures, fmt.Sprintf("validation failed for %s (- expected, + derived):\n%s", resource, strings.Join(diff, "\n")))
+		}
+	}
+
+	return failures, nil
+}
+
+// checkAssertion checks a relationship, with an optional caveat context (e.g.
+// 'document:1#can_view@user:jon with {"user_ip": "10.0.0.1"}').
+func checkAssertion(server *authorizerServer, check string) (authorizerpb.Permissionship, error) {
+	relationshipString, contextString, _ := strings.Cut(check, " with ")
+
+	relationship, err := parseRelationship(strings.TrimSpace(relationshipString))
+	if err != nil {
+		return 0, err
+	}
+
+	var requestContext *structpb.Struct
+	if contextString != "" {
+		var values map[string]any
+		if err := json.Unmarshal([]byte(contextString), &values); err != nil {
+			return 0, fmt.Errorf("invalid caveat context: %w", err)
+		}
+
+		requestContext, err = structpb.NewStruct(values)
+		if err != nil {
+			return 0, fmt.Errorf("invalid caveat context: %w", err)
+		}
+	}
+
+	resp, err := server.Check(context.Background(), &authorizerpb.CheckRequest{
+		ResourceType:    relationship.ResourceType,
+		ResourceIds:     []string{relationship.ResourceID},
+		Relation:        relationship.Relation,
+		SubjectType:     relationship.SubjectType,
+		SubjectId:       relationship.SubjectID,
+		SubjectRelation: relationship.SubjectRelation,
+		Context:         requestContext,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return resp.GetResultsByResourceId()[relationship.ResourceID].GetPermissionship(), nil
+}
+
+func runTest(args []string) {
+	if len(args) == 0 {
+		log.Fatalf("usage: test <validation file>...")
+	}
+
+	failed := false
+	for _, path := range args {
+		failures, err := validate(path)
+		if err != nil {
+			log.Fatalf("failed to validate '%s': %v", path, err)
+		}
+
+		if len(failures) == 0 {
+			fmt.Printf("PASS %s\n", path)
+			continue
+		}
+
+		failed = true
+		fmt.Printf("FAIL %s\n", path)
+		for _, failure := range failures {
+			fmt.Printf("  %s\n", strings.ReplaceAll(failure, "\n", "\n  "))
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
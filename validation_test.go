@@ -0,0 +1,117 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidationFiles(t *testing.T) {
+	paths, err := filepath.Glob("examples/*/validation.yaml")
+	if err != nil {
+		t.Fatalf("failed to find validation files: %v", err)
+	}
+
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			failures, err := validate(path)
+			if err != nil {
+				t.Fatalf("failed to validate: %v", err)
+			}
+
+			if len(failures) > 0 {
+				t.Errorf("validation failed:\n%s", strings.Join(failures, "\n"))
+			}
+		})
+	}
+}
+
+func TestValidate_Failures(t *testing.T) {
+	schemaPath, err := filepath.Abs("examples/nested-groups/schema.json")
+	if err != nil {
+		t.Fatalf("failed to resolve schema path: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "validation.yaml")
+	err = os.WriteFile(path, []byte(`schemaFile: `+schemaPath+`
+relationships: |-
+  group:eng#member@user:jon
+  document:readme#viewer@group:eng#member
+assertions:
+  assertTrue:
+    - document:readme#can_view@user:bob
+  assertFalse:
+    - document:readme#can_view@user:jon
+validation:
+  document:readme#can_view:
+    - group:eng#member
+    - user:bob
+`), 0o644)
+	if err != nil {
+		t.Fatalf("failed to write validation file: %v", err)
+	}
+
+	failures, err := validate(path)
+	if err != nil {
+		t.Fatalf("failed to validate: %v", err)
+	}
+
+	expected := []string{
+		"assertTrue failed for document:readme#can_view@user:bob: got PERMISSIONSHIP_NO_PERMISSION",
+		"assertFalse failed for document:readme#can_view@user:jon: got PERMISSIONSHIP_HAS_PERMISSION",
+		"validation failed for document:readme#can_view (- expected, + derived):\n  - user:bob\n  + user:jon",
+	}
+	if !reflect.DeepEqual(failures, expected) {
+		t.Errorf("expected %q, got %q", expected, failures)
+	}
+}
+
+func TestParseRelationship(t *testing.T) {
+	tests := []struct {
+		relationship string
+		expected     Relationship
+	}{
+		{
+			relationship: "document:readme#viewer@user:jon",
+			expected:     Relationship{ResourceType: "document", ResourceID: "readme", Relation: "viewer", SubjectType: "user", SubjectID: "jon"},
+		},
+		{
+			relationship: "document:readme#viewer@group:eng#member",
+			expected:     Relationship{ResourceType: "document", ResourceID: "readme", Relation: "viewer", SubjectType: "group", SubjectID: "eng", SubjectRelation: "member"},
+		},
+		{
+			relationship: `document:readme#viewer@user:*[ip_allowlist:{"cidrs": ["10.0.0.0/8"]}][expiration:2030-01-01T00:00:00Z]`,
+			expected: Relationship{
+				ResourceType:  "document",
+				ResourceID:    "readme",
+				Relation:      "viewer",
+				SubjectType:   "user",
+				SubjectID:     "*",
+				CaveatName:    "ip_allowlist",
+				CaveatContext: `{"cidrs": ["10.0.0.0/8"]}`,
+				ExpiresAt:     &Timestamp{time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		r, err := parseRelationship(test.relationship)
+		if err != nil {
+			t.Errorf("failed to parse '%s': %v", test.relationship, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(r, test.expected) {
+			t.Errorf("expected %v, got %v", test.expected, r)
+		}
+	}
+
+	for _, invalid := range []string{"document:readme#viewer", "document#viewer@user:jon", "document:readme#viewer@user:jon[caveat:{"} {
+		if _, err := parseRelationship(invalid); err == nil {
+			t.Errorf("expected an error for '%s'", invalid)
+		}
+	}
+}